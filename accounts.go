@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// pausedAccounts holds the owners whose schedules are all suspended,
+// regardless of each schedule's own Enabled flag. Guarded by mu.
+var pausedAccounts = make(map[string]bool)
+
+// shouldRun reports whether s should have a cron entry. Callers hold mu.
+func shouldRun(s Schedule) bool {
+	return s.Enabled && !pausedAccounts[s.Owner]
+}
+
+type AccountResponse struct {
+	Paused bool `json:"paused"`
+}
+
+// accountHandler reports the account-wide pause switch.
+func accountHandler(w http.ResponseWriter, r *http.Request) {
+	owner, err := getOwnerFromContext(r.Context())
+	if err != nil {
+		http.Error(w, `{"error": "Authentication required"}`, http.StatusUnauthorized)
+		return
+	}
+
+	mu.Lock()
+	paused := pausedAccounts[owner]
+	mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(AccountResponse{Paused: paused})
+}
+
+func pauseAccountHandler(w http.ResponseWriter, r *http.Request) {
+	setAccountPausedHandler(w, r, true)
+}
+
+func resumeAccountHandler(w http.ResponseWriter, r *http.Request) {
+	setAccountPausedHandler(w, r, false)
+}
+
+// setAccountPausedHandler flips the account-wide switch. Pausing removes the
+// cron entries of all the owner's schedules; resuming re-adds those that are
+// individually enabled, so schedules paused one by one stay paused.
+func setAccountPausedHandler(w http.ResponseWriter, r *http.Request, paused bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	owner, err := getOwnerFromContext(r.Context())
+	if err != nil {
+		http.Error(w, `{"error": "Authentication required"}`, http.StatusUnauthorized)
+		return
+	}
+
+	logger := loggerFromContext(r.Context())
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err := dataStore.SetAccountPaused(owner, paused); err != nil {
+		logger.Error("Error saving account pause state", "error", err)
+		http.Error(w, `{"error": "Failed to save account state to store"}`, http.StatusInternalServerError)
+		return
+	}
+
+	if paused {
+		pausedAccounts[owner] = true
+	} else {
+		delete(pausedAccounts, owner)
+	}
+
+	for i, s := range schedules {
+		if s.Owner != owner {
+			continue
+		}
+		switch {
+		case !shouldRun(s) && s.JobID != 0:
+			unregisterSchedule(&schedules[i])
+		case shouldRun(s) && s.JobID == 0:
+			if err := registerSchedule(&schedules[i]); err != nil {
+				logger.Error("Error re-adding schedule", logKeySchedule, s.ID, "error", err)
+			}
+		}
+	}
+	logger.Info("Account schedules paused", "paused", paused)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(AccountResponse{Paused: paused})
+}
+
+// loadAccounts reads the account-wide pause switches from the store. The
+// cron entries follow them once loadSchedules runs.
+func loadAccounts() {
+	owners, err := dataStore.PausedAccounts()
+	if err != nil {
+		log.Printf("Error loading accounts: %v", err)
+		return
+	}
+
+	paused := make(map[string]bool, len(owners))
+	for _, owner := range owners {
+		paused[owner] = true
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	pausedAccounts = paused
+}
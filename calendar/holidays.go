@@ -0,0 +1,101 @@
+package calendar
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// Holiday is a single non-working day.
+type Holiday struct {
+	Date Date   `json:"date"`
+	Name string `json:"name"`
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Date) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := ParseDate(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+type monthDay struct {
+	month Month
+	day   int
+}
+
+// builtinHolidays are the public holidays fixed in the Solar Hijri calendar.
+// Religious holidays follow the lunar calendar, move every year and have to
+// be uploaded as custom holidays.
+var builtinHolidays = []struct {
+	monthDay
+	name string
+}{
+	{monthDay{Farvardin, 1}, "Nowruz"},
+	{monthDay{Farvardin, 2}, "Nowruz"},
+	{monthDay{Farvardin, 3}, "Nowruz"},
+	{monthDay{Farvardin, 4}, "Nowruz"},
+	{monthDay{Farvardin, 12}, "Islamic Republic Day"},
+	{monthDay{Farvardin, 13}, "Sizdah Be-dar"},
+	{monthDay{Khordad, 14}, "Death of Khomeini"},
+	{monthDay{Khordad, 15}, "15 Khordad Uprising"},
+	{monthDay{Bahman, 22}, "Revolution Day"},
+	{monthDay{Esfand, 29}, "Oil Nationalization Day"},
+}
+
+// BuiltinHolidays returns the fixed public holidays of Jalali year y.
+func BuiltinHolidays(y int) []Holiday {
+	holidays := make([]Holiday, 0, len(builtinHolidays))
+	for _, h := range builtinHolidays {
+		holidays = append(holidays, Holiday{Date: Date{Year: y, Month: h.month, Day: h.day}, Name: h.name})
+	}
+	return holidays
+}
+
+// Holidays combines the built-in holidays with a custom list.
+type Holidays struct {
+	custom map[Date]string
+}
+
+// NewHolidays returns the built-in holidays plus custom.
+func NewHolidays(custom []Holiday) *Holidays {
+	h := &Holidays{custom: make(map[Date]string, len(custom))}
+	for _, c := range custom {
+		h.custom[c.Date] = c.Name
+	}
+	return h
+}
+
+// Lookup returns the name of the holiday on d, if any.
+func (h *Holidays) Lookup(d Date) (string, bool) {
+	for _, b := range builtinHolidays {
+		if b.month == d.Month && b.day == d.Day {
+			return b.name, true
+		}
+	}
+	if h != nil {
+		if name, ok := h.custom[d]; ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// Custom returns the custom holidays in date order.
+func (h *Holidays) Custom() []Holiday {
+	list := make([]Holiday, 0, len(h.custom))
+	for d, name := range h.custom {
+		list = append(list, Holiday{Date: d, Name: name})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Date.jdn() < list[j].Date.jdn() })
+	return list
+}
@@ -0,0 +1,241 @@
+// Package calendar provides the Solar Hijri (Jalali) calendar, Iranian
+// public holidays and the rules schedules use to skip runs on them.
+package calendar
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Month is a Jalali month, 1 (Farvardin) to 12 (Esfand). In JSON it can be
+// given either as a number or by name.
+type Month int
+
+const (
+	Farvardin Month = iota + 1
+	Ordibehesht
+	Khordad
+	Tir
+	Mordad
+	Shahrivar
+	Mehr
+	Aban
+	Azar
+	Dey
+	Bahman
+	Esfand
+)
+
+var monthNames = [...]string{
+	"Farvardin", "Ordibehesht", "Khordad", "Tir", "Mordad", "Shahrivar",
+	"Mehr", "Aban", "Azar", "Dey", "Bahman", "Esfand",
+}
+
+func (m Month) String() string {
+	if m < Farvardin || m > Esfand {
+		return "Month(" + strconv.Itoa(int(m)) + ")"
+	}
+	return monthNames[m-1]
+}
+
+// ParseMonth accepts a month number or its transliterated name, ignoring case.
+func ParseMonth(s string) (Month, error) {
+	if n, err := strconv.Atoi(s); err == nil {
+		if n < 1 || n > 12 {
+			return 0, fmt.Errorf("month %d out of range 1-12", n)
+		}
+		return Month(n), nil
+	}
+	for i, name := range monthNames {
+		if strings.EqualFold(s, name) {
+			return Month(i + 1), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown Jalali month %q", s)
+}
+
+func (m *Month) UnmarshalJSON(data []byte) error {
+	var raw any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	var parsed Month
+	var err error
+	switch v := raw.(type) {
+	case float64:
+		parsed, err = ParseMonth(strconv.Itoa(int(v)))
+	case string:
+		parsed, err = ParseMonth(v)
+	default:
+		err = fmt.Errorf("invalid Jalali month %s", data)
+	}
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+func (m Month) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+// Date is a day in the Jalali calendar.
+type Date struct {
+	Year  int
+	Month Month
+	Day   int
+}
+
+func (d Date) String() string {
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, int(d.Month), d.Day)
+}
+
+// ParseDate parses a Jalali date in YYYY-MM-DD form.
+func ParseDate(s string) (Date, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) != 3 {
+		return Date{}, fmt.Errorf("invalid Jalali date %q, want YYYY-MM-DD", s)
+	}
+	var nums [3]int
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return Date{}, fmt.Errorf("invalid Jalali date %q, want YYYY-MM-DD", s)
+		}
+		nums[i] = n
+	}
+	d := Date{Year: nums[0], Month: Month(nums[1]), Day: nums[2]}
+	if !d.Valid() {
+		return Date{}, fmt.Errorf("invalid Jalali date %q", s)
+	}
+	return d, nil
+}
+
+// Valid reports whether d is a real day of the supported Jalali range.
+func (d Date) Valid() bool {
+	if d.Year < minYear || d.Year >= maxYear || d.Month < Farvardin || d.Month > Esfand || d.Day < 1 {
+		return false
+	}
+	return d.Day <= MonthLength(d.Year, d.Month)
+}
+
+// MonthLength returns the number of days of month m in Jalali year y.
+func MonthLength(y int, m Month) int {
+	switch {
+	case m <= Shahrivar:
+		return 31
+	case m <= Bahman:
+		return 30
+	case IsLeapYear(y):
+		return 30
+	default:
+		return 29
+	}
+}
+
+// IsLeapYear reports whether Jalali year y has 366 days.
+func IsLeapYear(y int) bool {
+	return jalCal(y).leap == 0
+}
+
+// FromTime returns the Jalali date of t's calendar day in t's location.
+func FromTime(t time.Time) Date {
+	return fromJDN(gregorianToJDN(t.Year(), t.Month(), t.Day()))
+}
+
+// Time returns midnight of d in loc.
+func (d Date) Time(loc *time.Location) time.Time {
+	y, m, day := jdnToGregorian(d.jdn())
+	return time.Date(y, m, day, 0, 0, 0, 0, loc)
+}
+
+// The conversion below follows the arithmetic of Borkowski's algorithm as used
+// by the jalaali-js library. It is exact for Jalali years in [minYear, maxYear).
+
+const (
+	minYear = -61
+	maxYear = 3178
+)
+
+var breaks = [...]int{-61, 9, 38, 199, 426, 686, 756, 818, 1111, 1181, 1210,
+	1635, 2060, 2097, 2192, 2262, 2324, 2394, 2456, 3178}
+
+type yearInfo struct {
+	leap  int // years since the last leap year, 0 for a leap year
+	gy    int // Gregorian year in which the Jalali year starts
+	march int // day of March on which Farvardin 1 falls
+}
+
+func jalCal(jy int) yearInfo {
+	gy := jy + 621
+	leapJ := -14
+	jp := breaks[0]
+	jump := 0
+	for i := 1; i < len(breaks); i++ {
+		jm := breaks[i]
+		jump = jm - jp
+		if jy < jm {
+			break
+		}
+		leapJ += jump/33*8 + jump%33/4
+		jp = jm
+	}
+	n := jy - jp
+	leapJ += n/33*8 + (n%33+3)/4
+	if jump%33 == 4 && jump-n == 4 {
+		leapJ++
+	}
+	leapG := gy/4 - (gy/100+1)*3/4 - 150
+	march := 20 + leapJ - leapG
+
+	if jump-n < 6 {
+		n = n - jump + (jump+4)/33*33
+	}
+	leap := ((n+1)%33 - 1) % 4
+	if leap == -1 {
+		leap = 4
+	}
+	return yearInfo{leap: leap, gy: gy, march: march}
+}
+
+func (d Date) jdn() int {
+	info := jalCal(d.Year)
+	m := int(d.Month)
+	return gregorianToJDN(info.gy, time.March, info.march) + (m-1)*31 - m/7*(m-7) + d.Day - 1
+}
+
+func fromJDN(jdn int) Date {
+	gy, _, _ := jdnToGregorian(jdn)
+	jy := gy - 621
+	info := jalCal(jy)
+	k := jdn - gregorianToJDN(gy, time.March, info.march)
+	if k >= 0 {
+		if k <= 185 {
+			return Date{Year: jy, Month: Month(1 + k/31), Day: k%31 + 1}
+		}
+		k -= 186
+	} else {
+		jy--
+		k += 179
+		if info.leap == 1 {
+			k++
+		}
+	}
+	return Date{Year: jy, Month: Month(7 + k/30), Day: k%30 + 1}
+}
+
+// unixEpochJDN is the Julian Day Number of 1970-01-01.
+const unixEpochJDN = 2440588
+
+func gregorianToJDN(y int, m time.Month, d int) int {
+	return int(time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix()/86400) + unixEpochJDN
+}
+
+func jdnToGregorian(jdn int) (int, time.Month, int) {
+	t := time.Unix(int64(jdn-unixEpochJDN)*86400, 0).UTC()
+	return t.Year(), t.Month(), t.Day()
+}
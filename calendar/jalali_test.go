@@ -0,0 +1,65 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConversion(t *testing.T) {
+	tests := []struct {
+		gregorian string
+		jalali    Date
+	}{
+		{"2025-03-21", Date{1404, Farvardin, 1}},
+		{"2025-03-20", Date{1403, Esfand, 30}}, // 1403 is a leap year
+		{"2024-03-20", Date{1403, Farvardin, 1}},
+		{"2024-03-19", Date{1402, Esfand, 29}},
+		{"2029-03-20", Date{1408, Farvardin, 1}},
+		{"2030-03-20", Date{1408, Esfand, 30}}, // and so is 1408
+		{"2030-03-21", Date{1409, Farvardin, 1}},
+		{"2025-09-22", Date{1404, Shahrivar, 31}},
+		{"2025-09-23", Date{1404, Mehr, 1}},
+	}
+	for _, tt := range tests {
+		g, err := time.Parse("2006-01-02", tt.gregorian)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := FromTime(g); got != tt.jalali {
+			t.Errorf("FromTime(%s) = %s, want %s", tt.gregorian, got, tt.jalali)
+		}
+		if got := tt.jalali.Time(time.UTC); !got.Equal(g) {
+			t.Errorf("%s.Time() = %s, want %s", tt.jalali, got.Format("2006-01-02"), tt.gregorian)
+		}
+	}
+}
+
+func TestLeapYears(t *testing.T) {
+	for y, leap := range map[int]bool{1399: true, 1402: false, 1403: true, 1404: false, 1407: false, 1408: true, 1409: false} {
+		if got := IsLeapYear(y); got != leap {
+			t.Errorf("IsLeapYear(%d) = %v, want %v", y, got, leap)
+		}
+		wantEsfand := 29
+		if leap {
+			wantEsfand = 30
+		}
+		if got := MonthLength(y, Esfand); got != wantEsfand {
+			t.Errorf("MonthLength(%d, Esfand) = %d, want %d", y, got, wantEsfand)
+		}
+	}
+	if (Date{1404, Esfand, 30}).Valid() {
+		t.Error("1404-12-30 is valid, want invalid since 1404 is not a leap year")
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	d, err := ParseDate("1404-01-13")
+	if err != nil || d != (Date{1404, Farvardin, 13}) {
+		t.Errorf("ParseDate(1404-01-13) = %v, %v", d, err)
+	}
+	for _, s := range []string{"1404-13-01", "1404-07-31", "1404/01/01", "1404-01"} {
+		if _, err := ParseDate(s); err == nil {
+			t.Errorf("ParseDate(%q) succeeded, want an error", s)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package calendar
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Rule restricts the days on which a schedule actually runs. The cron
+// expression still decides when the schedule fires; a firing on a day the rule
+// excludes is skipped.
+type Rule struct {
+	// SkipHolidays skips built-in and custom public holidays.
+	SkipHolidays bool `json:"skipHolidays,omitempty"`
+	// WorkWeekOnly restricts runs to the Iranian work week, Saturday to
+	// Wednesday.
+	WorkWeekOnly bool `json:"workWeekOnly,omitempty"`
+	// Months, if set, restricts runs to these Jalali months.
+	Months []Month `json:"months,omitempty"`
+	// Days, if set, restricts runs to these days of the Jalali month, so
+	// {Months: [Farvardin], Days: [1]} means "1st of Farvardin".
+	Days []int `json:"days,omitempty"`
+}
+
+// Validate reports rules that can never match.
+func (r *Rule) Validate() error {
+	for _, m := range r.Months {
+		if m < Farvardin || m > Esfand {
+			return fmt.Errorf("month %d out of range 1-12", int(m))
+		}
+	}
+	for _, d := range r.Days {
+		if d < 1 || d > 31 {
+			return fmt.Errorf("day %d out of range 1-31", d)
+		}
+	}
+	return nil
+}
+
+// Skip reports whether a run at t should be skipped and why. t is evaluated
+// in its own location, so callers pass it in the schedule's time zone.
+func (r *Rule) Skip(t time.Time, holidays *Holidays) (string, bool) {
+	if r == nil {
+		return "", false
+	}
+
+	d := FromTime(t)
+	if r.WorkWeekOnly {
+		if wd := t.Weekday(); wd == time.Thursday || wd == time.Friday {
+			return fmt.Sprintf("%s is outside the Saturday-Wednesday work week", wd), true
+		}
+	}
+	if r.SkipHolidays {
+		if name, ok := holidays.Lookup(d); ok {
+			return fmt.Sprintf("%s (%s) is a holiday", d, name), true
+		}
+	}
+	if len(r.Months) > 0 && !slices.Contains(r.Months, d.Month) {
+		return fmt.Sprintf("%s is not in %v", d, r.Months), true
+	}
+	if len(r.Days) > 0 && !slices.Contains(r.Days, d.Day) {
+		return fmt.Sprintf("%s is not on day %v of the month", d, r.Days), true
+	}
+	return "", false
+}
@@ -0,0 +1,43 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRuleSkip(t *testing.T) {
+	tehran := time.FixedZone("IRST", 3*3600+1800)
+	holidays := NewHolidays([]Holiday{{Date: Date{1404, Farvardin, 20}, Name: "Company retreat"}})
+
+	tests := []struct {
+		name string
+		rule *Rule
+		at   time.Time
+		skip bool
+	}{
+		{"no rule", nil, time.Date(2025, 4, 11, 9, 0, 0, 0, tehran), false},
+		{"Friday off the work week", &Rule{WorkWeekOnly: true}, time.Date(2025, 4, 11, 9, 0, 0, 0, tehran), true},
+		{"Thursday off the work week", &Rule{WorkWeekOnly: true}, time.Date(2025, 4, 10, 9, 0, 0, 0, tehran), true},
+		{"Saturday in the work week", &Rule{WorkWeekOnly: true}, time.Date(2025, 4, 12, 9, 0, 0, 0, tehran), false},
+		{"listed holiday", &Rule{SkipHolidays: true}, time.Date(2025, 4, 9, 9, 0, 0, 0, tehran), true},
+		{"built-in holiday", &Rule{SkipHolidays: true}, time.Date(2025, 3, 21, 9, 0, 0, 0, tehran), true},
+		{"working day", &Rule{SkipHolidays: true}, time.Date(2025, 4, 8, 9, 0, 0, 0, tehran), false},
+		// 20:30 UTC on 1403-12-30 is already Nowruz in Tehran.
+		{"holiday in the local date only", &Rule{SkipHolidays: true}, time.Date(2025, 3, 20, 20, 30, 0, 0, time.UTC).In(tehran), true},
+		{"same instant in UTC", &Rule{SkipHolidays: true}, time.Date(2025, 3, 20, 20, 30, 0, 0, time.UTC), false},
+		// 22:00 UTC on a Wednesday is already Thursday in Tehran.
+		{"Thursday in the local date only", &Rule{WorkWeekOnly: true}, time.Date(2025, 4, 9, 22, 0, 0, 0, time.UTC).In(tehran), true},
+		{"Wednesday in UTC", &Rule{WorkWeekOnly: true}, time.Date(2025, 4, 9, 22, 0, 0, 0, time.UTC), false},
+		{"month outside the list", &Rule{Months: []Month{Mehr}}, time.Date(2025, 4, 8, 9, 0, 0, 0, tehran), true},
+		{"day of the month", &Rule{Months: []Month{Farvardin}, Days: []int{19}}, time.Date(2025, 4, 8, 9, 0, 0, 0, tehran), false},
+	}
+	for _, tt := range tests {
+		reason, skip := tt.rule.Skip(tt.at, holidays)
+		if skip != tt.skip {
+			t.Errorf("%s: Skip(%s) = %v (%q), want %v", tt.name, tt.at, skip, reason, tt.skip)
+		}
+		if skip && reason == "" {
+			t.Errorf("%s: Skip gave no reason", tt.name)
+		}
+	}
+}
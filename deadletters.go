@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"scheduler/liara"
+	"scheduler/retry"
+	"scheduler/store"
+)
+
+// DeadLetter is a scale action that failed for good, kept so it can be
+// inspected and replayed.
+type DeadLetter = store.DeadLetter
+
+var (
+	// deadLetters is the in-memory copy of the stored dead letters.
+	deadLetters   = make([]DeadLetter, 0)
+	deadLettersMu sync.Mutex
+)
+
+// addDeadLetter records a failed action in memory and in the store.
+func addDeadLetter(a scaleAction, attempts int, err error) {
+	letter := DeadLetter{
+		ID:          uuid.NewString(),
+		Owner:       a.owner,
+		ScheduleID:  a.scheduleID,
+		ServiceType: a.serviceType,
+		ServiceName: a.serviceName,
+		Action:      a.action,
+		Attempts:    attempts,
+		Error:       err.Error(),
+		FailedAt:    time.Now(),
+	}
+	var apiErr *liara.APIError
+	if errors.As(err, &apiErr) {
+		letter.StatusCode = apiErr.StatusCode
+	}
+
+	deadLettersMu.Lock()
+	defer deadLettersMu.Unlock()
+
+	if err := dataStore.AddDeadLetter(letter); err != nil {
+		a.logger.Error("Error saving dead letter", "error", err)
+	}
+	deadLetters = append(deadLetters, letter)
+}
+
+// deadLettersHandler lists the account's dead letters, newest first.
+func deadLettersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	owner, err := getOwnerFromContext(r.Context())
+	if err != nil {
+		http.Error(w, `{"error": "Authentication required"}`, http.StatusUnauthorized)
+		return
+	}
+
+	deadLettersMu.Lock()
+	letters := make([]DeadLetter, 0)
+	for i := len(deadLetters) - 1; i >= 0; i-- {
+		if deadLetters[i].Owner == owner {
+			letters = append(letters, deadLetters[i])
+		}
+	}
+	deadLettersMu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(letters)
+}
+
+// deadLetterItemHandler routes requests under /dead-letters/{id}: DELETE
+// discards a dead letter, POST .../replay runs its action again.
+func deadLetterItemHandler(w http.ResponseWriter, r *http.Request) {
+	pathParts := strings.Split(strings.TrimPrefix(r.URL.Path, "/dead-letters/"), "/")
+	parsedID, err := uuid.Parse(pathParts[0])
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Invalid dead letter ID format: %v"}`, err), http.StatusBadRequest)
+		return
+	}
+	letterID := parsedID.String()
+
+	switch {
+	case len(pathParts) == 1:
+		if r.Method != http.MethodDelete {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		deleteDeadLetterHandler(w, r, letterID)
+	case len(pathParts) == 2 && pathParts[1] == "replay":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		replayDeadLetterHandler(w, r, letterID)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func deleteDeadLetterHandler(w http.ResponseWriter, r *http.Request, letterID string) {
+	owner, err := getOwnerFromContext(r.Context())
+	if err != nil {
+		http.Error(w, `{"error": "Authentication required"}`, http.StatusUnauthorized)
+		return
+	}
+
+	deadLettersMu.Lock()
+	defer deadLettersMu.Unlock()
+
+	index := findDeadLetter(owner, letterID)
+	if index == -1 {
+		http.Error(w, `{"error": "Dead letter not found"}`, http.StatusNotFound)
+		return
+	}
+
+	// Another replica may have deleted it from the store already.
+	if err := dataStore.DeleteDeadLetter(owner, letterID); err != nil && !errors.Is(err, store.ErrNotFound) {
+		loggerFromContext(r.Context()).Error("Error deleting dead letter", "error", err)
+		http.Error(w, `{"error": "Failed to delete dead letter from store"}`, http.StatusInternalServerError)
+		return
+	}
+	deadLetters = append(deadLetters[:index], deadLetters[index+1:]...)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Dead letter deleted successfully"})
+}
+
+// replayDeadLetterHandler runs a dead letter's action again in the
+// background, with the retry policy of its schedule if that still exists. The
+// replay shows up in the schedule's execution history; if it fails again it
+// becomes a new dead letter. A letter is replayed only once; later requests
+// get 409.
+func replayDeadLetterHandler(w http.ResponseWriter, r *http.Request, letterID string) {
+	token, err := getTokenFromContext(r.Context())
+	if err != nil {
+		http.Error(w, `{"error": "Authentication required"}`, http.StatusUnauthorized)
+		return
+	}
+	owner, err := getOwnerFromContext(r.Context())
+	if err != nil {
+		http.Error(w, `{"error": "Authentication required"}`, http.StatusUnauthorized)
+		return
+	}
+
+	deadLettersMu.Lock()
+	index := findDeadLetter(owner, letterID)
+	if index == -1 {
+		deadLettersMu.Unlock()
+		http.Error(w, `{"error": "Dead letter not found"}`, http.StatusNotFound)
+		return
+	}
+	if deadLetters[index].ReplayedAt != nil {
+		deadLettersMu.Unlock()
+		http.Error(w, `{"error": "Dead letter was already replayed"}`, http.StatusConflict)
+		return
+	}
+	// The store claims the letter, so a replica whose copy is out of date
+	// cannot replay it a second time.
+	now := time.Now()
+	err = dataStore.MarkDeadLetterReplayed(owner, letterID, now)
+	if errors.Is(err, store.ErrAlreadyReplayed) {
+		deadLettersMu.Unlock()
+		http.Error(w, `{"error": "Dead letter was already replayed"}`, http.StatusConflict)
+		return
+	}
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		deadLettersMu.Unlock()
+		loggerFromContext(r.Context()).Error("Error marking dead letter as replayed", "error", err)
+		http.Error(w, `{"error": "Failed to update dead letter in store"}`, http.StatusInternalServerError)
+		return
+	}
+	deadLetters[index].ReplayedAt = &now
+	letter := deadLetters[index]
+	deadLettersMu.Unlock()
+
+	policy := retryPolicyFor(owner, letter.ScheduleID)
+	action := scaleAction{owner: owner, scheduleID: letter.ScheduleID, serviceType: letter.ServiceType,
+		serviceName: letter.ServiceName, action: letter.Action, token: token,
+		logger: loggerFromContext(r.Context()).With(logKeySchedule, letter.ScheduleID, logKeyTarget, letter.ServiceName)}
+	action.logger.Info(fmt.Sprintf("Replaying dead letter: turning %s %s %s", letter.Action, letter.ServiceType, letter.ServiceName), "deadLetter", letter.ID)
+	backgroundJobs.Add(1)
+	go func() {
+		defer backgroundJobs.Done()
+		executeAction(action, policy, now)
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(letter)
+}
+
+// findDeadLetter returns the index of the owner's dead letter, or -1.
+// Callers hold deadLettersMu.
+func findDeadLetter(owner, letterID string) int {
+	for i, letter := range deadLetters {
+		if letter.ID == letterID && letter.Owner == owner {
+			return i
+		}
+	}
+	return -1
+}
+
+// retryPolicyFor returns the retry policy of the owner's schedule, or the
+// default policy if there is no such schedule any more.
+func retryPolicyFor(owner, scheduleID string) retry.Policy {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, s := range schedules {
+		if s.ID == scheduleID && s.Owner == owner {
+			return s.Retry.WithDefaults()
+		}
+	}
+	return retry.DefaultPolicy()
+}
+
+// loadDeadLetters reads the dead letters from the store.
+func loadDeadLetters() {
+	loaded, err := dataStore.DeadLetters()
+	if err != nil {
+		log.Printf("Error loading dead letters: %v", err)
+		return
+	}
+
+	deadLettersMu.Lock()
+	defer deadLettersMu.Unlock()
+	deadLetters = loaded
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+)
+
+func TestReplayDeadLetterOnce(t *testing.T) {
+	var scaleCalls atomic.Int32
+	stubLiara(t, func(w http.ResponseWriter, r *http.Request) {
+		scaleCalls.Add(1)
+	})
+	addDeadLetter(testAction("replayed-app"), 3, errors.New("connection refused"))
+	letter, _ := deadLetterFor("replayed-app")
+	path := "/dead-letters/" + letter.ID
+	t.Cleanup(func() { serve(t, deadLetterItemHandler, http.MethodDelete, path, "") })
+
+	if code, body := serve(t, deadLetterItemHandler, http.MethodPost, path+"/replay", ""); code != http.StatusAccepted {
+		t.Fatalf("first replay = %d %s, want 202", code, body)
+	}
+	if code, body := serve(t, deadLetterItemHandler, http.MethodPost, path+"/replay", ""); code != http.StatusConflict {
+		t.Errorf("second replay = %d %s, want 409", code, body)
+	}
+
+	// A replica whose copy missed the replay is stopped by the store.
+	deadLettersMu.Lock()
+	deadLetters[findDeadLetter(testOwner, letter.ID)].ReplayedAt = nil
+	deadLettersMu.Unlock()
+	if code, body := serve(t, deadLetterItemHandler, http.MethodPost, path+"/replay", ""); code != http.StatusConflict {
+		t.Errorf("replay from an out-of-date copy = %d %s, want 409", code, body)
+	}
+
+	backgroundJobs.Wait()
+	if got := scaleCalls.Load(); got != 1 {
+		t.Errorf("%d scale calls, want the action replayed once", got)
+	}
+}
@@ -0,0 +1,479 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/mail"
+	"os"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/google/uuid"
+
+	"scheduler/events"
+	"scheduler/mailer"
+	"scheduler/store"
+)
+
+// Digest frequencies of an email recipient.
+const (
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+const (
+	defaultDailyDigestCron = "0 8 * * *"
+	// The week starts on Saturday in Iran.
+	defaultWeeklyDigestCron      = "0 8 * * 6"
+	maxEmailRecipientsPerAccount = 10
+	emailQueueSize               = 64
+	emailTimeFormat              = "2006-01-02 15:04 MST"
+)
+
+// EmailRecipient is an address that receives an account's notifications.
+type EmailRecipient = store.EmailRecipient
+
+//go:embed templates/email
+var embeddedEmailTemplates embed.FS
+
+var (
+	// emailSender is nil unless SMTP_HOST is set.
+	emailSender    *mailer.Sender
+	emailTemplates *emailTemplateSet
+)
+
+// newEmailSender builds the SMTP client from the SMTP_* variables. It returns
+// nil when SMTP_HOST is not set.
+func newEmailSender() (*mailer.Sender, error) {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		return nil, nil
+	}
+	security, err := mailer.ParseSecurity(os.Getenv("SMTP_SECURITY"))
+	if err != nil {
+		return nil, err
+	}
+	cfg := mailer.Config{
+		Host:     host,
+		Security: security,
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("SMTP_FROM"),
+	}
+	if value := os.Getenv("SMTP_PORT"); value != "" {
+		if cfg.Port, err = strconv.Atoi(value); err != nil || cfg.Port <= 0 || cfg.Port > 65535 {
+			return nil, fmt.Errorf("invalid SMTP_PORT %q", value)
+		}
+	}
+	if cfg.From == "" {
+		cfg.From = cfg.Username
+	}
+	return mailer.NewSender(cfg)
+}
+
+// emailTemplateSet renders the emails: for each kind, "<kind>.subject" and
+// "<kind>.txt.tmpl" from the text templates and "<kind>.html.tmpl" from the
+// HTML ones.
+type emailTemplateSet struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+var emailTemplateFuncs = map[string]any{
+	"datetime": func(t time.Time) string { return t.Local().Format(emailTimeFormat) },
+	"hours":    func(h float64) string { return strconv.FormatFloat(h, 'f', 1, 64) },
+	"amount":   groupThousands,
+}
+
+// loadEmailTemplates parses the email templates from EMAIL_TEMPLATES_DIR, or
+// the built-in ones when it is not set.
+func loadEmailTemplates() (*emailTemplateSet, error) {
+	var files fs.FS
+	if dir := os.Getenv("EMAIL_TEMPLATES_DIR"); dir != "" {
+		files = os.DirFS(dir)
+	} else {
+		var err error
+		if files, err = fs.Sub(embeddedEmailTemplates, "templates/email"); err != nil {
+			return nil, err
+		}
+	}
+
+	text, err := texttemplate.New("").Funcs(emailTemplateFuncs).ParseFS(files, "*.txt.tmpl")
+	if err != nil {
+		return nil, fmt.Errorf("error parsing text email templates: %w", err)
+	}
+	html, err := htmltemplate.New("").Funcs(emailTemplateFuncs).ParseFS(files, "*.html.tmpl")
+	if err != nil {
+		return nil, fmt.Errorf("error parsing HTML email templates: %w", err)
+	}
+	for _, kind := range []string{"failure", "digest", "welcome"} {
+		for _, name := range []string{kind + ".subject", kind + ".txt.tmpl"} {
+			if text.Lookup(name) == nil {
+				return nil, fmt.Errorf("email template %q is missing", name)
+			}
+		}
+		if html.Lookup(kind+".html.tmpl") == nil {
+			return nil, fmt.Errorf("email template %q is missing", kind+".html.tmpl")
+		}
+	}
+	return &emailTemplateSet{text: text, html: html}, nil
+}
+
+// render builds the email of the given kind to the given addresses.
+func (t *emailTemplateSet) render(kind string, data any, to ...string) (mailer.Message, error) {
+	var subject, text, html bytes.Buffer
+	if err := t.text.ExecuteTemplate(&subject, kind+".subject", data); err != nil {
+		return mailer.Message{}, err
+	}
+	if err := t.text.ExecuteTemplate(&text, kind+".txt.tmpl", data); err != nil {
+		return mailer.Message{}, err
+	}
+	if err := t.html.ExecuteTemplate(&html, kind+".html.tmpl", data); err != nil {
+		return mailer.Message{}, err
+	}
+	return mailer.Message{To: to, Subject: strings.TrimSpace(subject.String()), Text: text.String(), HTML: html.String()}, nil
+}
+
+// groupThousands writes n with commas between groups of three digits.
+func groupThousands(n int64) string {
+	digits := strconv.FormatInt(n, 10)
+	sign := ""
+	if n < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(d)
+	}
+	return sign + b.String()
+}
+
+// startEmailNotifications sends failed executions right away to the
+// recipients of their account that asked for them, and adds the daily and
+// weekly digests to the scheduler, so only the leader sends them. Failures
+// are sent by the replica that ran the execution.
+func startEmailNotifications(dailySpec, weeklySpec string) {
+	if emailSender == nil {
+		return
+	}
+
+	for _, digest := range []struct{ frequency, spec string }{{DigestDaily, dailySpec}, {DigestWeekly, weeklySpec}} {
+		if digest.spec == "" {
+			continue
+		}
+		frequency := digest.frequency
+		if _, err := scheduler.AddFunc(digest.spec, func() { sendEmailDigests(frequency, time.Now()) }); err != nil {
+			log.Printf("Error scheduling %s email digests: %v", frequency, err)
+		}
+	}
+
+	sub := eventBus.Subscribe(emailQueueSize, func(e events.Event) bool {
+		execution, ok := e.Data.(Execution)
+		return e.Type == EventExecutionFinished && ok && execution.Outcome == ExecutionFailed
+	})
+	go func() {
+		<-shuttingDown
+		sub.Close()
+	}()
+	go func() {
+		for e := range sub.C {
+			sendFailureEmail(e.Account, e.Data.(Execution))
+		}
+	}()
+	log.Println("Email notifications enabled.")
+}
+
+// failureEmail is what the failure template is rendered with.
+type failureEmail struct {
+	Execution
+	Schedule string // what the schedule does; empty for replayed actions
+}
+
+// sendFailureEmail tells the owner's recipients that want failures about a
+// failed execution.
+func sendFailureEmail(owner string, e Execution) {
+	recipients, err := ownerEmailRecipients(owner)
+	if err != nil {
+		accountLogger(owner).Error("Error loading email recipients", "error", err)
+		return
+	}
+	var to []string
+	for _, r := range recipients {
+		if r.Failures {
+			to = append(to, r.Address)
+		}
+	}
+	if len(to) == 0 {
+		return
+	}
+
+	data := failureEmail{Execution: e}
+	if e.ScheduleID != "" {
+		for _, s := range accountSchedules(owner).Schedules {
+			if s.ID == e.ScheduleID {
+				data.Schedule = describeSchedule(s)
+			}
+		}
+	}
+	sendEmail(owner, "failure", data, to...)
+}
+
+// digestEmail is what the digest template is rendered with.
+type digestEmail struct {
+	Period       string // "Daily" or "Weekly"
+	Report       accountReport
+	Savings      []targetSavings // biggest saving first
+	TotalSavings int64
+	// Unpriced is set when a target turned off has no known price and is
+	// left out of TotalSavings.
+	Unpriced bool
+}
+
+// sendEmailDigests sends every recipient with the given digest frequency a
+// digest of the past day or week. Each account's digest goes out as one
+// email to all its recipients.
+func sendEmailDigests(frequency string, now time.Time) {
+	recipients, err := dataStore.EmailRecipients()
+	if err != nil {
+		log.Printf("Error loading email recipients for the %s digest: %v", frequency, err)
+		return
+	}
+	byOwner := make(map[string][]EmailRecipient)
+	for _, r := range recipients {
+		if r.Digest == frequency {
+			byOwner[r.Owner] = append(byOwner[r.Owner], r)
+		}
+	}
+
+	period, length := "Daily", 24*time.Hour
+	if frequency == DigestWeekly {
+		period, length = "Weekly", 7*24*time.Hour
+	}
+	for owner, recipients := range byOwner {
+		data := digestEmail{Period: period, Report: buildAccountReport(owner, now.Add(-length), now)}
+		data.Savings, data.TotalSavings, data.Unpriced = data.Report.savings(emailToken(recipients))
+		to := make([]string, 0, len(recipients))
+		for _, r := range recipients {
+			to = append(to, r.Address)
+		}
+		sendEmail(owner, "digest", data, to...)
+	}
+}
+
+// emailToken returns a token of the recipients' account to look up prices
+// with: one stored with a recipient, else one a schedule of the account runs
+// with, else "".
+func emailToken(recipients []EmailRecipient) string {
+	for _, r := range recipients {
+		if token, err := storedToken(r.Owner, r.TokenCiphertext); err == nil {
+			return token
+		}
+	}
+	if len(recipients) == 0 {
+		return ""
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for _, s := range schedules {
+		if s.Owner == recipients[0].Owner && s.token != "" {
+			return s.token
+		}
+	}
+	return ""
+}
+
+// sendEmail renders and sends an email of the given kind, logging any error
+// to the owner's logs.
+func sendEmail(owner, kind string, data any, to ...string) {
+	logger := accountLogger(owner)
+	msg, err := emailTemplates.render(kind, data, to...)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error rendering %s email", kind), "error", err)
+		return
+	}
+	if err := emailSender.Send(context.Background(), msg); err != nil {
+		logger.Error(fmt.Sprintf("Error sending %s email", kind), "recipients", len(to), "error", err)
+		return
+	}
+	logger.Info(fmt.Sprintf("Sent %s email", kind), "recipients", len(to))
+}
+
+// ownerEmailRecipients returns the owner's email recipients, oldest first.
+func ownerEmailRecipients(owner string) ([]EmailRecipient, error) {
+	all, err := dataStore.EmailRecipients()
+	if err != nil {
+		return nil, err
+	}
+	recipients := make([]EmailRecipient, 0)
+	for _, r := range all {
+		if r.Owner == owner {
+			recipients = append(recipients, r)
+		}
+	}
+	return recipients, nil
+}
+
+type EmailRecipientRequest struct {
+	Address  string `json:"address"`
+	Failures bool   `json:"failures"`
+	Digest   string `json:"digest"`
+}
+
+// validateEmailRecipientRequest checks req and keeps only the bare address.
+func validateEmailRecipientRequest(req *EmailRecipientRequest) error {
+	addr, err := mail.ParseAddress(req.Address)
+	if err != nil {
+		return errors.New("address must be a valid email address")
+	}
+	req.Address = addr.Address
+	if req.Digest != "" && req.Digest != DigestDaily && req.Digest != DigestWeekly {
+		return fmt.Errorf("digest must be %q, %q or empty", DigestDaily, DigestWeekly)
+	}
+	if !req.Failures && req.Digest == "" {
+		return errors.New("choose failures, a digest or both")
+	}
+	return nil
+}
+
+// emailRecipientsHandler lists the account's email recipients (GET) or adds
+// one (POST).
+func emailRecipientsHandler(w http.ResponseWriter, r *http.Request) {
+	owner, err := getOwnerFromContext(r.Context())
+	if err != nil {
+		http.Error(w, `{"error": "Authentication required"}`, http.StatusUnauthorized)
+		return
+	}
+	if emailSender == nil {
+		http.Error(w, `{"error": "Email is not configured"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		recipients, err := ownerEmailRecipients(owner)
+		if err != nil {
+			loggerFromContext(r.Context()).Error("Error loading email recipients", "error", err)
+			http.Error(w, `{"error": "Failed to fetch email recipients"}`, http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(recipients)
+	case http.MethodPost:
+		addEmailRecipientHandler(w, r, owner)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// welcomeEmail is what the welcome template is rendered with.
+type welcomeEmail struct {
+	EmailRecipient
+}
+
+// addEmailRecipientHandler adds an address to the account's recipients and
+// tells it what it will receive.
+func addEmailRecipientHandler(w http.ResponseWriter, r *http.Request, owner string) {
+	logger := loggerFromContext(r.Context())
+
+	var req EmailRecipientRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+	if err := validateEmailRecipientRequest(&req); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Invalid email recipient: %v"}`, err), http.StatusBadRequest)
+		return
+	}
+	token, err := getTokenFromContext(r.Context())
+	if err != nil {
+		http.Error(w, `{"error": "Authentication required"}`, http.StatusUnauthorized)
+		return
+	}
+
+	existing, err := ownerEmailRecipients(owner)
+	if err != nil {
+		logger.Error("Error loading email recipients", "error", err)
+		http.Error(w, `{"error": "Failed to fetch email recipients"}`, http.StatusInternalServerError)
+		return
+	}
+	for _, e := range existing {
+		if strings.EqualFold(e.Address, req.Address) {
+			http.Error(w, `{"error": "This address is already a recipient; delete it first to change what it receives"}`, http.StatusConflict)
+			return
+		}
+	}
+	if len(existing) >= maxEmailRecipientsPerAccount {
+		http.Error(w, fmt.Sprintf(`{"error": "An account can have at most %d email recipients"}`, maxEmailRecipientsPerAccount), http.StatusConflict)
+		return
+	}
+
+	ciphertext, err := encryptToken(owner, token)
+	if err != nil {
+		logger.Error("Error encrypting token", "error", err)
+		http.Error(w, `{"error": "Failed to encrypt token"}`, http.StatusInternalServerError)
+		return
+	}
+	recipient := EmailRecipient{ID: uuid.NewString(), Owner: owner, Address: req.Address, Failures: req.Failures,
+		Digest: req.Digest, TokenCiphertext: ciphertext, CreatedAt: time.Now()}
+	if err := dataStore.SaveEmailRecipient(recipient); err != nil {
+		logger.Error("Error saving email recipient", "error", err)
+		http.Error(w, `{"error": "Failed to save email recipient to store"}`, http.StatusInternalServerError)
+		return
+	}
+	logger.Info("Added email recipient", "emailRecipient", recipient.ID, "failures", recipient.Failures, "digest", recipient.Digest)
+
+	go sendEmail(owner, "welcome", welcomeEmail{recipient}, recipient.Address)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(recipient)
+}
+
+// emailRecipientItemHandler removes the recipient in DELETE
+// /email/recipients/{id}.
+func emailRecipientItemHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	owner, err := getOwnerFromContext(r.Context())
+	if err != nil {
+		http.Error(w, `{"error": "Authentication required"}`, http.StatusUnauthorized)
+		return
+	}
+
+	parsedID, err := uuid.Parse(strings.TrimPrefix(r.URL.Path, "/email/recipients/"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Invalid email recipient ID format: %v"}`, err), http.StatusBadRequest)
+		return
+	}
+
+	err = dataStore.DeleteEmailRecipient(owner, parsedID.String())
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, `{"error": "Email recipient not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		loggerFromContext(r.Context()).Error("Error deleting email recipient", "error", err)
+		http.Error(w, `{"error": "Failed to delete email recipient from store"}`, http.StatusInternalServerError)
+		return
+	}
+	loggerFromContext(r.Context()).Info("Deleted email recipient", "emailRecipient", parsedID.String())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Email recipient deleted successfully"})
+}
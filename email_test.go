@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"scheduler/liara"
+)
+
+// savingsReport is a day in which project app was kept off for 6 hours and
+// database db for the last 4, with runs that must not count mixed in.
+func savingsReport() accountReport {
+	from := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	run := func(hour int, serviceType, serviceName, action, outcome string) Execution {
+		at := from.Add(time.Duration(hour) * time.Hour)
+		return Execution{Owner: testOwner, ServiceType: serviceType, ServiceName: serviceName, Action: action,
+			PlannedAt: at, StartedAt: at, FinishedAt: at, Attempts: 1, Outcome: outcome}
+	}
+	return accountReport{
+		From: from, To: from.Add(24 * time.Hour), Succeeded: 4, Failed: 1,
+		executions: []Execution{
+			run(1, "project", "app", "on", ExecutionSucceeded), // on before any off
+			run(2, "project", "app", "off", ExecutionSucceeded),
+			run(3, "project", "worker", "off", ExecutionFailed),
+			run(8, "project", "app", "on", ExecutionSucceeded),
+			run(20, "database", "db", "off", ExecutionSucceeded),
+		},
+	}
+}
+
+// stubPrices serves app on plan appPlan and db at 1,250 Toman an hour, with
+// a project on plan small costing 1,000.
+func stubPrices(t *testing.T, appPlan string) {
+	t.Helper()
+	saved := planHourlyPrices
+	planHourlyPrices = map[string]int{"small": 1000}
+	t.Cleanup(func() { planHourlyPrices = saved })
+
+	stubLiara(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/projects":
+			json.NewEncoder(w).Encode(liara.ProjectsResponse{Projects: []liara.Project{{ProjectID: "app", PlanID: appPlan}}})
+		case "/v1/databases":
+			json.NewEncoder(w).Encode(liara.DatabasesResponse{Databases: []liara.Database{{DBId: "db", HourlyPrice: 1250}}})
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+func TestAccountReportSavings(t *testing.T) {
+	tests := []struct {
+		name         string
+		appPlan      string
+		token        string
+		want         []targetSavings
+		wantTotal    int64
+		wantUnpriced bool
+	}{
+		{
+			name:    "all priced",
+			appPlan: "small",
+			token:   testToken,
+			want: []targetSavings{
+				{ServiceType: "project", ServiceName: "app", OffHours: 6, HourlyPrice: 1000, Savings: 6000},
+				{ServiceType: "database", ServiceName: "db", OffHours: 4, HourlyPrice: 1250, Savings: 5000},
+			},
+			wantTotal: 11000,
+		},
+		{
+			name:    "plan without a price",
+			appPlan: "large",
+			token:   testToken,
+			want: []targetSavings{
+				{ServiceType: "database", ServiceName: "db", OffHours: 4, HourlyPrice: 1250, Savings: 5000},
+				{ServiceType: "project", ServiceName: "app", OffHours: 6},
+			},
+			wantTotal:    5000,
+			wantUnpriced: true,
+		},
+		{
+			name:    "no token",
+			appPlan: "small",
+			want: []targetSavings{
+				{ServiceType: "project", ServiceName: "app", OffHours: 6},
+				{ServiceType: "database", ServiceName: "db", OffHours: 4},
+			},
+			wantUnpriced: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stubPrices(t, tt.appPlan)
+			savings, total, unpriced := savingsReport().savings(tt.token)
+			if total != tt.wantTotal || unpriced != tt.wantUnpriced {
+				t.Errorf("total, unpriced = %d, %v, want %d, %v", total, unpriced, tt.wantTotal, tt.wantUnpriced)
+			}
+			if len(savings) != len(tt.want) {
+				t.Fatalf("savings = %+v, want %+v", savings, tt.want)
+			}
+			for i := range savings {
+				if savings[i] != tt.want[i] {
+					t.Errorf("savings[%d] = %+v, want %+v", i, savings[i], tt.want[i])
+				}
+			}
+		})
+	}
+
+	if savings, total, unpriced := (accountReport{}).savings(testToken); savings != nil || total != 0 || unpriced {
+		t.Errorf("savings of an empty report = %+v, %d, %v, want nothing", savings, total, unpriced)
+	}
+}
+
+// embeddedTemplates loads the email templates built into the binary.
+func embeddedTemplates(t *testing.T) *emailTemplateSet {
+	t.Helper()
+	t.Setenv("EMAIL_TEMPLATES_DIR", "")
+	templates, err := loadEmailTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return templates
+}
+
+func TestFailureEmail(t *testing.T) {
+	templates := embeddedTemplates(t)
+	data := failureEmail{
+		Execution: Execution{ServiceType: "project", ServiceName: "app", Action: "off", Attempts: 3,
+			PlannedAt: time.Date(2026, 3, 2, 22, 0, 0, 0, time.UTC), Outcome: ExecutionFailed, Error: "503 Service Unavailable"},
+		Schedule: "turns off every day at 22:00",
+	}
+
+	msg, err := templates.render("failure", data, "ops@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Failed to turn off project app"; msg.Subject != want {
+		t.Errorf("subject = %q, want %q", msg.Subject, want)
+	}
+	if len(msg.To) != 1 || msg.To[0] != "ops@example.com" {
+		t.Errorf("to = %v, want [ops@example.com]", msg.To)
+	}
+	for _, want := range []string{"after 3 attempt(s)", "503 Service Unavailable", "turns off every day at 22:00"} {
+		if !strings.Contains(msg.Text, want) {
+			t.Errorf("text does not contain %q:\n%s", want, msg.Text)
+		}
+		if !strings.Contains(msg.HTML, want) {
+			t.Errorf("HTML does not contain %q:\n%s", want, msg.HTML)
+		}
+	}
+
+	data.Schedule = ""
+	if msg, err = templates.render("failure", data); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(msg.Text, "Schedule:") {
+		t.Errorf("text of a replayed action mentions a schedule:\n%s", msg.Text)
+	}
+}
+
+func TestDigestEmail(t *testing.T) {
+	templates := embeddedTemplates(t)
+
+	for _, period := range []string{"Daily", "Weekly"} {
+		t.Run(period, func(t *testing.T) {
+			stubPrices(t, "small")
+			data := digestEmail{Period: period, Report: savingsReport()}
+			data.Savings, data.TotalSavings, data.Unpriced = data.Report.savings(testToken)
+
+			msg, err := templates.render("digest", data, "ops@example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := period + " digest: 4 succeeded, 1 failed, about 11,000 Toman saved"; msg.Subject != want {
+				t.Errorf("subject = %q, want %q", msg.Subject, want)
+			}
+			for _, want := range []string{
+				period + " digest",
+				"Estimated savings: 11,000 Toman",
+				"project app: off for 6.0 h, 6,000 Toman",
+				"database db: off for 4.0 h, 5,000 Toman",
+			} {
+				if !strings.Contains(msg.Text, want) {
+					t.Errorf("text does not contain %q:\n%s", want, msg.Text)
+				}
+			}
+			if strings.Contains(msg.Text, "unknown price") {
+				t.Errorf("text mentions unknown prices though all are known:\n%s", msg.Text)
+			}
+			for _, want := range []string{period, "11,000"} {
+				if !strings.Contains(msg.HTML, want) {
+					t.Errorf("HTML does not contain %q:\n%s", want, msg.HTML)
+				}
+			}
+		})
+	}
+
+	t.Run("Unpriced", func(t *testing.T) {
+		stubPrices(t, "large")
+		data := digestEmail{Period: "Weekly", Report: savingsReport()}
+		data.Savings, data.TotalSavings, data.Unpriced = data.Report.savings(testToken)
+
+		msg, err := templates.render("digest", data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range []string{
+			"Estimated savings: 5,000 Toman",
+			"project app: off for 6.0 h, price unknown",
+			"Targets of unknown price are not counted.",
+		} {
+			if !strings.Contains(msg.Text, want) {
+				t.Errorf("text does not contain %q:\n%s", want, msg.Text)
+			}
+		}
+	})
+
+	t.Run("NothingTurnedOff", func(t *testing.T) {
+		data := digestEmail{Period: "Daily", Report: accountReport{From: time.Now().Add(-24 * time.Hour), To: time.Now()}}
+		msg, err := templates.render("digest", data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(msg.Subject, "saved") {
+			t.Errorf("subject = %q, want no savings", msg.Subject)
+		}
+		if !strings.Contains(msg.Text, "Nothing was turned off") {
+			t.Errorf("text does not say nothing was turned off:\n%s", msg.Text)
+		}
+	})
+}
+
+func TestGroupThousands(t *testing.T) {
+	for n, want := range map[int64]string{0: "0", 999: "999", 1000: "1,000", 1234567: "1,234,567", -12500: "-12,500"} {
+		if got := groupThousands(n); got != want {
+			t.Errorf("groupThousands(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
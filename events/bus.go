@@ -0,0 +1,93 @@
+// Package events is an in-process publish/subscribe bus for things that
+// happen to schedules, so that streams and notifiers can follow them without
+// the scheduler knowing about its listeners.
+package events
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Event is something that happened to an account.
+type Event struct {
+	ID      uint64    `json:"id"`
+	Type    string    `json:"type"`
+	Account string    `json:"-"` // owner the event belongs to
+	Time    time.Time `json:"time"`
+	Data    any       `json:"data,omitempty"`
+}
+
+// Bus delivers every published event to the subscribers whose filter
+// accepts it. Publishing never blocks: a subscriber that does not keep up
+// misses events rather than stalling the scheduler.
+type Bus struct {
+	mu     sync.RWMutex
+	subs   map[*Subscription]struct{}
+	nextID atomic.Uint64
+}
+
+func NewBus() *Bus {
+	return &Bus{subs: make(map[*Subscription]struct{})}
+}
+
+// Subscription receives events on C until it is closed.
+type Subscription struct {
+	C <-chan Event
+
+	c       chan Event
+	bus     *Bus
+	match   func(Event) bool
+	dropped atomic.Uint64
+	once    sync.Once
+}
+
+// Subscribe registers a subscriber with room for buffer pending events. A
+// nil match accepts every event.
+func (b *Bus) Subscribe(buffer int, match func(Event) bool) *Subscription {
+	c := make(chan Event, buffer)
+	sub := &Subscription{C: c, c: c, bus: b, match: match}
+
+	b.mu.Lock()
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+	return sub
+}
+
+// Publish stamps e with an ID and, if unset, the current time and hands it
+// to the matching subscribers.
+func (b *Bus) Publish(e Event) {
+	e.ID = b.nextID.Add(1)
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for sub := range b.subs {
+		if sub.match != nil && !sub.match(e) {
+			continue
+		}
+		select {
+		case sub.c <- e:
+		default:
+			sub.dropped.Add(1)
+		}
+	}
+}
+
+// Dropped returns how many events the subscriber missed because its buffer
+// was full.
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Close unsubscribes and closes C. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.bus.mu.Lock()
+		delete(s.bus.subs, s)
+		s.bus.mu.Unlock()
+		close(s.c)
+	})
+}
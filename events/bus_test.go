@@ -0,0 +1,125 @@
+package events
+
+import (
+	"testing"
+	"time"
+)
+
+// receive returns the next event on sub or fails the test.
+func receive(t *testing.T, sub *Subscription) Event {
+	t.Helper()
+	select {
+	case e, ok := <-sub.C:
+		if !ok {
+			t.Fatal("subscription closed, want an event")
+		}
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("no event received")
+		return Event{}
+	}
+}
+
+// expectNone fails the test if sub has an event pending.
+func expectNone(t *testing.T, sub *Subscription) {
+	t.Helper()
+	select {
+	case e := <-sub.C:
+		t.Fatalf("received %+v, want nothing", e)
+	default:
+	}
+}
+
+func TestPublish(t *testing.T) {
+	bus := NewBus()
+	all := bus.Subscribe(8, nil)
+	defer all.Close()
+
+	at := time.Date(2025, 4, 9, 22, 0, 0, 0, time.UTC)
+	bus.Publish(Event{Type: "first", Account: "alice", Time: at})
+	bus.Publish(Event{Type: "second", Account: "bob", Data: 42})
+
+	first, second := receive(t, all), receive(t, all)
+	if first.Type != "first" || !first.Time.Equal(at) || first.Account != "alice" {
+		t.Errorf("first event = %+v, want it as published", first)
+	}
+	if second.Type != "second" || second.Time.IsZero() || second.Data != 42 {
+		t.Errorf("second event = %+v, want it stamped with the current time", second)
+	}
+	if first.ID == 0 || second.ID <= first.ID {
+		t.Errorf("IDs = %d, %d, want increasing from 1", first.ID, second.ID)
+	}
+}
+
+func TestSubscribeFiltersByAccount(t *testing.T) {
+	bus := NewBus()
+	byAccount := func(account string) func(Event) bool {
+		return func(e Event) bool { return e.Account == account }
+	}
+	alice, bob := bus.Subscribe(8, byAccount("alice")), bus.Subscribe(8, byAccount("bob"))
+	defer alice.Close()
+	defer bob.Close()
+
+	bus.Publish(Event{Type: "a", Account: "alice"})
+	bus.Publish(Event{Type: "b", Account: "bob"})
+	bus.Publish(Event{Type: "c", Account: "carol"})
+
+	if e := receive(t, alice); e.Type != "a" {
+		t.Errorf("alice received %q, want a", e.Type)
+	}
+	expectNone(t, alice)
+	if e := receive(t, bob); e.Type != "b" {
+		t.Errorf("bob received %q, want b", e.Type)
+	}
+	expectNone(t, bob)
+}
+
+func TestSlowSubscriberMissesEvents(t *testing.T) {
+	const buffer = 64
+	bus := NewBus()
+	slow, fast := bus.Subscribe(buffer, nil), bus.Subscribe(buffer+10, nil)
+	defer slow.Close()
+	defer fast.Close()
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < buffer+10; i++ {
+			bus.Publish(Event{Type: "tick"})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Publish blocked on a full subscriber")
+	}
+
+	if got := slow.Dropped(); got != 10 {
+		t.Errorf("slow subscriber dropped %d events, want 10", got)
+	}
+	if got := len(slow.C); got != buffer {
+		t.Errorf("slow subscriber has %d events pending, want %d", got, buffer)
+	}
+	// The oldest events are kept and the newest dropped.
+	if e := receive(t, slow); e.ID != 1 {
+		t.Errorf("first pending event has ID %d, want 1", e.ID)
+	}
+	if got := fast.Dropped(); got != 0 {
+		t.Errorf("subscriber with room dropped %d events", got)
+	}
+}
+
+func TestClose(t *testing.T) {
+	bus := NewBus()
+	sub := bus.Subscribe(8, nil)
+	sub.Close()
+	sub.Close() // a second Close is harmless
+
+	if _, ok := <-sub.C; ok {
+		t.Error("C is still open after Close")
+	}
+	bus.Publish(Event{Type: "after"}) // must not send on the closed channel
+	if n := len(bus.subs); n != 0 {
+		t.Errorf("%d subscribers left after Close, want 0", n)
+	}
+}
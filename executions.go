@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/robfig/cron/v3"
+
+	"scheduler/retry"
+	"scheduler/store"
+)
+
+const (
+	ExecutionRunning   = "running" // only seen in execution-started events
+	ExecutionSucceeded = "succeeded"
+	ExecutionFailed    = "failed"
+	ExecutionSkipped   = "skipped" // excluded by the schedule's calendar rule
+)
+
+const (
+	// maxResponseBodyLength caps the Liara response body kept per execution.
+	maxResponseBodyLength = 4096
+
+	defaultExecutionsLimit = 50
+	maxExecutionsLimit     = 500
+)
+
+// Execution is one run of a schedule's action, including all its attempts.
+type Execution = store.Execution
+
+type ExecutionsResponse struct {
+	Executions []Execution `json:"executions"`
+	Total      int         `json:"total"`
+	Limit      int         `json:"limit"`
+	Offset     int         `json:"offset"`
+}
+
+// plannedTime returns the activation of sched that a job running at now
+// belongs to. Cron activations are at least a minute apart, so it is the
+// first activation in the minute before now.
+func plannedTime(sched cron.Schedule, now time.Time) time.Time {
+	planned := sched.Next(now.Add(-time.Minute))
+	if planned.IsZero() || planned.After(now) {
+		return now
+	}
+	return planned
+}
+
+// newExecution starts the record of a run of a that was due at planned.
+func newExecution(a scaleAction, planned time.Time) Execution {
+	return Execution{
+		ID:          uuid.NewString(),
+		ScheduleID:  a.scheduleID,
+		Owner:       a.owner,
+		ServiceType: a.serviceType,
+		ServiceName: a.serviceName,
+		Action:      a.action,
+		PlannedAt:   planned,
+		StartedAt:   time.Now(),
+	}
+}
+
+// executeAction runs a under policy, records the run in the execution
+// history and returns the record.
+func executeAction(a scaleAction, policy retry.Policy, planned time.Time) Execution {
+	execution := newExecution(a, planned)
+	a.executionID = execution.ID
+	a.logger = a.logger.With(logKeyExecution, execution.ID)
+	execution.Outcome = ExecutionRunning
+	publishEvent(a.owner, EventExecutionStarted, execution)
+	result := runWithRetry(a, policy)
+	execution.FinishedAt = time.Now()
+	execution.Attempts = result.attempts
+	if result.response != nil {
+		execution.StatusCode = result.response.StatusCode
+		execution.ResponseBody = truncate(string(result.response.Body), maxResponseBodyLength)
+	}
+	if result.err != nil {
+		execution.Outcome = ExecutionFailed
+		execution.Error = result.err.Error()
+	} else {
+		execution.Outcome = ExecutionSucceeded
+	}
+	recordExecution(a.logger, execution)
+	publishEvent(a.owner, EventExecutionFinished, execution)
+	return execution
+}
+
+// recordSkippedExecution records a run that the calendar rule excluded.
+func recordSkippedExecution(a scaleAction, planned time.Time, reason string) {
+	execution := newExecution(a, planned)
+	execution.FinishedAt = execution.StartedAt
+	execution.Outcome = ExecutionSkipped
+	execution.Error = reason
+	recordExecution(a.logger, execution)
+	publishEvent(a.owner, EventExecutionFinished, execution)
+}
+
+// recordExecution stores an execution in the history.
+func recordExecution(logger *slog.Logger, e Execution) {
+	if err := dataStore.AddExecution(e); err != nil {
+		logger.Error("Error saving execution", "error", err)
+	}
+}
+
+// parseExecutionFilter reads limit, offset, from and to (RFC 3339) from the
+// query string.
+func parseExecutionFilter(r *http.Request, owner, scheduleID string) (store.ExecutionFilter, error) {
+	f := store.ExecutionFilter{Owner: owner, ScheduleID: scheduleID, Limit: defaultExecutionsLimit}
+	query := r.URL.Query()
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxExecutionsLimit {
+			return f, fmt.Errorf("limit must be between 1 and %d", maxExecutionsLimit)
+		}
+		f.Limit = limit
+	}
+	if v := query.Get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return f, fmt.Errorf("offset must be a non-negative integer")
+		}
+		f.Offset = offset
+	}
+	for _, bound := range []struct {
+		name string
+		t    *time.Time
+	}{{"from", &f.From}, {"to", &f.To}} {
+		if v := query.Get(bound.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return f, fmt.Errorf("%s must be an RFC 3339 time", bound.name)
+			}
+			*bound.t = t
+		}
+	}
+	return f, nil
+}
+
+// executionsHandler lists a schedule's executions. Executions stay readable
+// after their schedule is deleted. A schedule that is not the caller's and
+// left them no executions is reported as missing, like in the other schedule
+// handlers.
+func executionsHandler(w http.ResponseWriter, r *http.Request, scheduleID string) {
+	owner, err := getOwnerFromContext(r.Context())
+	if err != nil {
+		http.Error(w, `{"error": "Authentication required"}`, http.StatusUnauthorized)
+		return
+	}
+
+	filter, err := parseExecutionFilter(r, owner, scheduleID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Invalid query: %v"}`, err), http.StatusBadRequest)
+		return
+	}
+
+	executions, total, err := dataStore.Executions(filter)
+	if err != nil {
+		loggerFromContext(r.Context()).Error("Error querying executions", "error", err)
+		http.Error(w, `{"error": "Failed to fetch executions"}`, http.StatusInternalServerError)
+		return
+	}
+	if total == 0 && !ownsSchedule(owner, scheduleID) {
+		http.Error(w, `{"error": "Schedule not found"}`, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ExecutionsResponse{Executions: executions, Total: total, Limit: filter.Limit, Offset: filter.Offset})
+}
+
+// truncate shortens s to at most n bytes.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
+
+// ownsSchedule reports whether the owner has a schedule with the given ID.
+func ownsSchedule(owner, scheduleID string) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, s := range schedules {
+		if s.ID == scheduleID && s.Owner == owner {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// readinessTimeout bounds each dependency check of /readyz.
+const readinessTimeout = 3 * time.Second
+
+// schedulerStarted is set once the cron scheduler is running.
+var schedulerStarted atomic.Bool
+
+// DependencyStatus is the result of one readiness check.
+type DependencyStatus struct {
+	Status  string `json:"status"` // "ok", "failed" or "skipped"
+	Latency string `json:"latency,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+type ReadinessResponse struct {
+	Status string                      `json:"status"` // "ready" or "not ready"
+	Role   string                      `json:"role"`   // "leader" or "follower"
+	Checks map[string]DependencyStatus `json:"checks"`
+}
+
+// healthzHandler reports that the process is alive.
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// readyzHandler reports the replica's role and whether it can do its work:
+// the store is reachable unless it is in memory, the scheduler is running
+// on the leader and the Liara API answers. It answers 503 if any check fails.
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+	response := ReadinessResponse{Status: "ready", Role: role(), Checks: make(map[string]DependencyStatus)}
+	checks := map[string]func(context.Context) error{
+		"liara": func(ctx context.Context) error {
+			return liaraClient.Ping(ctx)
+		},
+	}
+	if storeBackend != "memory" {
+		checks["store"] = dataStore.Ping
+	} else {
+		response.Checks["store"] = DependencyStatus{Status: "skipped"}
+	}
+	// Followers leave the schedules to the leader.
+	if isLeader.Load() {
+		checks["scheduler"] = func(context.Context) error {
+			if !schedulerStarted.Load() {
+				return errors.New("scheduler not started")
+			}
+			return nil
+		}
+	} else {
+		response.Checks["scheduler"] = DependencyStatus{Status: "skipped"}
+	}
+
+	var wg sync.WaitGroup
+	var resultsMu sync.Mutex
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := check(ctx)
+			result := DependencyStatus{Status: "ok", Latency: time.Since(start).String()}
+			if err != nil {
+				result.Status = "failed"
+				result.Error = err.Error()
+			}
+
+			resultsMu.Lock()
+			defer resultsMu.Unlock()
+			response.Checks[name] = result
+			if err != nil {
+				response.Status = "not ready"
+			}
+		}()
+	}
+	wg.Wait()
+
+	w.Header().Set("Content-Type", "application/json")
+	if response.Status != "ready" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(response)
+}
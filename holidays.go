@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"scheduler/calendar"
+	"scheduler/store"
+)
+
+var (
+	// Custom holidays uploaded by each owner, on top of the built-in ones.
+	customHolidays   = make(map[string]*calendar.Holidays)
+	customHolidaysMu sync.Mutex
+)
+
+// holidaysFor returns the built-in holidays plus the owner's custom ones.
+func holidaysFor(owner string) *calendar.Holidays {
+	customHolidaysMu.Lock()
+	defer customHolidaysMu.Unlock()
+
+	if h, ok := customHolidays[owner]; ok {
+		return h
+	}
+	return calendar.NewHolidays(nil)
+}
+
+// marshalCalendarRule encodes a rule for storage; a nil rule is stored as
+// nothing.
+func marshalCalendarRule(rule *calendar.Rule) ([]byte, error) {
+	if rule == nil {
+		return nil, nil
+	}
+	return json.Marshal(rule)
+}
+
+type HolidaysResponse struct {
+	Year    int                `json:"year"`
+	BuiltIn []calendar.Holiday `json:"builtIn"`
+	Custom  []calendar.Holiday `json:"custom"`
+}
+
+// holidaysHandler lists holidays on GET and replaces the owner's custom
+// holidays on PUT.
+func holidaysHandler(w http.ResponseWriter, r *http.Request) {
+	owner, err := getOwnerFromContext(r.Context())
+	if err != nil {
+		http.Error(w, `{"error": "Authentication required"}`, http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		year := calendar.FromTime(time.Now()).Year
+		response := HolidaysResponse{
+			Year:    year,
+			BuiltIn: calendar.BuiltinHolidays(year),
+			Custom:  holidaysFor(owner).Custom(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	case http.MethodPut:
+		var custom []calendar.Holiday
+		if err := json.NewDecoder(r.Body).Decode(&custom); err != nil {
+			http.Error(w, fmt.Sprintf(`{"error": "Invalid request body: %v"}`, err), http.StatusBadRequest)
+			return
+		}
+
+		stored := make([]store.Holiday, len(custom))
+		for i, h := range custom {
+			stored[i] = store.Holiday{Owner: owner, Date: h.Date.String(), Name: h.Name}
+		}
+		if err := dataStore.ReplaceHolidays(owner, stored); err != nil {
+			loggerFromContext(r.Context()).Error("Error saving holidays", "error", err)
+			http.Error(w, `{"error": "Failed to save holidays to store"}`, http.StatusInternalServerError)
+			return
+		}
+
+		customHolidaysMu.Lock()
+		customHolidays[owner] = calendar.NewHolidays(custom)
+		customHolidaysMu.Unlock()
+		loggerFromContext(r.Context()).Info("Holidays updated", "count", len(custom))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Holidays updated successfully"})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// loadHolidays reads every owner's custom holidays from the store.
+func loadHolidays() {
+	stored, err := dataStore.Holidays()
+	if err != nil {
+		log.Printf("Error loading holidays: %v", err)
+		return
+	}
+
+	byOwner := make(map[string][]calendar.Holiday)
+	for _, h := range stored {
+		d, err := calendar.ParseDate(h.Date)
+		if err != nil {
+			log.Printf("Error parsing stored holiday %q: %v", h.Date, err)
+			continue
+		}
+		byOwner[h.Owner] = append(byOwner[h.Owner], calendar.Holiday{Date: d, Name: h.Name})
+	}
+
+	loaded := make(map[string]*calendar.Holidays, len(byOwner))
+	for owner, list := range byOwner {
+		loaded[owner] = calendar.NewHolidays(list)
+	}
+
+	customHolidaysMu.Lock()
+	defer customHolidaysMu.Unlock()
+	customHolidays = loaded
+}
@@ -0,0 +1,156 @@
+// Package keyring encrypts Liara API tokens at rest with AES-GCM.
+//
+// A keyring holds one or more named 256-bit keys. The first key is the
+// primary and is used for all new ciphertexts; the others are only used to
+// decrypt values written before a key rotation. Ciphertexts are encoded as
+// "v1:<key id>:<base64(nonce || sealed)>".
+package keyring
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	version = "v1"
+	keySize = 32
+)
+
+// ErrUnknownKey is returned when a ciphertext was written with a key that is
+// no longer part of the keyring.
+var ErrUnknownKey = errors.New("keyring: ciphertext uses an unknown key")
+
+type key struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// Keyring encrypts with its primary key and decrypts with any of its keys.
+type Keyring struct {
+	keys []key
+}
+
+// Parse builds a keyring from "id:base64key" entries separated by commas or
+// newlines. The first entry becomes the primary key. Blank lines and lines
+// starting with '#' are ignored so a key file can carry comments.
+func Parse(spec string) (*Keyring, error) {
+	k := &Keyring{}
+	seen := make(map[string]bool)
+
+	fields := strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == '\n' })
+	for _, field := range fields {
+		field = strings.TrimSpace(field)
+		if field == "" || strings.HasPrefix(field, "#") {
+			continue
+		}
+
+		id, encoded, ok := strings.Cut(field, ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("keyring: entry %q is not in id:base64key form", field)
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("keyring: duplicate key id %q", id)
+		}
+		seen[id] = true
+
+		raw, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("keyring: key %q is not valid base64: %w", id, err)
+		}
+		if len(raw) != keySize {
+			return nil, fmt.Errorf("keyring: key %q must be %d bytes, got %d", id, keySize, len(raw))
+		}
+
+		block, err := aes.NewCipher(raw)
+		if err != nil {
+			return nil, fmt.Errorf("keyring: key %q: %w", id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("keyring: key %q: %w", id, err)
+		}
+		k.keys = append(k.keys, key{id: id, aead: aead})
+	}
+
+	if len(k.keys) == 0 {
+		return nil, errors.New("keyring: no keys configured")
+	}
+	return k, nil
+}
+
+// GenerateKey returns a new random key in the "id:base64key" form accepted by
+// Parse.
+func GenerateKey(id string) (string, error) {
+	raw := make([]byte, keySize)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("keyring: generating key: %w", err)
+	}
+	return id + ":" + base64.StdEncoding.EncodeToString(raw), nil
+}
+
+// PrimaryID returns the id of the key used for encryption.
+func (k *Keyring) PrimaryID() string {
+	return k.keys[0].id
+}
+
+// Encrypt seals plaintext with the primary key. associatedData is bound to
+// the ciphertext and must be passed unchanged to Decrypt; callers use it to
+// tie a token to the row it belongs to.
+func (k *Keyring) Encrypt(plaintext, associatedData []byte) (string, error) {
+	primary := k.keys[0]
+	nonce := make([]byte, primary.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("keyring: generating nonce: %w", err)
+	}
+
+	sealed := primary.aead.Seal(nonce, nonce, plaintext, associatedData)
+	return fmt.Sprintf("%s:%s:%s", version, primary.id, base64.StdEncoding.EncodeToString(sealed)), nil
+}
+
+// Decrypt opens a ciphertext produced by Encrypt with any key in the keyring.
+func (k *Keyring) Decrypt(ciphertext string, associatedData []byte) ([]byte, error) {
+	id, payload, err := split(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, candidate := range k.keys {
+		if candidate.id != id {
+			continue
+		}
+		sealed, err := base64.StdEncoding.DecodeString(payload)
+		if err != nil {
+			return nil, fmt.Errorf("keyring: invalid ciphertext encoding: %w", err)
+		}
+		nonceSize := candidate.aead.NonceSize()
+		if len(sealed) < nonceSize {
+			return nil, errors.New("keyring: ciphertext too short")
+		}
+		plaintext, err := candidate.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], associatedData)
+		if err != nil {
+			return nil, fmt.Errorf("keyring: decrypting with key %q: %w", id, err)
+		}
+		return plaintext, nil
+	}
+	return nil, fmt.Errorf("%w %q", ErrUnknownKey, id)
+}
+
+// IsCurrent reports whether ciphertext was written with the primary key, i.e.
+// whether it can be left alone during re-encryption.
+func (k *Keyring) IsCurrent(ciphertext string) bool {
+	id, _, err := split(ciphertext)
+	return err == nil && id == k.keys[0].id
+}
+
+func split(ciphertext string) (id, payload string, err error) {
+	parts := strings.SplitN(ciphertext, ":", 3)
+	if len(parts) != 3 || parts[0] != version {
+		return "", "", errors.New("keyring: unsupported ciphertext format")
+	}
+	return parts[1], parts[2], nil
+}
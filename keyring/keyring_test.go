@@ -0,0 +1,175 @@
+package keyring
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// mustParse parses spec or fails the test.
+func mustParse(t *testing.T, spec string) *Keyring {
+	t.Helper()
+	k, err := Parse(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+// newKey generates a key entry with the given id.
+func newKey(t *testing.T, id string) string {
+	t.Helper()
+	key, err := GenerateKey(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestParse(t *testing.T) {
+	k1, k2 := newKey(t, "k1"), newKey(t, "k2")
+	short := "short:" + base64.StdEncoding.EncodeToString(make([]byte, 16))
+
+	tests := []struct {
+		name        string
+		spec        string
+		wantPrimary string
+		wantErr     string
+	}{
+		{name: "one key", spec: k1, wantPrimary: "k1"},
+		{name: "comma separated", spec: k2 + "," + k1, wantPrimary: "k2"},
+		{name: "key file", spec: "# rotated in March\n\n" + k1 + "\n  " + k2 + "  \n", wantPrimary: "k1"},
+		{name: "empty", spec: "", wantErr: "no keys"},
+		{name: "only comments", spec: "# nothing yet\n", wantErr: "no keys"},
+		{name: "no id", spec: ":" + strings.SplitN(k1, ":", 2)[1], wantErr: "id:base64key form"},
+		{name: "no separator", spec: "k1", wantErr: "id:base64key form"},
+		{name: "bad base64", spec: "k1:not*base64", wantErr: "not valid base64"},
+		{name: "short key", spec: short, wantErr: "must be 32 bytes, got 16"},
+		{name: "duplicate id", spec: k1 + "," + strings.Replace(k2, "k2:", "k1:", 1), wantErr: "duplicate key id"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, err := Parse(tt.spec)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Parse error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := k.PrimaryID(); got != tt.wantPrimary {
+				t.Errorf("primary key = %q, want %q", got, tt.wantPrimary)
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	k := mustParse(t, newKey(t, "k1"))
+	owner := []byte("owner-a")
+
+	ciphertext, err := k.Encrypt([]byte("liara-token"), owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(ciphertext, "v1:k1:") || strings.Contains(ciphertext, "liara-token") {
+		t.Errorf("ciphertext = %q, want v1:k1:<sealed>", ciphertext)
+	}
+	plaintext, err := k.Decrypt(ciphertext, owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plaintext) != "liara-token" {
+		t.Errorf("decrypted %q, want liara-token", plaintext)
+	}
+
+	again, err := k.Encrypt([]byte("liara-token"), owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again == ciphertext {
+		t.Error("encrypting twice gave the same ciphertext, want a fresh nonce")
+	}
+}
+
+func TestDecryptFailures(t *testing.T) {
+	k := mustParse(t, newKey(t, "k1"))
+	ciphertext, err := k.Encrypt([]byte("liara-token"), []byte("owner-a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := strings.TrimPrefix(ciphertext, "v1:k1:")
+	sealed, _ := base64.StdEncoding.DecodeString(payload)
+	sealed[len(sealed)-1] ^= 1
+	tampered := "v1:k1:" + base64.StdEncoding.EncodeToString(sealed)
+
+	tests := []struct {
+		name           string
+		ciphertext     string
+		associatedData string
+		wantUnknownKey bool
+	}{
+		{name: "another owner", ciphertext: ciphertext, associatedData: "owner-b"},
+		{name: "tampered", ciphertext: tampered, associatedData: "owner-a"},
+		{name: "unknown key", ciphertext: "v1:k9:" + payload, associatedData: "owner-a", wantUnknownKey: true},
+		{name: "other version", ciphertext: "v2:k1:" + payload, associatedData: "owner-a"},
+		{name: "not a ciphertext", ciphertext: "liara-token", associatedData: "owner-a"},
+		{name: "bad encoding", ciphertext: "v1:k1:***", associatedData: "owner-a"},
+		{name: "too short", ciphertext: "v1:k1:" + base64.StdEncoding.EncodeToString([]byte("short")), associatedData: "owner-a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plaintext, err := k.Decrypt(tt.ciphertext, []byte(tt.associatedData))
+			if err == nil {
+				t.Fatalf("Decrypt = %q, want an error", plaintext)
+			}
+			if got := errors.Is(err, ErrUnknownKey); got != tt.wantUnknownKey {
+				t.Errorf("errors.Is(%v, ErrUnknownKey) = %v, want %v", err, got, tt.wantUnknownKey)
+			}
+		})
+	}
+}
+
+func TestRotation(t *testing.T) {
+	oldKey, newKeyEntry := newKey(t, "old"), newKey(t, "new")
+	before := mustParse(t, oldKey)
+	ciphertext, err := before.Encrypt([]byte("liara-token"), []byte("owner-a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !before.IsCurrent(ciphertext) {
+		t.Error("IsCurrent = false for a ciphertext of the only key")
+	}
+
+	rotated := mustParse(t, newKeyEntry+","+oldKey)
+	if rotated.IsCurrent(ciphertext) {
+		t.Error("IsCurrent = true for a ciphertext of the old key after rotation")
+	}
+	if rotated.IsCurrent("not a ciphertext") {
+		t.Error("IsCurrent = true for a malformed ciphertext")
+	}
+	plaintext, err := rotated.Decrypt(ciphertext, []byte("owner-a"))
+	if err != nil || string(plaintext) != "liara-token" {
+		t.Fatalf("old ciphertext after rotation = %q, %v, want it still readable", plaintext, err)
+	}
+
+	reencrypted, err := rotated.Encrypt(plaintext, []byte("owner-a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(reencrypted, "v1:new:") || !rotated.IsCurrent(reencrypted) {
+		t.Errorf("re-encrypted = %q, want it written with the new key", reencrypted)
+	}
+
+	// Once the old key is dropped, only the re-encrypted value is readable.
+	newOnly := mustParse(t, newKeyEntry)
+	if _, err := newOnly.Decrypt(reencrypted, []byte("owner-a")); err != nil {
+		t.Errorf("re-encrypted value without the old key: %v", err)
+	}
+	if _, err := newOnly.Decrypt(ciphertext, []byte("owner-a")); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("old value without the old key: error %v, want ErrUnknownKey", err)
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lib/pq"
+
+	"scheduler/store"
+)
+
+// leaderLockKey is the Postgres advisory lock held by the replica running
+// the scheduler. It is arbitrary but must not change between versions, or
+// old and new replicas would not exclude each other; the store package uses
+// the next key for its schema.
+const leaderLockKey int64 = 0x6c69617261_01
+
+const (
+	defaultLeaderCheckInterval = 5 * time.Second
+	// stateReloadDelay gathers bursts of changes into one reload.
+	stateReloadDelay = 500 * time.Millisecond
+)
+
+var (
+	// isLeader is set while this replica runs the scheduler, the reconciler
+	// and the log janitor.
+	isLeader atomic.Bool
+	// electionDone is closed when the election loop has stopped.
+	electionDone = make(chan struct{})
+	// election takes and holds the leader lock; nil for stores other than
+	// Postgres.
+	election *elector
+)
+
+// elector takes an advisory lock on a connection of its own and holds it
+// for as long as the connection lives.
+type elector struct {
+	db  *sql.DB
+	key int64
+
+	mu sync.Mutex
+	// conn holds the lock; nil while following.
+	conn *sql.Conn
+}
+
+func newElector(db *sql.DB, key int64) *elector {
+	return &elector{db: db, key: key}
+}
+
+// holding reports whether the elector holds the lock.
+func (e *elector) holding() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.conn != nil
+}
+
+// acquire tries to take the lock and reports whether it did. It reports
+// false if the lock is held already, by this elector or another.
+func (e *elector) acquire(ctx context.Context) (bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.conn != nil {
+		return false, nil
+	}
+	conn, err := e.db.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", e.key).Scan(&acquired); err != nil || !acquired {
+		conn.Close()
+		return false, err
+	}
+	e.conn = conn
+	return true, nil
+}
+
+// check makes sure the lock's connection is still alive. It returns nil if
+// the lock is not held.
+func (e *elector) check(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.conn == nil {
+		return nil
+	}
+	_, err := e.conn.ExecContext(ctx, "SELECT 1")
+	return err
+}
+
+// release gives up the lock, if held, and closes its connection.
+func (e *elector) release(ctx context.Context) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.conn == nil {
+		return
+	}
+	if _, err := e.conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", e.key); err != nil {
+		// Discard the connection rather than return it to the pool, so the
+		// lock goes with its session.
+		e.conn.Raw(func(any) error { return driver.ErrBadConn })
+	}
+	e.conn.Close()
+	e.conn = nil
+}
+
+// leaderCheckInterval reads LEADER_CHECK_INTERVAL, how often followers try to
+// take over and the leader checks that it still holds the lock.
+func leaderCheckInterval() time.Duration {
+	value := os.Getenv("LEADER_CHECK_INTERVAL")
+	if value == "" {
+		return defaultLeaderCheckInterval
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("Invalid LEADER_CHECK_INTERVAL %q, using %s", value, defaultLeaderCheckInterval)
+		return defaultLeaderCheckInterval
+	}
+	return d
+}
+
+// role names the replica's part for /readyz.
+func role() string {
+	if isLeader.Load() {
+		return "leader"
+	}
+	return "follower"
+}
+
+// startLeaderElection makes this replica the leader if it can take the
+// leader lock, and otherwise keeps trying every interval until shutdown.
+// Stores other than Postgres serve a single replica, which leads.
+func startLeaderElection(interval time.Duration) {
+	if db == nil {
+		becomeLeader()
+		close(electionDone)
+		return
+	}
+	election = newElector(db, leaderLockKey)
+
+	// The first attempt is made before serving, so a lone replica starts
+	// with its schedules running.
+	if checkLeadership() {
+		becomeLeader()
+	} else {
+		log.Println("Another replica is the leader, following.")
+	}
+
+	go func() {
+		defer close(electionDone)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-shuttingDown:
+				return
+			case <-ticker.C:
+				if checkLeadership() {
+					// What this replica followed may be a few
+					// changes behind.
+					reloadState()
+					becomeLeader()
+				}
+			}
+		}
+	}()
+}
+
+// checkLeadership makes sure the leader lock's connection is still alive as
+// the leader, or tries to take the lock as a follower. It reports whether a
+// follower took the lock.
+func checkLeadership() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), readinessTimeout)
+	defer cancel()
+
+	if election.holding() {
+		if err := election.check(ctx); err != nil {
+			log.Printf("Lost the leader lock: %v", err)
+			resignLeadership()
+		}
+		return false
+	}
+
+	acquired, err := election.acquire(ctx)
+	if err != nil {
+		log.Printf("Error trying the leader lock: %v", err)
+	}
+	return acquired
+}
+
+func becomeLeader() {
+	isLeader.Store(true)
+	scheduler.Start()
+	schedulerStarted.Store(true)
+	log.Println("This replica is the leader and runs the schedules.")
+}
+
+// resignLeadership stops the scheduler and releases the leader lock. Jobs
+// already running finish on their own.
+func resignLeadership() {
+	if isLeader.Swap(false) {
+		scheduler.Stop()
+		schedulerStarted.Store(false)
+	}
+	if election == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), readinessTimeout)
+	defer cancel()
+	election.release(ctx)
+}
+
+// startStateSync reloads the in-memory state whenever another replica, or
+// this one, changes it in the Postgres store.
+func startStateSync(connStr string) {
+	listener := pq.NewListener(connStr, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("State change listener: %v", err)
+		}
+	})
+	if err := listener.Listen(store.StateChannel); err != nil {
+		log.Printf("Error listening for state changes, replicas will not see each other's changes: %v", err)
+		listener.Close()
+		return
+	}
+
+	go func() {
+		for range listener.Notify {
+			// A nil notification after a reconnect means changes may
+			// have been missed; reload either way.
+			timer := time.NewTimer(stateReloadDelay)
+		gather:
+			for {
+				select {
+				case <-listener.Notify:
+				case <-timer.C:
+					break gather
+				}
+			}
+			reloadState()
+		}
+	}()
+}
+
+// reloadState replaces the in-memory state with what the store holds.
+func reloadState() {
+	loadHolidays()
+	loadAccounts()
+	loadSchedules()
+	loadDeadLetters()
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"math/rand/v2"
+	"os"
+	"sync"
+	"testing"
+)
+
+// openTestDB connects to TEST_DATABASE_URL, skipping the test without it.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	connStr := os.Getenv("TEST_DATABASE_URL")
+	if connStr == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	db, err := sql.Open("postgres", connStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestLeaderTakeover(t *testing.T) {
+	// Each elector has a pool of its own, as replicas do, and a key of its
+	// own so a scheduler running on the same database is not disturbed.
+	key := rand.Int64()
+	electors := []*elector{newElector(openTestDB(t), key), newElector(openTestDB(t), key)}
+	ctx := context.Background()
+	t.Cleanup(func() {
+		for _, e := range electors {
+			e.release(ctx)
+		}
+	})
+
+	acquired := make([]bool, len(electors))
+	var wg sync.WaitGroup
+	for i, e := range electors {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var err error
+			if acquired[i], err = e.acquire(ctx); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if acquired[0] == acquired[1] {
+		t.Fatalf("electors took the lock %v, want exactly one", acquired)
+	}
+	leader, follower := electors[0], electors[1]
+	if acquired[1] {
+		leader, follower = follower, leader
+	}
+
+	if !leader.holding() || follower.holding() {
+		t.Fatal("holding disagrees with who took the lock")
+	}
+	if err := leader.check(ctx); err != nil {
+		t.Fatalf("check on the leader: %v", err)
+	}
+	if ok, err := follower.acquire(ctx); ok || err != nil {
+		t.Fatalf("follower acquire = %v, %v while the leader holds the lock", ok, err)
+	}
+
+	// The leader's session ends, as when its database connection drops.
+	var pid int
+	if err := leader.conn.QueryRowContext(ctx, "SELECT pg_backend_pid()").Scan(&pid); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := follower.db.ExecContext(ctx, "SELECT pg_terminate_backend($1)", pid); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := leader.check(ctx); err == nil {
+		t.Fatal("check on the leader succeeded after its connection was closed")
+	}
+	leader.release(ctx)
+	if leader.holding() {
+		t.Error("leader still holds the lock after release")
+	}
+
+	if ok, err := follower.acquire(ctx); !ok || err != nil {
+		t.Fatalf("follower acquire = %v, %v after the leader's connection closed, want it to take over", ok, err)
+	}
+	if ok, err := leader.acquire(ctx); ok || err != nil {
+		t.Errorf("old leader acquire = %v, %v after the takeover, want it to follow", ok, err)
+	}
+}
@@ -0,0 +1,245 @@
+// Package liara is a small client for the parts of the Liara.ir API used by
+// the scheduler: listing projects and databases and scaling them.
+package liara
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// IranBaseURL is the API endpoint for the Iran region.
+	IranBaseURL = "https://api.iran.liara.ir"
+	// GermanyBaseURL is the API endpoint for the Germany region.
+	GermanyBaseURL = "https://api.liara.ir"
+
+	defaultTimeout   = 10 * time.Second
+	defaultUserAgent = "liara-scheduler"
+)
+
+// BaseURLForRegion maps a region name ("iran" or "germany") to its API base URL.
+func BaseURLForRegion(region string) (string, error) {
+	switch strings.ToLower(region) {
+	case "", "iran":
+		return IranBaseURL, nil
+	case "germany":
+		return GermanyBaseURL, nil
+	default:
+		return "", fmt.Errorf("unknown Liara region %q", region)
+	}
+}
+
+// Client talks to the Liara API. The token is passed per call because a
+// single client is shared between all users of the scheduler.
+type Client struct {
+	baseURL    string
+	userAgent  string
+	httpClient *http.Client
+	observe    Observer
+}
+
+// Observer is told about every request the client sends. endpoint is the
+// request path with IDs replaced by placeholders, and status is 0 if no
+// response was received.
+type Observer func(endpoint string, status int, elapsed time.Duration)
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithBaseURL overrides the API base URL, e.g. for another region or a local
+// stand-in server.
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		c.baseURL = strings.TrimRight(baseURL, "/")
+	}
+}
+
+// WithTimeout sets the timeout applied to every request.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.httpClient.Timeout = timeout
+	}
+}
+
+// WithTransport sets the RoundTripper used for requests.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(c *Client) {
+		c.httpClient.Transport = transport
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request.
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
+
+// WithObserver sets a function called after every request, e.g. to record
+// metrics.
+func WithObserver(observe Observer) Option {
+	return func(c *Client) {
+		c.observe = observe
+	}
+}
+
+// NewClient returns a Client for the Iran region unless configured otherwise.
+func NewClient(opts ...Option) *Client {
+	c := &Client{
+		baseURL:    IranBaseURL,
+		userAgent:  defaultUserAgent,
+		httpClient: &http.Client{Timeout: defaultTimeout},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// BaseURL returns the API base URL the client sends requests to.
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
+// Response is the raw result of an API call.
+type Response struct {
+	StatusCode int
+	Body       []byte
+}
+
+// APIError is returned when the API answers with a non-200 status.
+type APIError struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API failed with status: %d", e.StatusCode)
+}
+
+// GetProjects lists the projects of the account owning token.
+func (c *Client) GetProjects(ctx context.Context, token string) ([]Project, error) {
+	var projectsResponse ProjectsResponse
+	if err := c.getJSON(ctx, token, "/v1/projects", "/v1/projects", &projectsResponse); err != nil {
+		return nil, err
+	}
+	return projectsResponse.Projects, nil
+}
+
+// GetDatabases lists the databases of the account owning token.
+func (c *Client) GetDatabases(ctx context.Context, token string) ([]Database, error) {
+	var databasesResponse DatabasesResponse
+	if err := c.getJSON(ctx, token, "/v1/databases", "/v1/databases", &databasesResponse); err != nil {
+		return nil, err
+	}
+	return databasesResponse.Databases, nil
+}
+
+// Ping checks that the API answers at all. Any HTTP response counts, since
+// the API requires a token for everything else.
+func (c *Client) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL, nil)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("API error: %w", err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// ScaleProject sets the scale of a project; 0 turns it off, 1 turns it on.
+func (c *Client) ScaleProject(ctx context.Context, token, projectID string, scale int) (*Response, error) {
+	id, err := pathSegment(projectID)
+	if err != nil {
+		return nil, err
+	}
+	return c.scale(ctx, token, "/v1/projects/{id}/actions/scale", "/v1/projects/"+id+"/actions/scale", scale)
+}
+
+// ScaleDatabase sets the scale of a database; 0 turns it off, 1 turns it on.
+func (c *Client) ScaleDatabase(ctx context.Context, token, databaseID string, scale int) (*Response, error) {
+	id, err := pathSegment(databaseID)
+	if err != nil {
+		return nil, err
+	}
+	return c.scale(ctx, token, "/v1/databases/{id}/actions/scale", "/v1/databases/"+id+"/actions/scale", scale)
+}
+
+// pathSegment escapes id for use as one segment of a request path, so that
+// an ID containing "/" or "?" cannot reach another endpoint. "." and "..",
+// which would still move up the path, are refused.
+func pathSegment(id string) (string, error) {
+	if id == "" || id == "." || id == ".." {
+		return "", fmt.Errorf("invalid ID %q", id)
+	}
+	return url.PathEscape(id), nil
+}
+
+func (c *Client) scale(ctx context.Context, token, endpoint, path string, scale int) (*Response, error) {
+	jsonBody, err := json.Marshal(map[string]int{"scale": scale})
+	if err != nil {
+		return nil, fmt.Errorf("error encoding request: %w", err)
+	}
+	return c.do(ctx, token, http.MethodPost, endpoint, path, bytes.NewReader(jsonBody))
+}
+
+func (c *Client) getJSON(ctx context.Context, token, endpoint, path string, v any) error {
+	resp, err := c.do(ctx, token, http.MethodGet, endpoint, path, nil)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(resp.Body, v); err != nil {
+		return fmt.Errorf("error decoding response: %w", err)
+	}
+	return nil
+}
+
+// do sends a request and returns the response body. Non-200 answers are
+// reported as *APIError alongside the response. endpoint names the request
+// for the observer.
+func (c *Client) do(ctx context.Context, token, method, endpoint, path string, body io.Reader) (*Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", c.userAgent)
+
+	start := time.Now()
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		if c.observe != nil {
+			c.observe(endpoint, 0, time.Since(start))
+		}
+		return nil, fmt.Errorf("API error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if c.observe != nil {
+		c.observe(endpoint, resp.StatusCode, time.Since(start))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading response: %w", err)
+	}
+
+	result := &Response{StatusCode: resp.StatusCode, Body: respBody}
+	if resp.StatusCode != http.StatusOK {
+		return result, &APIError{StatusCode: resp.StatusCode, Body: respBody}
+	}
+	return result, nil
+}
@@ -0,0 +1,220 @@
+package liara
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBaseURLForRegion(t *testing.T) {
+	tests := []struct {
+		region  string
+		want    string
+		wantErr bool
+	}{
+		{region: "", want: IranBaseURL},
+		{region: "iran", want: IranBaseURL},
+		{region: "Germany", want: GermanyBaseURL},
+		{region: "mars", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := BaseURLForRegion(tt.region)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("BaseURLForRegion(%q) = %q, %v, want %q, error %v", tt.region, got, err, tt.want, tt.wantErr)
+		}
+	}
+
+	if got := NewClient().BaseURL(); got != IranBaseURL {
+		t.Errorf("default base URL = %q, want %q", got, IranBaseURL)
+	}
+	if got := NewClient(WithBaseURL("http://127.0.0.1:8080/")).BaseURL(); got != "http://127.0.0.1:8080" {
+		t.Errorf("base URL = %q, want the trailing slash trimmed", got)
+	}
+}
+
+// request is what the test server saw of one request.
+type request struct {
+	method, path, authorization, userAgent string
+	scale                                  map[string]int
+}
+
+// testServer answers every request with status and body and records what it
+// received.
+func testServer(t *testing.T, status int, body string) (*Client, *[]request) {
+	t.Helper()
+	var received []request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := request{method: r.Method, path: r.URL.EscapedPath(), authorization: r.Header.Get("Authorization"),
+			userAgent: r.Header.Get("User-Agent")}
+		if r.Method == http.MethodPost {
+			json.NewDecoder(r.Body).Decode(&req.scale)
+		}
+		received = append(received, req)
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return NewClient(WithBaseURL(srv.URL), WithUserAgent("test-agent")), &received
+}
+
+func TestScale(t *testing.T) {
+	tests := []struct {
+		name     string
+		scale    func(c *Client) (*Response, error)
+		wantPath string
+		want     int
+	}{
+		{
+			name:     "project",
+			scale:    func(c *Client) (*Response, error) { return c.ScaleProject(context.Background(), "secret", "app", 1) },
+			wantPath: "/v1/projects/app/actions/scale",
+			want:     1,
+		},
+		{
+			name:     "database",
+			scale:    func(c *Client) (*Response, error) { return c.ScaleDatabase(context.Background(), "secret", "db", 0) },
+			wantPath: "/v1/databases/db/actions/scale",
+		},
+		{
+			name: "slash and query",
+			scale: func(c *Client) (*Response, error) {
+				return c.ScaleProject(context.Background(), "secret", "app/actions/delete?force=1", 0)
+			},
+			wantPath: "/v1/projects/app%2Factions%2Fdelete%3Fforce=1/actions/scale",
+		},
+		{
+			name: "dot segments",
+			scale: func(c *Client) (*Response, error) {
+				return c.ScaleDatabase(context.Background(), "secret", "../projects/app", 0)
+			},
+			wantPath: "/v1/databases/..%2Fprojects%2Fapp/actions/scale",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, received := testServer(t, http.StatusOK, `{}`)
+			resp, err := tt.scale(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != http.StatusOK || string(resp.Body) != `{}` {
+				t.Errorf("response = %d %q, want 200 {}", resp.StatusCode, resp.Body)
+			}
+			if len(*received) != 1 {
+				t.Fatalf("server received %d requests, want 1", len(*received))
+			}
+			got := (*received)[0]
+			if got.method != http.MethodPost || got.path != tt.wantPath {
+				t.Errorf("request = %s %s, want POST %s", got.method, got.path, tt.wantPath)
+			}
+			if got.authorization != "Bearer secret" || got.userAgent != "test-agent" {
+				t.Errorf("headers = Authorization %q, User-Agent %q", got.authorization, got.userAgent)
+			}
+			if got.scale["scale"] != tt.want {
+				t.Errorf("scale = %v, want %d", got.scale, tt.want)
+			}
+		})
+	}
+}
+
+func TestScaleRefusesDotIDs(t *testing.T) {
+	c, received := testServer(t, http.StatusOK, `{}`)
+	for _, id := range []string{"", ".", ".."} {
+		if _, err := c.ScaleProject(context.Background(), "secret", id, 0); err == nil {
+			t.Errorf("ScaleProject(%q) succeeded, want an error", id)
+		}
+	}
+	if len(*received) != 0 {
+		t.Errorf("server received %d requests, want none", len(*received))
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	c, _ := testServer(t, http.StatusServiceUnavailable, `{"message":"down"}`)
+
+	resp, err := c.ScaleProject(context.Background(), "secret", "app", 1)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("error = %v, want an *APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusServiceUnavailable || string(apiErr.Body) != `{"message":"down"}` {
+		t.Errorf("APIError = %d %q", apiErr.StatusCode, apiErr.Body)
+	}
+	if resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("response = %+v, want the 503 alongside the error", resp)
+	}
+
+	if _, err := c.GetProjects(context.Background(), "secret"); !errors.As(err, &apiErr) {
+		t.Errorf("GetProjects error = %v, want an *APIError", err)
+	}
+}
+
+func TestGetProjectsAndDatabases(t *testing.T) {
+	c, received := testServer(t, http.StatusOK,
+		`{"projects":[{"project_id":"app","scale":1,"planID":"small"}],"databases":[{"DBId":"db","hourlyPrice":1250}]}`)
+
+	projects, err := c.GetProjects(context.Background(), "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(projects) != 1 || projects[0].ProjectID != "app" || projects[0].Scale != 1 || projects[0].PlanID != "small" {
+		t.Errorf("projects = %+v", projects)
+	}
+	databases, err := c.GetDatabases(context.Background(), "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(databases) != 1 || databases[0].DBId != "db" || databases[0].HourlyPrice != 1250 {
+		t.Errorf("databases = %+v", databases)
+	}
+
+	for i, want := range []string{"/v1/projects", "/v1/databases"} {
+		if got := (*received)[i]; got.method != http.MethodGet || got.path != want || got.authorization != "Bearer secret" {
+			t.Errorf("request %d = %s %s with %q, want GET %s with the token", i, got.method, got.path, got.authorization, want)
+		}
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	var observed []int
+	c := NewClient(WithBaseURL(srv.URL), WithTimeout(50*time.Millisecond),
+		WithObserver(func(endpoint string, status int, elapsed time.Duration) { observed = append(observed, status) }))
+	start := time.Now()
+	_, err := c.ScaleDatabase(context.Background(), "secret", "db", 1)
+	if err == nil || !strings.Contains(err.Error(), "API error") {
+		t.Fatalf("error = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("request took %s despite the 50ms timeout", elapsed)
+	}
+	if len(observed) != 1 || observed[0] != 0 {
+		t.Errorf("observed statuses = %v, want [0] for no response", observed)
+	}
+}
+
+func TestObserverEndpoint(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	var endpoints []string
+	c := NewClient(WithBaseURL(srv.URL),
+		WithObserver(func(endpoint string, status int, elapsed time.Duration) { endpoints = append(endpoints, endpoint) }))
+	c.ScaleProject(context.Background(), "secret", "app", 1)
+	c.ScaleDatabase(context.Background(), "secret", "db", 1)
+	if len(endpoints) != 2 || endpoints[0] != "/v1/projects/{id}/actions/scale" || endpoints[1] != "/v1/databases/{id}/actions/scale" {
+		t.Errorf("endpoints = %v, want the paths without IDs", endpoints)
+	}
+}
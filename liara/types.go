@@ -0,0 +1,47 @@
+package liara
+
+type Project struct {
+	ID        string `json:"_id"`
+	ProjectID string `json:"project_id"`
+	Type      string `json:"type"`
+	Status    string `json:"status"`
+	Scale     int    `json:"scale"`
+	PlanID    string `json:"planID"`
+	CreatedAt string `json:"created_at"`
+}
+
+type ProjectsResponse struct {
+	Projects []Project `json:"projects"`
+}
+
+type Database struct {
+	DBId          string `json:"DBId"`
+	Type          string `json:"type"`
+	PlanID        string `json:"planID"`
+	Status        string `json:"status"`
+	Scale         int    `json:"scale"`
+	Hostname      string `json:"hostname"`
+	PublicNetwork bool   `json:"publicNetwork"`
+	Version       string `json:"version"`
+	VolumeSize    int    `json:"volumeSize"`
+	CreatedAt     string `json:"created_at"`
+	DBName        string `json:"dbName"`
+	Node          struct {
+		ID   string `json:"_id"`
+		Host string `json:"host"`
+	} `json:"node"`
+	Port         int    `json:"port"`
+	RootPassword string `json:"root_password"`
+	InternalPort int    `json:"internalPort"`
+	ID           string `json:"id"`
+	HourlyPrice  int    `json:"hourlyPrice"`
+	MetaData     struct {
+		StandaloneReplicaSet bool `json:"standaloneReplicaSet"`
+		PrivateNetwork       bool `json:"privateNetwork"`
+	} `json:"metaData"`
+	Username string `json:"username"`
+}
+
+type DatabasesResponse struct {
+	Databases []Database `json:"databases"`
+}
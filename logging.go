@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+
+	"scheduler/store"
+)
+
+// Attribute keys shared by all loggers.
+const (
+	logKeyAccount   = "account"   // owner; records carrying it are audit entries of that account
+	logKeySchedule  = "schedule"  // schedule ID
+	logKeyExecution = "execution" // execution ID
+	logKeyTarget    = "target"    // name of the project or database acted on
+)
+
+const loggerContextKey contextKey = "logger"
+
+// setupLogging sends all server logs, including those of the standard log
+// package, to stdout through auditHandler.
+func setupLogging() {
+	slog.SetDefault(slog.New(&auditHandler{next: slog.NewTextHandler(os.Stdout, nil)}))
+}
+
+// accountLogger returns a logger whose records are also kept in the owner's
+// log store.
+func accountLogger(owner string) *slog.Logger {
+	return slog.Default().With(logKeyAccount, owner)
+}
+
+// loggerFromContext returns the request logger set by authMiddleware, or the
+// server logger outside authenticated requests.
+func loggerFromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerContextKey).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// auditHandler writes every record to the server log and copies records that
+// carry an account to that account's log store, so what an account sees does
+// not depend on who logged in last.
+type auditHandler struct {
+	next    slog.Handler
+	account string
+	attrs   []slog.Attr // added with WithAttrs, other than the account
+	group   string
+}
+
+func (h *auditHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *auditHandler) Handle(ctx context.Context, r slog.Record) error {
+	err := h.next.Handle(ctx, r)
+
+	entry := LogEntry{Owner: h.account, Timestamp: r.Time, Level: r.Level.String(), Message: r.Message}
+	for _, a := range h.attrs {
+		addLogAttr(&entry, a)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == logKeyAccount && h.group == "" {
+			entry.Owner = a.Value.String()
+		} else {
+			addLogAttr(&entry, a)
+		}
+		return true
+	})
+	if entry.Owner != "" {
+		storeLogEntry(entry)
+	}
+	return err
+}
+
+func (h *auditHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.next = h.next.WithAttrs(attrs)
+	clone.attrs = append([]slog.Attr(nil), h.attrs...)
+	for _, a := range attrs {
+		if a.Key == logKeyAccount && h.group == "" {
+			clone.account = a.Value.String()
+			continue
+		}
+		clone.attrs = append(clone.attrs, a)
+	}
+	return &clone
+}
+
+func (h *auditHandler) WithGroup(name string) slog.Handler {
+	clone := *h
+	clone.next = h.next.WithGroup(name)
+	clone.group = strings.TrimPrefix(h.group+"."+name, ".")
+	return &clone
+}
+
+// LogEntry is one audit entry of an account. The well-known attributes have
+// fields of their own so they can be filtered on; the rest go into Attrs.
+type LogEntry = store.LogEntry
+
+func addLogAttr(e *LogEntry, a slog.Attr) {
+	value := a.Value.Resolve().String()
+	switch a.Key {
+	case logKeySchedule:
+		e.ScheduleID = value
+	case logKeyExecution:
+		e.ExecutionID = value
+	case logKeyTarget:
+		e.Target = value
+	default:
+		if e.Attrs == nil {
+			e.Attrs = make(map[string]string)
+		}
+		e.Attrs[a.Key] = value
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"scheduler/store"
+)
+
+const (
+	defaultLogsLimit = 100
+	maxLogsLimit     = 1000
+
+	// logQueueSize is how many entries may wait for the store before loggers
+	// block.
+	logQueueSize = 1024
+	// logWriteBatch is the most entries written at once.
+	logWriteBatch = 100
+)
+
+var (
+	// logQueue feeds entries to the store writer; nil before the store is
+	// open and once the writer has been flushed.
+	logQueue      chan LogEntry
+	logQueueMu    sync.RWMutex // guards logQueue
+	logWriterDone chan struct{}
+)
+
+// storeLogEntry queues an entry for the store, or writes it at once when the
+// writer is not running. It must not log to an account logger.
+func storeLogEntry(entry LogEntry) {
+	logQueueMu.RLock()
+	if logQueue != nil {
+		logQueue <- entry
+		logQueueMu.RUnlock()
+		return
+	}
+	logQueueMu.RUnlock()
+	writeLogEntries([]LogEntry{entry})
+}
+
+// startLogWriter starts writing queued entries to the store in batches.
+func startLogWriter() {
+	logQueue = make(chan LogEntry, logQueueSize)
+	logWriterDone = make(chan struct{})
+	go func(queue <-chan LogEntry) {
+		defer close(logWriterDone)
+		batch := make([]LogEntry, 0, logWriteBatch)
+		for entry := range queue {
+			batch = append(batch[:0], entry)
+			// Take whatever else is already waiting.
+		fill:
+			for len(batch) < logWriteBatch {
+				select {
+				case entry, ok := <-queue:
+					if !ok {
+						break fill
+					}
+					batch = append(batch, entry)
+				default:
+					break fill
+				}
+			}
+			writeLogEntries(batch)
+		}
+	}(logQueue)
+}
+
+// flushLogs stops the store writer once it has written every queued
+// entry; later entries are written synchronously.
+func flushLogs(ctx context.Context) error {
+	logQueueMu.Lock()
+	if logQueue == nil {
+		logQueueMu.Unlock()
+		return nil
+	}
+	close(logQueue)
+	logQueue = nil
+	logQueueMu.Unlock()
+
+	select {
+	case <-logWriterDone:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// writeLogEntries writes entries to the store. Entries logged before the
+// store is open only reach the server log.
+func writeLogEntries(entries []LogEntry) {
+	if dataStore == nil {
+		return
+	}
+	if err := dataStore.AppendLogs(entries); err != nil {
+		slog.Error("Error writing logs to store", "error", err, "entries", len(entries))
+	}
+}
+
+type LogsResponse struct {
+	Logs []LogEntry `json:"logs"`
+	// NextCursor fetches the next, older page; empty on the last page.
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// parseLogFilter reads the filters from the query string: level (the minimum
+// level), schedule, target, from and to (RFC 3339), q (text search), cursor
+// and limit.
+func parseLogFilter(r *http.Request, owner string) (store.LogFilter, error) {
+	query := r.URL.Query()
+	f := store.LogFilter{Owner: owner, ScheduleID: query.Get("schedule"), Target: query.Get("target"), Text: query.Get("q"), Limit: defaultLogsLimit}
+
+	if v := query.Get("level"); v != "" {
+		if err := f.MinLevel.UnmarshalText([]byte(v)); err != nil {
+			return f, fmt.Errorf("level must be one of debug, info, warn and error")
+		}
+		f.LevelSet = true
+	}
+	for _, bound := range []struct {
+		name string
+		t    *time.Time
+	}{{"from", &f.From}, {"to", &f.To}} {
+		if v := query.Get(bound.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return f, fmt.Errorf("%s must be an RFC 3339 time", bound.name)
+			}
+			*bound.t = t
+		}
+	}
+	if v := query.Get("cursor"); v != "" {
+		before, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || before <= 0 {
+			return f, fmt.Errorf("invalid cursor")
+		}
+		f.Before = before
+	}
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxLogsLimit {
+			return f, fmt.Errorf("limit must be between 1 and %d", maxLogsLimit)
+		}
+		f.Limit = limit
+	}
+	return f, nil
+}
+
+// logsHandler returns the account's log entries, newest first, as a JSON page.
+// format=ndjson and format=text export every matching entry instead, oldest
+// first.
+func logsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	owner, err := getOwnerFromContext(r.Context())
+	if err != nil {
+		http.Error(w, `{"error": "Authentication required"}`, http.StatusUnauthorized)
+		return
+	}
+
+	filter, err := parseLogFilter(r, owner)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Invalid query: %v"}`, err), http.StatusBadRequest)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	switch format {
+	case "":
+		format = "json"
+	case "json":
+	case "ndjson", "text":
+		filter.Limit = 0
+		filter.Before = 0
+	default:
+		http.Error(w, `{"error": "Invalid query: format must be json, ndjson or text"}`, http.StatusBadRequest)
+		return
+	}
+
+	entries, err := dataStore.Logs(filter, format == "json")
+	if err != nil {
+		loggerFromContext(r.Context()).Error("Error querying logs", "error", err)
+		http.Error(w, `{"error": "Failed to fetch logs"}`, http.StatusInternalServerError)
+		return
+	}
+
+	switch format {
+	case "ndjson":
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		encoder := json.NewEncoder(w)
+		for _, entry := range entries {
+			encoder.Encode(entry)
+		}
+	case "text":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, entry := range entries {
+			fmt.Fprintln(w, entry.String())
+		}
+	default:
+		response := LogsResponse{Logs: entries}
+		if filter.Limit > 0 && len(entries) == filter.Limit {
+			response.NextCursor = strconv.FormatInt(entries[len(entries)-1].ID, 10)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
@@ -0,0 +1,278 @@
+// Package mailer sends email through an SMTP server, over implicit TLS,
+// STARTTLS or, for local stand-ins, plain text.
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Security is how the connection to the SMTP server is protected.
+type Security string
+
+const (
+	// StartTLS upgrades a plain connection with STARTTLS and fails if the
+	// server does not offer it. Usually on port 587.
+	StartTLS Security = "starttls"
+	// TLS connects over TLS from the start. Usually on port 465.
+	TLS Security = "tls"
+	// None sends everything in plain text. Only for servers on the same
+	// host or a local stand-in; credentials are refused over it except to
+	// localhost.
+	None Security = "none"
+)
+
+const defaultTimeout = 30 * time.Second
+
+// ParseSecurity parses "starttls", "tls" or "none"; "" means StartTLS.
+func ParseSecurity(s string) (Security, error) {
+	switch Security(strings.ToLower(s)) {
+	case "", StartTLS:
+		return StartTLS, nil
+	case TLS:
+		return TLS, nil
+	case None:
+		return None, nil
+	default:
+		return "", fmt.Errorf("unknown SMTP security %q (available: starttls, tls, none)", s)
+	}
+}
+
+// DefaultPort is the usual port of an SMTP server using security.
+func (s Security) DefaultPort() int {
+	switch s {
+	case TLS:
+		return 465
+	case None:
+		return 25
+	default:
+		return 587
+	}
+}
+
+// Config describes the SMTP server and the sender.
+type Config struct {
+	Host     string
+	Port     int // 0 for the default port of Security
+	Security Security
+	// Username and Password authenticate with PLAIN auth; no auth is
+	// attempted without a username.
+	Username string
+	Password string
+	From     string        // address the mail is sent from, optionally with a name
+	Timeout  time.Duration // per message; 0 means 30s
+	// TLSConfig, if set, is used for TLS and STARTTLS instead of one that
+	// only sets the server name; Host is used if it sets none.
+	TLSConfig *tls.Config
+}
+
+// Message is an email with a plain-text body and, optionally, an HTML
+// alternative.
+type Message struct {
+	To      []string
+	Subject string
+	Text    string
+	HTML    string // empty for text only
+}
+
+// Sender sends messages with one new connection per message.
+type Sender struct {
+	cfg  Config
+	from *mail.Address
+}
+
+// NewSender checks cfg and returns a Sender using it.
+func NewSender(cfg Config) (*Sender, error) {
+	if cfg.Host == "" {
+		return nil, errors.New("no SMTP host")
+	}
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid from address %q: %w", cfg.From, err)
+	}
+	if cfg.Security == "" {
+		cfg.Security = StartTLS
+	}
+	if cfg.Port == 0 {
+		cfg.Port = cfg.Security.DefaultPort()
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	return &Sender{cfg: cfg, from: from}, nil
+}
+
+// Send delivers msg to the server, which takes it from there.
+func (s *Sender) Send(ctx context.Context, msg Message) error {
+	if len(msg.To) == 0 {
+		return errors.New("no recipients")
+	}
+	data, err := s.compose(msg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+	defer cancel()
+	addr := net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port))
+	dialer := &net.Dialer{}
+	var conn net.Conn
+	if s.cfg.Security == TLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: s.tlsConfig()}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("connecting to SMTP server: %w", err)
+	}
+	// net/smtp knows nothing of contexts; the deadline bounds the whole
+	// conversation instead.
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, s.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("SMTP greeting: %w", err)
+	}
+	defer c.Close()
+
+	if s.cfg.Security == StartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return errors.New("SMTP server does not offer STARTTLS")
+		}
+		if err := c.StartTLS(s.tlsConfig()); err != nil {
+			return fmt.Errorf("SMTP STARTTLS: %w", err)
+		}
+	}
+	if s.cfg.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)); err != nil {
+			return fmt.Errorf("SMTP auth: %w", err)
+		}
+	}
+
+	if err := c.Mail(s.from.Address); err != nil {
+		return fmt.Errorf("SMTP MAIL FROM: %w", err)
+	}
+	for _, to := range msg.To {
+		if err := c.Rcpt(to); err != nil {
+			return fmt.Errorf("SMTP RCPT TO %s: %w", to, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("SMTP DATA: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return fmt.Errorf("SMTP DATA: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("SMTP DATA: %w", err)
+	}
+	return c.Quit()
+}
+
+// tlsConfig returns the TLS configuration with the server name set, which
+// STARTTLS, unlike dialing, does not fill in.
+func (s *Sender) tlsConfig() *tls.Config {
+	if s.cfg.TLSConfig == nil {
+		return &tls.Config{ServerName: s.cfg.Host}
+	}
+	cfg := s.cfg.TLSConfig.Clone()
+	if cfg.ServerName == "" {
+		cfg.ServerName = s.cfg.Host
+	}
+	return cfg
+}
+
+// compose renders msg as a MIME message with CRLF line endings.
+func (s *Sender) compose(msg Message, now time.Time) ([]byte, error) {
+	for _, to := range msg.To {
+		if strings.ContainsAny(to, "\r\n") {
+			return nil, fmt.Errorf("invalid recipient %q", to)
+		}
+	}
+
+	var b bytes.Buffer
+	header := func(key, value string) {
+		fmt.Fprintf(&b, "%s: %s\r\n", key, value)
+	}
+	header("From", s.from.String())
+	header("To", strings.Join(msg.To, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", now.Format(time.RFC1123Z))
+	header("Message-ID", messageID(s.from.Address))
+	header("MIME-Version", "1.0")
+
+	if msg.HTML == "" {
+		header("Content-Type", "text/plain; charset=utf-8")
+		header("Content-Transfer-Encoding", "quoted-printable")
+		b.WriteString("\r\n")
+		if err := writeQuotedPrintable(&b, msg.Text); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), nil
+	}
+
+	mw := multipart.NewWriter(&b)
+	header("Content-Type", "multipart/alternative; boundary="+mw.Boundary())
+	b.WriteString("\r\n")
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQuotedPrintable(pw, part.body); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// writeQuotedPrintable writes text with CRLF line endings, as SMTP expects.
+func writeQuotedPrintable(w io.Writer, text string) error {
+	qp := quotedprintable.NewWriter(w)
+	text = strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\n", "\r\n")
+	if _, err := qp.Write([]byte(text)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// messageID returns a unique Message-ID in the sender's domain.
+func messageID(from string) string {
+	domain := "localhost"
+	if at := strings.LastIndexByte(from, '@'); at >= 0 {
+		domain = from[at+1:]
+	}
+	random := make([]byte, 12)
+	rand.Read(random)
+	return fmt.Sprintf("<%d.%s@%s>", time.Now().UnixNano(), hex.EncodeToString(random), domain)
+}
@@ -0,0 +1,283 @@
+package mailer
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSMTP is an SMTP server that accepts every message and remembers the
+// commands it got.
+type fakeSMTP struct {
+	ln        net.Listener
+	tlsConfig *tls.Config
+	offerTLS  bool // offer STARTTLS on the plain connection
+
+	mu       sync.Mutex
+	commands []string
+	auth     string // decoded AUTH PLAIN response
+	authTLS  bool   // whether AUTH came over TLS
+	messages []string
+}
+
+// newFakeSMTP starts a fakeSMTP that speaks TLS from the start if
+// implicitTLS is set. It returns the server and a client TLS config that
+// trusts it.
+func newFakeSMTP(t *testing.T, implicitTLS, offerTLS bool) (*fakeSMTP, *tls.Config) {
+	t.Helper()
+	cert, pool := testCertificate(t)
+	f := &fakeSMTP{tlsConfig: &tls.Config{Certificates: []tls.Certificate{cert}}, offerTLS: offerTLS}
+	var err error
+	if implicitTLS {
+		f.ln, err = tls.Listen("tcp", "127.0.0.1:0", f.tlsConfig)
+	} else {
+		f.ln, err = net.Listen("tcp", "127.0.0.1:0")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.ln.Close() })
+	go func() {
+		for {
+			conn, err := f.ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn, implicitTLS)
+		}
+	}()
+	return f, &tls.Config{RootCAs: pool}
+}
+
+func (f *fakeSMTP) port() int {
+	return f.ln.Addr().(*net.TCPAddr).Port
+}
+
+func (f *fakeSMTP) serve(conn net.Conn, secure bool) {
+	defer func() { conn.Close() }()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+	reply("220 fake ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb, arg, _ := strings.Cut(line, " ")
+		f.mu.Lock()
+		f.commands = append(f.commands, strings.ToUpper(verb))
+		f.mu.Unlock()
+
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			reply("250-fake")
+			if f.offerTLS && !secure {
+				reply("250-STARTTLS")
+			}
+			reply("250 AUTH PLAIN")
+		case "STARTTLS":
+			reply("220 ready")
+			tlsConn := tls.Server(conn, f.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, r, secure = tlsConn, bufio.NewReader(tlsConn), true
+		case "AUTH":
+			_, response, _ := strings.Cut(arg, " ")
+			decoded, _ := base64.StdEncoding.DecodeString(response)
+			f.mu.Lock()
+			f.auth, f.authTLS = string(decoded), secure
+			f.mu.Unlock()
+			reply("235 authenticated")
+		case "MAIL", "RCPT", "RSET", "NOOP":
+			reply("250 ok")
+		case "DATA":
+			reply("354 go ahead")
+			var b strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				b.WriteString(strings.TrimPrefix(line, "."))
+			}
+			f.mu.Lock()
+			f.messages = append(f.messages, b.String())
+			f.mu.Unlock()
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+// testCertificate returns a self-signed certificate for 127.0.0.1 and a pool
+// that trusts it.
+func testCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(parsed)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
+}
+
+func TestSend(t *testing.T) {
+	tests := []struct {
+		name        string
+		security    Security
+		implicitTLS bool
+		offerTLS    bool
+		username    string
+		wantErr     string
+		wantTLSAuth bool
+	}{
+		{name: "plain text", security: None},
+		{name: "plain text with auth to localhost", security: None, username: "user"},
+		{name: "STARTTLS", security: StartTLS, offerTLS: true, username: "user", wantTLSAuth: true},
+		{name: "STARTTLS not offered", security: StartTLS, username: "user", wantErr: "does not offer STARTTLS"},
+		{name: "implicit TLS", security: TLS, implicitTLS: true, username: "user", wantTLSAuth: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, clientTLS := newFakeSMTP(t, tt.implicitTLS, tt.offerTLS)
+			sender, err := NewSender(Config{Host: "127.0.0.1", Port: server.port(), Security: tt.security,
+				Username: tt.username, Password: "secret", From: "Scheduler <scheduler@example.com>",
+				Timeout: 5 * time.Second, TLSConfig: clientTLS})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = sender.Send(t.Context(), Message{To: []string{"ops@example.com"}, Subject: "Test", Text: "Hello"})
+
+			server.mu.Lock()
+			defer server.mu.Unlock()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Send() = %v, want an error containing %q", err, tt.wantErr)
+				}
+				if len(server.messages) > 0 || server.auth != "" {
+					t.Errorf("sent credentials or a message over plain text: %v", server.commands)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Send() = %v (commands %v)", err, server.commands)
+			}
+			if len(server.messages) != 1 {
+				t.Fatalf("server got %d messages, want 1", len(server.messages))
+			}
+			switch {
+			case tt.username == "" && server.auth != "":
+				t.Errorf("authenticated without a username")
+			case tt.username != "" && server.auth != "\x00user\x00secret":
+				t.Errorf("AUTH PLAIN sent %q, want the username and password", server.auth)
+			case tt.username != "" && server.authTLS != tt.wantTLSAuth:
+				t.Errorf("authenticated over TLS: %v, want %v", server.authTLS, tt.wantTLSAuth)
+			}
+		})
+	}
+}
+
+func TestSendUntrustedCertificate(t *testing.T) {
+	server, _ := newFakeSMTP(t, true, false)
+	sender, err := NewSender(Config{Host: "127.0.0.1", Port: server.port(), Security: TLS, From: "scheduler@example.com",
+		Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sender.Send(t.Context(), Message{To: []string{"ops@example.com"}, Subject: "Test", Text: "Hello"}); err == nil {
+		t.Fatal("Send() trusted a self-signed certificate")
+	}
+}
+
+func TestCompose(t *testing.T) {
+	sender, err := NewSender(Config{Host: "smtp.example.com", From: "Scheduler <scheduler@example.com>"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := "Turned off 3 projects.\nSavings: 12,000 Toman – estimated"
+	data, err := sender.compose(Message{To: []string{"ops@example.com"}, Subject: "Weekly digest – خلاصه",
+		Text: text, HTML: "<p>Turned off 3 projects.</p>"}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != "Weekly digest – خلاصه" {
+		t.Errorf("Subject = %q, %v", subject, err)
+	}
+	if !strings.HasSuffix(msg.Header.Get("Message-ID"), "@example.com>") {
+		t.Errorf("Message-ID %q not in the sender's domain", msg.Header.Get("Message-ID"))
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, %v", mediaType, err)
+	}
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	var bodies []string
+	for {
+		part, err := parts.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(part) // NextPart decodes quoted-printable
+		if err != nil {
+			t.Fatal(err)
+		}
+		bodies = append(bodies, string(body))
+	}
+	if len(bodies) != 2 || bodies[0] != strings.ReplaceAll(text, "\n", "\r\n") || bodies[1] != "<p>Turned off 3 projects.</p>" {
+		t.Errorf("parts = %q", bodies)
+	}
+
+	if _, err := sender.compose(Message{To: []string{"ops@example.com\r\nBcc: x@example.com"}, Text: "x"}, time.Now()); err == nil {
+		t.Error("compose accepted a recipient with a line break")
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"scheduler/liara"
+	"scheduler/store"
+)
+
+const testToken = "test-token"
+
+var testOwner = ownerFromToken(testToken)
+
+func TestMain(m *testing.M) {
+	slog.SetDefault(slog.New(&auditHandler{next: slog.NewTextHandler(io.Discard, nil)}))
+	// Nothing listens there, so a test that forgets to stub Liara fails
+	// instead of reaching it.
+	liaraClient = liara.NewClient(liara.WithBaseURL("http://127.0.0.1:1"))
+	dataStore, _ = store.NewFile("", store.FileOptions{})
+	storeBackend = "memory"
+	os.Exit(m.Run())
+}
+
+// serve sends a request authenticated with testToken to handler and returns
+// the status code and body.
+func serve(t *testing.T, handler http.HandlerFunc, method, path, body string) (int, string) {
+	t.Helper()
+	return serveAs(t, testToken, handler, method, path, body)
+}
+
+// serveAs is serve for the account of another token.
+func serveAs(t *testing.T, token string, handler http.HandlerFunc, method, path, body string) (int, string) {
+	t.Helper()
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	authMiddleware(handler)(w, r)
+	return w.Code, w.Body.String()
+}
+
+// stubLiara points liaraClient at handler for the rest of the test.
+func stubLiara(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	saved := liaraClient
+	liaraClient = liara.NewClient(liara.WithBaseURL(srv.URL))
+	t.Cleanup(func() {
+		liaraClient = saved
+		srv.Close()
+	})
+}
+
+// createSchedule adds a schedule for testToken from its JSON request and
+// returns it.
+func createSchedule(t *testing.T, request string) Schedule {
+	t.Helper()
+	code, body := serve(t, scheduleHandler, http.MethodPost, "/schedule", request)
+	if code != http.StatusOK {
+		t.Fatalf("creating schedule: %d %s", code, body)
+	}
+	var created struct{ ID string }
+	if err := json.Unmarshal([]byte(body), &created); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		serve(t, deleteScheduleHandler, http.MethodDelete, "/schedule/delete/"+created.ID, "")
+	})
+	s, ok := findSchedule(created.ID)
+	if !ok {
+		t.Fatalf("schedule %s not in memory", created.ID)
+	}
+	return s
+}
+
+// findSchedule returns the in-memory copy of a schedule.
+func findSchedule(id string) (Schedule, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, s := range schedules {
+		if s.ID == id {
+			return s, true
+		}
+	}
+	return Schedule{}, false
+}
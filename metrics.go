@@ -0,0 +1,106 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const metricsNamespace = "liara_scheduler"
+
+var (
+	scaleActionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "scale_actions_total",
+		Help:      "Scale calls made to the Liara API, by service type, action and outcome.",
+	}, []string{"service_type", "action", "outcome"})
+
+	liaraRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "liara_request_duration_seconds",
+		Help:      "Latency of Liara API requests, by endpoint and status code (0 if no response was received).",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"endpoint", "status"})
+
+	scheduleRunLag = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "schedule_run_lag_seconds",
+		Help:      "How late the most recent schedule run started after its planned time.",
+	})
+
+	_ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace:   metricsNamespace,
+		Name:        "schedules",
+		Help:        "Schedules by state; active ones have a cron entry, paused ones are disabled or belong to a paused account.",
+		ConstLabels: prometheus.Labels{"state": "active"},
+	}, func() float64 { return float64(countSchedules(true)) })
+
+	_ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace:   metricsNamespace,
+		Name:        "schedules",
+		Help:        "Schedules by state; active ones have a cron entry, paused ones are disabled or belong to a paused account.",
+		ConstLabels: prometheus.Labels{"state": "paused"},
+	}, func() float64 { return float64(countSchedules(false)) })
+
+	_ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "leader",
+		Help:      "1 if this replica is the leader and runs the schedules, 0 otherwise.",
+	}, func() float64 {
+		if isLeader.Load() {
+			return 1
+		}
+		return 0
+	})
+
+	_ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "dead_letters",
+		Help:      "Dead letters that have not been replayed.",
+	}, func() float64 { return float64(countDeadLetters()) })
+)
+
+// observeLiaraRequest records the latency of a Liara API request; it is the
+// observer of liaraClient.
+func observeLiaraRequest(endpoint string, status int, elapsed time.Duration) {
+	liaraRequestDuration.WithLabelValues(endpoint, strconv.Itoa(status)).Observe(elapsed.Seconds())
+}
+
+// observeScaleAction counts a scale call; err is the call's result. The
+// target is left out: /metrics is unauthenticated and must not list the
+// accounts' projects and databases, nor grow a series for each of them.
+func observeScaleAction(serviceType string, turnOn bool, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+	}
+	scaleActionsTotal.WithLabelValues(serviceType, onOff(turnOn), outcome).Inc()
+}
+
+// countSchedules returns how many schedules are running, or how many are
+// disabled or belong to a paused account.
+func countSchedules(active bool) int {
+	mu.Lock()
+	defer mu.Unlock()
+	n := 0
+	for _, s := range schedules {
+		if shouldRun(s) == active {
+			n++
+		}
+	}
+	return n
+}
+
+func countDeadLetters() int {
+	deadLettersMu.Lock()
+	defer deadLettersMu.Unlock()
+	n := 0
+	for _, letter := range deadLetters {
+		if letter.ReplayedAt == nil {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+)
+
+// migrateCommand runs "migrate status", "migrate up" or "migrate down" on
+// the configured SQL store. The server applies pending migrations itself on
+// start; this is for inspecting them and for rolling back.
+func migrateCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New("migrate: missing subcommand (available: status, up, down)")
+	}
+
+	fs := flag.NewFlagSet("migrate "+args[0], flag.ExitOnError)
+	steps := 1
+	if args[0] == "down" {
+		fs.IntVar(&steps, "steps", 1, "number of migrations to revert")
+	}
+	fs.Parse(args[1:])
+
+	m, err := openMigrator()
+	if err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+	defer m.Close()
+	ctx := context.Background()
+
+	switch args[0] {
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return fmt.Errorf("migrate: %w", err)
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.AppliedAt != nil {
+				state = "applied " + s.AppliedAt.Local().Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%-20s %s\n", s.Version, s.Name, state)
+		}
+		return nil
+	case "up":
+		n, err := m.Up(ctx)
+		if err != nil {
+			return fmt.Errorf("migrate: %w", err)
+		}
+		fmt.Printf("Applied %d migrations.\n", n)
+		return nil
+	case "down":
+		if steps < 1 {
+			return errors.New("migrate: -steps must be at least 1")
+		}
+		n, err := m.Down(ctx, steps)
+		if err != nil {
+			return fmt.Errorf("migrate: %w", err)
+		}
+		fmt.Printf("Reverted %d migrations.\n", n)
+		return nil
+	default:
+		return fmt.Errorf("migrate: unknown subcommand %q (available: status, up, down)", args[0])
+	}
+}
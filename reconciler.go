@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"scheduler/retry"
+)
+
+const defaultReconcileInterval = 5 * time.Minute
+
+// reconcileTarget identifies a project or database of one account.
+type reconcileTarget struct {
+	owner       string
+	serviceType string
+	serviceName string
+}
+
+// desiredState is what the account's windows say a target should be.
+type desiredState struct {
+	on      bool
+	token   string
+	windows []string // IDs of the windows that contributed
+	// scheduleID and policy are those of the first window that wants the
+	// target in this state; a correction is recorded under that window and
+	// retried under its policy.
+	scheduleID string
+	policy     retry.Policy
+}
+
+// reconcileInterval reads RECONCILE_INTERVAL; "0" or "off" disables the
+// reconciler.
+func reconcileInterval() time.Duration {
+	value := os.Getenv("RECONCILE_INTERVAL")
+	switch value {
+	case "":
+		return defaultReconcileInterval
+	case "0", "off":
+		return 0
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		log.Printf("Invalid RECONCILE_INTERVAL %q, using %s", value, defaultReconcileInterval)
+		return defaultReconcileInterval
+	}
+	return d
+}
+
+// startReconciler periodically compares every target covered by a window
+// schedule with its actual scale and corrects any drift.
+func startReconciler(interval time.Duration) {
+	if interval == 0 {
+		log.Println("Reconciler disabled.")
+		return
+	}
+	log.Printf("Reconciler running every %s.", interval)
+	go runReconciler(interval)
+}
+
+// runReconciler reconciles every interval while this replica leads, until
+// the server shuts down. Each pass is one of the backgroundJobs, so shutdown
+// waits for its corrections before closing the store.
+func runReconciler(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-shuttingDown:
+			return
+		case <-ticker.C:
+		}
+		if !isLeader.Load() || isShuttingDown() {
+			continue
+		}
+		backgroundJobs.Add(1)
+		reconcile(context.Background(), time.Now())
+		backgroundJobs.Done()
+	}
+}
+
+// windowActive reports whether window s wants its target on at now. ok is
+// false when the window has no say, because it is paused, cannot be parsed or
+// its calendar rule skips the current period; the target is then left alone,
+// just as the skipped cron runs left it alone.
+func windowActive(s Schedule, now time.Time) (active, ok bool) {
+	if s.Kind != ScheduleKindWindow || s.JobID == 0 {
+		return false, false
+	}
+	start, err := cron.ParseStandard(cronSpecWithTimezone(s.CronSpec, s.Timezone))
+	if err != nil {
+		return false, false
+	}
+	end, err := windowEnd(s, start)
+	if err != nil {
+		return false, false
+	}
+
+	nextStart := start.Next(now)
+	nextEnd := end.Next(now)
+	if nextStart.IsZero() || nextEnd.IsZero() {
+		return false, false
+	}
+	// Inside a window the end comes before the next start.
+	active = nextEnd.Before(nextStart)
+
+	var openedBy cron.Schedule
+	if active {
+		openedBy = start
+	}
+	if _, skip := s.calendarSkip(now, openedBy); skip {
+		return false, false
+	}
+	return active, true
+}
+
+// desiredStates derives the desired state of every target from the active
+// window schedules. A target covered by several windows is on if any of them
+// is open.
+func desiredStates(now time.Time) map[reconcileTarget]*desiredState {
+	mu.Lock()
+	defer mu.Unlock()
+
+	states := make(map[reconcileTarget]*desiredState)
+	for _, s := range schedules {
+		active, ok := windowActive(s, now)
+		if !ok {
+			continue
+		}
+		target := reconcileTarget{owner: s.Owner, serviceType: s.ServiceType, serviceName: s.ServiceName}
+		state, exists := states[target]
+		if !exists {
+			state = &desiredState{token: s.token, scheduleID: s.ID, policy: s.Retry.WithDefaults()}
+			states[target] = state
+		}
+		if active && !state.on {
+			state.scheduleID, state.policy = s.ID, s.Retry.WithDefaults()
+		}
+		state.on = state.on || active
+		state.windows = append(state.windows, s.ID)
+	}
+	return states
+}
+
+// reconcile fetches the actual scale of every target with a desired state and
+// re-issues the scale call where they differ. A correction runs like a
+// scheduled run of the window it is recorded under: with retries, an entry in
+// its execution history and a dead letter if it keeps failing.
+func reconcile(ctx context.Context, now time.Time) {
+	states := desiredStates(now)
+
+	type ownerType struct{ owner, serviceType string }
+	actual := make(map[ownerType]map[string]bool)
+
+	for target, state := range states {
+		// Corrections not started yet are left to the next leader.
+		if isShuttingDown() {
+			return
+		}
+		key := ownerType{target.owner, target.serviceType}
+		scales, fetched := actual[key]
+		logger := accountLogger(target.owner).With(logKeyTarget, target.serviceName)
+		if !fetched {
+			var err error
+			scales, err = actualScales(ctx, target.serviceType, state.token)
+			if err != nil {
+				logger.Error(fmt.Sprintf("Reconciler could not fetch %ss", target.serviceType), "error", err)
+			}
+			actual[key] = scales
+		}
+		if scales == nil {
+			continue
+		}
+
+		on, found := scales[target.serviceName]
+		if !found {
+			logger.Warn(fmt.Sprintf("Reconciler could not find %s %s", target.serviceType, target.serviceName))
+			continue
+		}
+		if on == state.on {
+			continue
+		}
+
+		logger.Warn(fmt.Sprintf("Drift detected: %s %s is %s but its windows want it %s, correcting",
+			target.serviceType, target.serviceName, onOff(on), onOff(state.on)), "windows", state.windows)
+		publishEvent(target.owner, EventReconcileDrift, DriftEvent{ServiceType: target.serviceType, ServiceName: target.serviceName,
+			Actual: onOff(on), Desired: onOff(state.on), Windows: state.windows})
+		correction := scaleAction{owner: target.owner, scheduleID: state.scheduleID, serviceType: target.serviceType,
+			serviceName: target.serviceName, action: onOff(state.on), token: state.token,
+			logger: logger.With(logKeySchedule, state.scheduleID)}
+		executeAction(correction, state.policy, now)
+	}
+}
+
+// actualScales returns whether each project or database of the account is
+// currently scaled up, keyed by the name schedules refer to it by.
+func actualScales(ctx context.Context, serviceType, token string) (map[string]bool, error) {
+	scales := make(map[string]bool)
+	if serviceType == "project" {
+		projects, err := liaraClient.GetProjects(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range projects {
+			scales[p.ProjectID] = p.Scale > 0
+		}
+		return scales, nil
+	}
+
+	databases, err := liaraClient.GetDatabases(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range databases {
+		scales[d.DBId] = d.Scale > 0
+		if d.ID != "" {
+			scales[d.ID] = d.Scale > 0
+		}
+	}
+	return scales, nil
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
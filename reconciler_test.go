@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"scheduler/store"
+)
+
+func TestReconcileCorrectsDriftWithRetries(t *testing.T) {
+	scaleCalls := 0
+	stubLiara(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`{"projects":[{"project_id":"drifting","scale":0}]}`))
+			return
+		}
+		scaleCalls++
+		if scaleCalls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{}`))
+	})
+
+	// A window that opened an hour ago and is still open.
+	now := time.Now()
+	opened := now.Add(-time.Hour)
+	window := createSchedule(t, fmt.Sprintf(`{"kind":"window","service":"drifting","serviceType":"project",
+		"cron":"%d %d * * *","duration":"2h","retry":{"maxAttempts":3,"initialBackoff":"1ms"}}`, opened.Minute(), opened.Hour()))
+
+	reconcile(context.Background(), now)
+
+	if scaleCalls != 2 {
+		t.Fatalf("%d scale calls, want a failed one and its retry", scaleCalls)
+	}
+	executions, _, err := dataStore.Executions(store.ExecutionFilter{Owner: testOwner, ScheduleID: window.ID, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(executions) != 1 {
+		t.Fatalf("%d executions recorded for the window, want 1", len(executions))
+	}
+	if e := executions[0]; e.Outcome != ExecutionSucceeded || e.Action != "on" || e.Attempts != 2 {
+		t.Errorf("execution = %s %s after %d attempts, want succeeded on after 2", e.Outcome, e.Action, e.Attempts)
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"scheduler/store"
+)
+
+// accountReport sums up an account's runs over a period, for the Telegram
+// summary and the email digests. Only the runs of schedules that still exist
+// are counted.
+type accountReport struct {
+	From, To  time.Time
+	Succeeded int
+	Failed    int
+	Skipped   int
+	Failures  []Execution // oldest first
+	// PendingFailedActions counts the account's dead letters that were not
+	// replayed yet, whenever they failed.
+	PendingFailedActions int
+
+	Account SchedulesResponse
+	// Enabled counts the schedules in Account that are not paused.
+	Enabled int
+
+	executions []Execution // every run of the period, oldest first
+}
+
+// buildAccountReport collects the owner's runs planned from from until to.
+func buildAccountReport(owner string, from, to time.Time) accountReport {
+	report := accountReport{From: from, To: to, Account: accountSchedules(owner)}
+	for _, s := range report.Account.Schedules {
+		if s.Enabled {
+			report.Enabled++
+		}
+		f := store.ExecutionFilter{Owner: owner, ScheduleID: s.ID, From: from, To: to, Limit: maxExecutionsLimit}
+		for {
+			executions, total, err := dataStore.Executions(f)
+			if err != nil {
+				accountLogger(owner).Error("Error querying executions for a report", logKeySchedule, s.ID, "error", err)
+				break
+			}
+			report.executions = append(report.executions, executions...)
+			f.Offset += len(executions)
+			if len(executions) == 0 || f.Offset >= total {
+				break
+			}
+		}
+	}
+	sort.SliceStable(report.executions, func(i, j int) bool {
+		return report.executions[i].PlannedAt.Before(report.executions[j].PlannedAt)
+	})
+
+	for _, e := range report.executions {
+		switch e.Outcome {
+		case ExecutionSucceeded:
+			report.Succeeded++
+		case ExecutionFailed:
+			report.Failed++
+			report.Failures = append(report.Failures, e)
+		case ExecutionSkipped:
+			report.Skipped++
+		}
+	}
+
+	deadLettersMu.Lock()
+	for _, letter := range deadLetters {
+		if letter.Owner == owner && letter.ReplayedAt == nil {
+			report.PendingFailedActions++
+		}
+	}
+	deadLettersMu.Unlock()
+	return report
+}
+
+// targetSavings is what keeping one target off during a period saved.
+type targetSavings struct {
+	ServiceType string
+	ServiceName string
+	OffHours    float64
+	// HourlyPrice is what the target costs per hour while on; 0 when it is
+	// not known.
+	HourlyPrice int64
+	Savings     int64
+}
+
+// offHours works out, from the successful runs of the period, how long each
+// target was kept off: from each run that turned it off to the next that
+// turned it on, or to the end of the period. Time off before the first such
+// run is not counted, since the target may have been on, and neither are
+// scale changes made outside the schedules.
+func (r accountReport) offHours() map[reconcileTarget]time.Duration {
+	offSince := make(map[reconcileTarget]time.Time)
+	off := make(map[reconcileTarget]time.Duration)
+	for _, e := range r.executions {
+		if e.Outcome != ExecutionSucceeded {
+			continue
+		}
+		target := reconcileTarget{owner: e.Owner, serviceType: e.ServiceType, serviceName: e.ServiceName}
+		since, isOff := offSince[target]
+		switch {
+		case e.Action == "off" && !isOff:
+			offSince[target] = e.FinishedAt
+		case e.Action == "on" && isOff:
+			off[target] += e.FinishedAt.Sub(since)
+			delete(offSince, target)
+		}
+	}
+	for target, since := range offSince {
+		off[target] += r.To.Sub(since)
+	}
+	return off
+}
+
+// savings estimates what keeping targets off saved during the period, at the
+// hourly prices known for token, biggest saving first. It also reports
+// whether a price was missing for any target.
+func (r accountReport) savings(token string) (savings []targetSavings, total int64, unpriced bool) {
+	off := r.offHours()
+	if len(off) == 0 {
+		return nil, 0, false
+	}
+	prices := hourlyPrices(context.Background(), token, off)
+
+	for target, d := range off {
+		s := targetSavings{ServiceType: target.serviceType, ServiceName: target.serviceName, OffHours: d.Hours(),
+			HourlyPrice: int64(prices[target])}
+		if s.HourlyPrice == 0 {
+			unpriced = true
+		}
+		s.Savings = int64(s.OffHours*float64(s.HourlyPrice) + 0.5)
+		total += s.Savings
+		savings = append(savings, s)
+	}
+	sort.Slice(savings, func(i, j int) bool {
+		if savings[i].Savings != savings[j].Savings {
+			return savings[i].Savings > savings[j].Savings
+		}
+		if savings[i].OffHours != savings[j].OffHours {
+			return savings[i].OffHours > savings[j].OffHours
+		}
+		return savings[i].ServiceName < savings[j].ServiceName
+	})
+	return savings, total, unpriced
+}
+
+// hourlyPrices looks up what the targets cost per hour. Liara reports the
+// price of databases; projects are priced by their plan from
+// PLAN_HOURLY_PRICES. Targets whose price cannot be found are left out.
+func hourlyPrices(ctx context.Context, token string, targets map[reconcileTarget]time.Duration) map[reconcileTarget]int {
+	prices := make(map[reconcileTarget]int)
+	if token == "" {
+		return prices
+	}
+	var projects, databases bool
+	for target := range targets {
+		projects = projects || target.serviceType == "project"
+		databases = databases || target.serviceType == "database"
+	}
+
+	if projects && len(planHourlyPrices) > 0 {
+		if list, err := liaraClient.GetProjects(ctx, token); err == nil {
+			for _, p := range list {
+				target := reconcileTarget{serviceType: "project", serviceName: p.ProjectID}
+				if price, ok := planHourlyPrices[p.PlanID]; ok {
+					prices[target] = price
+				}
+			}
+		}
+	}
+	if databases {
+		if list, err := liaraClient.GetDatabases(ctx, token); err == nil {
+			for _, d := range list {
+				prices[reconcileTarget{serviceType: "database", serviceName: d.DBId}] = d.HourlyPrice
+				if d.ID != "" {
+					prices[reconcileTarget{serviceType: "database", serviceName: d.ID}] = d.HourlyPrice
+				}
+			}
+		}
+	}
+
+	byTarget := make(map[reconcileTarget]int, len(targets))
+	for target := range targets {
+		if price, ok := prices[reconcileTarget{serviceType: target.serviceType, serviceName: target.serviceName}]; ok {
+			byTarget[target] = price
+		}
+	}
+	return byTarget
+}
+
+// planHourlyPrices holds what a project on each plan costs per hour, from
+// PLAN_HOURLY_PRICES.
+var planHourlyPrices map[string]int
+
+// planHourlyPricesFromEnv reads PLAN_HOURLY_PRICES, a comma-separated list
+// of plan=price pairs, since the Liara API does not report what projects
+// cost.
+func planHourlyPricesFromEnv() (map[string]int, error) {
+	prices := make(map[string]int)
+	value := os.Getenv("PLAN_HOURLY_PRICES")
+	if value == "" {
+		return prices, nil
+	}
+	for _, pair := range strings.Split(value, ",") {
+		plan, price, ok := strings.Cut(pair, "=")
+		p, err := strconv.Atoi(strings.TrimSpace(price))
+		if !ok || strings.TrimSpace(plan) == "" || err != nil || p < 0 {
+			return nil, fmt.Errorf("invalid PLAN_HOURLY_PRICES entry %q, want plan=price", pair)
+		}
+		prices[strings.TrimSpace(plan)] = p
+	}
+	return prices, nil
+}
+
+// reportCronSpec reads when a periodic report is sent from the environment
+// variable name, def if it is unset; "off" disables the report.
+func reportCronSpec(name, def string) (string, error) {
+	spec := os.Getenv(name)
+	switch spec {
+	case "":
+		return def, nil
+	case "off":
+		return "", nil
+	}
+	if _, err := cron.ParseStandard(spec); err != nil {
+		return "", fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return spec, nil
+}
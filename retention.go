@@ -0,0 +1,200 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"scheduler/store"
+)
+
+const (
+	defaultJanitorInterval = time.Hour
+	defaultPruneBatchSize  = 1000
+	// defaultMemoryLogCapacity bounds each account's log in the file store
+	// when LOG_MAX_ENTRIES is unset.
+	defaultMemoryLogCapacity = 1000
+)
+
+// logRetention says how long log entries are kept.
+type logRetention struct {
+	maxAge     time.Duration // 0 keeps entries regardless of age
+	maxEntries int           // per account; 0 is unlimited in SQL stores
+	interval   time.Duration // how often the janitor runs
+	batchSize  int           // rows deleted per statement by SQL stores
+	archiveDir string        // where pruned entries are archived; empty discards them
+}
+
+// retention is the policy in effect, set once at startup.
+var retention = logRetention{interval: defaultJanitorInterval, batchSize: defaultPruneBatchSize}
+
+// logRetentionFromEnv reads LOG_RETENTION (a Go duration such as "720h"),
+// LOG_MAX_ENTRIES, LOG_JANITOR_INTERVAL, LOG_PRUNE_BATCH and LOG_ARCHIVE_DIR.
+func logRetentionFromEnv() (logRetention, error) {
+	r := logRetention{interval: defaultJanitorInterval, batchSize: defaultPruneBatchSize, archiveDir: os.Getenv("LOG_ARCHIVE_DIR")}
+
+	if v := os.Getenv("LOG_RETENTION"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return r, fmt.Errorf("invalid LOG_RETENTION %q", v)
+		}
+		r.maxAge = d
+	}
+	if v := os.Getenv("LOG_MAX_ENTRIES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return r, fmt.Errorf("invalid LOG_MAX_ENTRIES %q", v)
+		}
+		r.maxEntries = n
+	}
+	if v := os.Getenv("LOG_JANITOR_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return r, fmt.Errorf("invalid LOG_JANITOR_INTERVAL %q", v)
+		}
+		r.interval = d
+	}
+	if v := os.Getenv("LOG_PRUNE_BATCH"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return r, fmt.Errorf("invalid LOG_PRUNE_BATCH %q", v)
+		}
+		r.batchSize = n
+	}
+	if r.archiveDir != "" {
+		if err := os.MkdirAll(r.archiveDir, 0o750); err != nil {
+			return r, fmt.Errorf("creating LOG_ARCHIVE_DIR: %w", err)
+		}
+	}
+	return r, nil
+}
+
+// memoryLogCapacity is how many entries the file store keeps per account.
+func (r logRetention) memoryLogCapacity() int {
+	if r.maxEntries > 0 {
+		return r.maxEntries
+	}
+	return defaultMemoryLogCapacity
+}
+
+// startLogJanitor periodically prunes log entries beyond the retention
+// policy. It does nothing if the policy keeps everything.
+func startLogJanitor(r logRetention) {
+	if r.maxAge == 0 && r.maxEntries == 0 {
+		switch storeBackend {
+		case "postgres", "sqlite":
+			log.Println("Log retention not configured, keeping all log entries.")
+		default:
+			log.Printf("Log retention not configured, keeping the latest %d log entries per account.", r.memoryLogCapacity())
+		}
+		return
+	}
+	log.Printf("Log janitor running every %s (retention %s, %d entries per account).", r.interval, r.maxAge, r.maxEntries)
+	go runLogJanitor(r)
+}
+
+// runLogJanitor prunes the logs right away and then every r.interval while
+// this replica leads, until the server shuts down. Each pass is one of the
+// backgroundJobs, so the store is not closed under it.
+func runLogJanitor(r logRetention) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		if isLeader.Load() && !isShuttingDown() {
+			backgroundJobs.Add(1)
+			pruned, err := pruneLogs(r, time.Now())
+			backgroundJobs.Done()
+			if err != nil {
+				log.Printf("Error pruning logs: %v", err)
+			}
+			if pruned > 0 {
+				log.Printf("Pruned %d log entries.", pruned)
+			}
+		}
+		select {
+		case <-shuttingDown:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// pruneLogs deletes the entries older than the retention age and the oldest
+// entries of accounts over the size limit, archiving them first if an
+// archive directory is configured. It returns how many entries it removed.
+func pruneLogs(r logRetention, now time.Time) (int, error) {
+	archive, err := openLogArchive(r.archiveDir, now)
+	if err != nil {
+		return 0, err
+	}
+	defer archive.close()
+
+	var before time.Time
+	if r.maxAge > 0 {
+		before = now.Add(-r.maxAge)
+	}
+	return dataStore.PruneLogs(store.PruneOptions{Before: before, MaxEntries: r.maxEntries, BatchSize: r.batchSize, Archive: archive.write})
+}
+
+// logArchive is a gzipped NDJSON file of pruned entries, created on the
+// first write. A nil archive discards entries.
+type logArchive struct {
+	path string
+	file *os.File
+	gz   *gzip.Writer
+}
+
+func openLogArchive(dir string, now time.Time) (*logArchive, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	return &logArchive{path: filepath.Join(dir, "logs-"+now.UTC().Format("20060102T150405Z")+".ndjson.gz")}, nil
+}
+
+// write appends entries to the archive and flushes them to disk.
+func (a *logArchive) write(entries []LogEntry) error {
+	if a == nil || len(entries) == 0 {
+		return nil
+	}
+	if a.file == nil {
+		file, err := os.OpenFile(a.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+		if err != nil {
+			return fmt.Errorf("opening log archive: %w", err)
+		}
+		a.file = file
+		a.gz = gzip.NewWriter(file)
+	}
+
+	encoder := json.NewEncoder(a.gz)
+	for _, e := range entries {
+		// Owner is not part of LogEntry's JSON, which is per account.
+		record := struct {
+			Account string `json:"account"`
+			LogEntry
+		}{e.Owner, e}
+		if err := encoder.Encode(record); err != nil {
+			return fmt.Errorf("writing log archive: %w", err)
+		}
+	}
+	if err := a.gz.Flush(); err != nil {
+		return fmt.Errorf("writing log archive: %w", err)
+	}
+	return a.file.Sync()
+}
+
+func (a *logArchive) close() {
+	if a == nil || a.file == nil {
+		return
+	}
+	if err := a.gz.Close(); err != nil {
+		log.Printf("Error closing log archive: %v", err)
+	}
+	if err := a.file.Close(); err != nil {
+		log.Printf("Error closing log archive: %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"scheduler/liara"
+	"scheduler/retry"
+)
+
+// scaleAction is one turn-on or turn-off of a target on behalf of an account.
+type scaleAction struct {
+	owner       string
+	scheduleID  string // empty for actions that do not come from a schedule
+	serviceType string
+	serviceName string
+	action      string // "on" or "off"
+	executionID string // empty outside schedule runs
+	attempt     int    // counting from 1; 0 outside runWithRetry
+	token       string
+	logger      *slog.Logger // tagged with the account and, for schedule runs, the schedule and execution
+}
+
+func (a scaleAction) run() (*liara.Response, error) {
+	if a.serviceType == "project" {
+		return scaleProject(a)
+	}
+	return scaleDatabase(a)
+}
+
+// publishScaleResult publishes the outcome of one scale call of a.
+func publishScaleResult(a scaleAction, resp *liara.Response, err error) {
+	eventType := EventScaleSucceeded
+	if err != nil {
+		eventType = EventScaleFailed
+	}
+	publishEvent(a.owner, eventType, newScaleEvent(a, resp, err))
+}
+
+func newScaleEvent(a scaleAction, resp *liara.Response, err error) ScaleEvent {
+	e := ScaleEvent{ScheduleID: a.scheduleID, ExecutionID: a.executionID, ServiceType: a.serviceType,
+		ServiceName: a.serviceName, Action: a.action, Attempt: a.attempt}
+	if resp != nil {
+		e.StatusCode = resp.StatusCode
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	return e
+}
+
+// scaleResult is the outcome of an action after all its attempts.
+type scaleResult struct {
+	attempts int
+	response *liara.Response // of the last attempt; nil if the API was never reached
+	err      error
+}
+
+// runWithRetry runs a under policy, waiting between attempts, and
+// dead-letters it once the attempts are used up or the failure is not worth
+// retrying. It also stops waiting, and dead-letters a, when the server shuts
+// down or the replica loses the leadership it had when a started; the new
+// leader's reconciler takes over windows from there.
+func runWithRetry(a scaleAction, policy retry.Policy) scaleResult {
+	wasLeader := isLeader.Load()
+	var result scaleResult
+	for result.attempts = 1; ; result.attempts++ {
+		a.attempt = result.attempts
+		result.response, result.err = a.run()
+		if result.err == nil {
+			return result
+		}
+		if result.attempts >= policy.MaxAttempts || !retryable(policy, result.err) {
+			break
+		}
+		backoff := policy.Backoff(result.attempts)
+		a.logger.Warn(fmt.Sprintf("Attempt %d/%d to turn %s %s %s failed, retrying in %s",
+			result.attempts, policy.MaxAttempts, a.action, a.serviceType, a.serviceName, backoff.Round(time.Millisecond)))
+		if reason := waitToRetry(backoff, wasLeader); reason != "" {
+			a.logger.Warn(fmt.Sprintf("Stopped retrying to turn %s %s %s: %s", a.action, a.serviceType, a.serviceName, reason))
+			break
+		}
+	}
+
+	a.logger.Error(fmt.Sprintf("Giving up turning %s %s %s after %d attempt(s)", a.action, a.serviceType, a.serviceName, result.attempts), "error", result.err)
+	addDeadLetter(a, result.attempts, result.err)
+	publishEvent(a.owner, EventRetriesExhausted, newScaleEvent(a, result.response, result.err))
+	return result
+}
+
+// waitToRetry waits for backoff and returns why the next attempt must not be
+// made, or "" if it may.
+func waitToRetry(backoff time.Duration, wasLeader bool) string {
+	select {
+	case <-time.After(backoff):
+	case <-shuttingDown:
+		return "the server is shutting down"
+	}
+	if wasLeader && !isLeader.Load() {
+		return "this replica is no longer the leader"
+	}
+	return ""
+}
+
+// retryable reports whether err is worth another attempt. API errors are
+// retried only for the policy's status codes; anything else, such as a
+// timeout or a refused connection, never reached the API and is retried.
+func retryable(policy retry.Policy, err error) bool {
+	var apiErr *liara.APIError
+	if errors.As(err, &apiErr) {
+		return policy.RetryableStatusCode(apiErr.StatusCode)
+	}
+	return !errors.Is(err, context.Canceled)
+}
+
+// marshalRetryPolicy encodes a policy for storage; a nil policy is stored as
+// nothing.
+func marshalRetryPolicy(policy *retry.Policy) ([]byte, error) {
+	if policy == nil {
+		return nil, nil
+	}
+	return json.Marshal(policy)
+}
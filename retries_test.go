@@ -0,0 +1,98 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"scheduler/retry"
+)
+
+func testAction(serviceName string) scaleAction {
+	return scaleAction{owner: testOwner, serviceType: "project", serviceName: serviceName, action: "on",
+		token: testToken, logger: accountLogger(testOwner)}
+}
+
+// deadLetterFor returns the last dead letter of the test account's target.
+func deadLetterFor(serviceName string) (DeadLetter, bool) {
+	deadLettersMu.Lock()
+	defer deadLettersMu.Unlock()
+	for i := len(deadLetters) - 1; i >= 0; i-- {
+		if deadLetters[i].Owner == testOwner && deadLetters[i].ServiceName == serviceName {
+			return deadLetters[i], true
+		}
+	}
+	return DeadLetter{}, false
+}
+
+func TestRunWithRetry(t *testing.T) {
+	policy := (&retry.Policy{MaxAttempts: 3, InitialBackoff: retry.Duration(time.Millisecond)}).WithDefaults()
+	tests := []struct {
+		name         string
+		statuses     []int // of the scale calls in turn; 200 after the list
+		wantAttempts int
+		wantDead     bool
+	}{
+		{"first attempt succeeds", nil, 1, false},
+		{"succeeds on a retry", []int{503, 502}, 3, false},
+		{"dead-lettered after the last attempt", []int{503, 503, 503, 503}, 3, true},
+		{"not retried on a client error", []int{400}, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			stubLiara(t, func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				if calls <= len(tt.statuses) {
+					w.WriteHeader(tt.statuses[calls-1])
+					return
+				}
+				w.Write([]byte(`{}`))
+			})
+
+			target := "retry-" + t.Name()
+			result := runWithRetry(testAction(target), policy)
+			if result.attempts != tt.wantAttempts || calls != tt.wantAttempts {
+				t.Errorf("%d attempts and %d calls, want %d", result.attempts, calls, tt.wantAttempts)
+			}
+			if (result.err != nil) != tt.wantDead {
+				t.Errorf("error = %v, want one: %v", result.err, tt.wantDead)
+			}
+			letter, dead := deadLetterFor(target)
+			if dead != tt.wantDead {
+				t.Fatalf("dead-lettered: %v, want %v", dead, tt.wantDead)
+			}
+			if dead && (letter.Attempts != tt.wantAttempts || letter.StatusCode != tt.statuses[tt.wantAttempts-1]) {
+				t.Errorf("dead letter after %d attempts with status %d", letter.Attempts, letter.StatusCode)
+			}
+		})
+	}
+}
+
+func TestRunWithRetryStopsOnShutdown(t *testing.T) {
+	saved := shuttingDown
+	shuttingDown = make(chan struct{})
+	defer func() { shuttingDown = saved }()
+
+	stubLiara(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	policy := (&retry.Policy{MaxAttempts: 3, InitialBackoff: retry.Duration(time.Hour)}).WithDefaults()
+
+	done := make(chan scaleResult)
+	go func() { done <- runWithRetry(testAction("retry-shutdown"), policy) }()
+	time.Sleep(50 * time.Millisecond)
+	close(shuttingDown)
+
+	select {
+	case result := <-done:
+		if result.attempts != 1 || result.err == nil {
+			t.Errorf("%d attempts, error %v, want the first failed attempt only", result.attempts, result.err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("runWithRetry kept waiting after shutdown")
+	}
+	if _, dead := deadLetterFor("retry-shutdown"); !dead {
+		t.Error("interrupted action was not dead-lettered")
+	}
+}
@@ -0,0 +1,131 @@
+// Package retry describes how failed scale calls are retried.
+package retry
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"time"
+)
+
+// Duration is a time.Duration that reads and writes Go duration strings in
+// JSON, e.g. "30s".
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"30s\": %w", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Policy is a retry policy with exponential backoff and jitter. Zero fields,
+// and a nil Jitter, take the value of DefaultPolicy.
+type Policy struct {
+	// MaxAttempts counts the first attempt, so 1 disables retries.
+	MaxAttempts    int      `json:"maxAttempts,omitempty"`
+	InitialBackoff Duration `json:"initialBackoff,omitempty"`
+	MaxBackoff     Duration `json:"maxBackoff,omitempty"`
+	Multiplier     float64  `json:"multiplier,omitempty"`
+	// Jitter randomizes each backoff by up to this fraction in either
+	// direction, e.g. 0.2 for ±20%. It is a pointer so that 0 can turn
+	// jitter off rather than select the default.
+	Jitter *float64 `json:"jitter,omitempty"`
+	// RetryableStatus lists the HTTP status codes worth retrying. Transport
+	// errors such as timeouts are always retried.
+	RetryableStatus []int `json:"retryableStatus,omitempty"`
+}
+
+// DefaultPolicy is used for schedules without a policy of their own.
+func DefaultPolicy() Policy {
+	jitter := 0.2
+	return Policy{
+		MaxAttempts:     3,
+		InitialBackoff:  Duration(5 * time.Second),
+		MaxBackoff:      Duration(time.Minute),
+		Multiplier:      2,
+		Jitter:          &jitter,
+		RetryableStatus: []int{408, 425, 429, 500, 502, 503, 504},
+	}
+}
+
+// WithDefaults returns p with its zero fields filled from DefaultPolicy. A
+// nil policy yields DefaultPolicy.
+func (p *Policy) WithDefaults() Policy {
+	def := DefaultPolicy()
+	if p == nil {
+		return def
+	}
+	merged := *p
+	if merged.MaxAttempts == 0 {
+		merged.MaxAttempts = def.MaxAttempts
+	}
+	if merged.InitialBackoff == 0 {
+		merged.InitialBackoff = def.InitialBackoff
+	}
+	if merged.MaxBackoff == 0 {
+		merged.MaxBackoff = def.MaxBackoff
+	}
+	if merged.Multiplier == 0 {
+		merged.Multiplier = def.Multiplier
+	}
+	if merged.Jitter == nil {
+		merged.Jitter = def.Jitter
+	}
+	if merged.RetryableStatus == nil {
+		merged.RetryableStatus = def.RetryableStatus
+	}
+	return merged
+}
+
+// Validate rejects policies that cannot be applied.
+func (p *Policy) Validate() error {
+	switch {
+	case p.MaxAttempts < 0 || p.MaxAttempts > 20:
+		return fmt.Errorf("maxAttempts must be between 1 and 20")
+	case p.InitialBackoff < 0 || p.MaxBackoff < 0:
+		return fmt.Errorf("backoff durations must not be negative")
+	case p.MaxBackoff != 0 && p.MaxBackoff < p.InitialBackoff:
+		return fmt.Errorf("maxBackoff must not be shorter than initialBackoff")
+	case p.Multiplier != 0 && p.Multiplier < 1:
+		return fmt.Errorf("multiplier must be at least 1")
+	case p.Jitter != nil && (*p.Jitter < 0 || *p.Jitter > 1):
+		return fmt.Errorf("jitter must be between 0 and 1")
+	}
+	for _, code := range p.RetryableStatus {
+		if code < 100 || code > 599 {
+			return fmt.Errorf("retryable status %d is not an HTTP status code", code)
+		}
+	}
+	return nil
+}
+
+// RetryableStatusCode reports whether a response with this status is retried.
+func (p Policy) RetryableStatusCode(code int) bool {
+	return slices.Contains(p.RetryableStatus, code)
+}
+
+// Backoff returns how long to wait after the given failed attempt, counting
+// from 1.
+func (p Policy) Backoff(attempt int) time.Duration {
+	backoff := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(attempt-1))
+	if ceiling := float64(p.MaxBackoff); backoff > ceiling {
+		backoff = ceiling
+	}
+	if p.Jitter != nil && *p.Jitter > 0 {
+		backoff *= 1 + *p.Jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(backoff)
+}
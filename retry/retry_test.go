@@ -0,0 +1,116 @@
+package retry
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	p := Policy{InitialBackoff: Duration(time.Second), MaxBackoff: Duration(10 * time.Second), Multiplier: 2}
+	for attempt, want := range map[int]time.Duration{
+		1: time.Second,
+		2: 2 * time.Second,
+		3: 4 * time.Second,
+		4: 8 * time.Second,
+		5: 10 * time.Second, // capped
+		9: 10 * time.Second,
+	} {
+		if got := p.Backoff(attempt); got != want {
+			t.Errorf("Backoff(%d) = %s, want %s", attempt, got, want)
+		}
+	}
+
+	p.Jitter = fraction(0.2)
+	for attempt := 1; attempt <= 6; attempt++ {
+		base := min(time.Second<<(attempt-1), 10*time.Second)
+		low, high := time.Duration(float64(base)*0.8), time.Duration(float64(base)*1.2)
+		for range 100 {
+			if got := p.Backoff(attempt); got < low || got > high {
+				t.Fatalf("Backoff(%d) = %s, want between %s and %s", attempt, got, low, high)
+			}
+		}
+	}
+}
+
+func TestWithDefaults(t *testing.T) {
+	def := DefaultPolicy()
+	if got := (*Policy)(nil).WithDefaults(); !equal(got, def) {
+		t.Errorf("nil.WithDefaults() = %+v, want %+v", got, def)
+	}
+	if got := (&Policy{}).WithDefaults(); !equal(got, def) {
+		t.Errorf("zero.WithDefaults() = %+v, want %+v", got, def)
+	}
+
+	custom := Policy{MaxAttempts: 5, InitialBackoff: Duration(10 * time.Second), Jitter: fraction(0.5), RetryableStatus: []int{503}}
+	want := def
+	want.MaxAttempts, want.InitialBackoff, want.Jitter, want.RetryableStatus = 5, Duration(10*time.Second), fraction(0.5), []int{503}
+	if got := custom.WithDefaults(); !equal(got, want) {
+		t.Errorf("WithDefaults() = %+v, want %+v", got, want)
+	}
+
+	partial := Policy{MaxAttempts: 2}
+	if got := partial.WithDefaults(); !equal(got, Policy{MaxAttempts: 2, InitialBackoff: def.InitialBackoff,
+		MaxBackoff: def.MaxBackoff, Multiplier: def.Multiplier, Jitter: def.Jitter, RetryableStatus: def.RetryableStatus}) {
+		t.Errorf("WithDefaults() = %+v, want the defaults but for maxAttempts", got)
+	}
+}
+
+func TestZeroJitter(t *testing.T) {
+	var p Policy
+	if err := json.Unmarshal([]byte(`{"initialBackoff":"1s","jitter":0}`), &p); err != nil {
+		t.Fatal(err)
+	}
+	merged := p.WithDefaults()
+	if merged.Jitter == nil || *merged.Jitter != 0 {
+		t.Fatalf("WithDefaults() jitter = %v, want 0 kept", merged.Jitter)
+	}
+	for range 20 {
+		if got := merged.Backoff(2); got != 2*time.Second {
+			t.Fatalf("Backoff(2) = %s without jitter, want exactly 2s", got)
+		}
+	}
+
+	data, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored Policy
+	if err := json.Unmarshal(data, &stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Jitter == nil || *stored.Jitter != 0 {
+		t.Errorf("jitter 0 did not survive storage as %s", data)
+	}
+}
+
+// fraction returns a pointer to f, for Policy.Jitter.
+func fraction(f float64) *float64 {
+	return &f
+}
+
+func equal(a, b Policy) bool {
+	return a.MaxAttempts == b.MaxAttempts && a.InitialBackoff == b.InitialBackoff && a.MaxBackoff == b.MaxBackoff &&
+		a.Multiplier == b.Multiplier && (a.Jitter == nil) == (b.Jitter == nil) && (a.Jitter == nil || *a.Jitter == *b.Jitter) &&
+		slices.Equal(a.RetryableStatus, b.RetryableStatus)
+}
+
+func TestValidate(t *testing.T) {
+	for _, p := range []Policy{
+		{MaxAttempts: 21},
+		{InitialBackoff: Duration(-time.Second)},
+		{InitialBackoff: Duration(time.Minute), MaxBackoff: Duration(time.Second)},
+		{Multiplier: 0.5},
+		{Jitter: fraction(1.5)},
+		{Jitter: fraction(-0.1)},
+		{RetryableStatus: []int{999}},
+	} {
+		if err := p.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded, want an error", p)
+		}
+	}
+	if def := DefaultPolicy(); def.Validate() != nil {
+		t.Errorf("DefaultPolicy() is invalid: %v", def.Validate())
+	}
+}
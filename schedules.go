@@ -0,0 +1,767 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/robfig/cron/v3"
+
+	"scheduler/calendar"
+	"scheduler/retry"
+	"scheduler/store"
+)
+
+const (
+	// ScheduleKindSingle runs Action whenever CronSpec fires.
+	ScheduleKindSingle = "single"
+	// ScheduleKindWindow turns the target on when CronSpec fires and off when
+	// EndCronSpec fires or Duration after the start.
+	ScheduleKindWindow = "window"
+)
+
+type Schedule struct {
+	ID          string         `json:"ID"`
+	Owner       string         `json:"-"` // ownerFromToken of the token that created it
+	Kind        string         `json:"Kind"`
+	ServiceName string         `json:"ServiceName"`
+	ServiceType string         `json:"ServiceType"`      // "project" or "database"
+	Action      string         `json:"Action,omitempty"` // "on" or "off"; empty for windows
+	CronSpec    string         `json:"CronSpec"`
+	EndCronSpec string         `json:"EndCronSpec,omitempty"` // window only
+	Duration    string         `json:"Duration,omitempty"`    // window only, alternative to EndCronSpec
+	Timezone    string         `json:"Timezone,omitempty"`    // IANA zone; empty means the server's local zone
+	Calendar    *calendar.Rule `json:"Calendar,omitempty"`
+	Retry       *retry.Policy  `json:"Retry,omitempty"` // nil means retry.DefaultPolicy
+	Enabled     bool           `json:"Enabled"`
+	JobID       cron.EntryID   `json:"-"` // runtime cron entry, changes on every restart; 0 while disabled
+	EndJobID    cron.EntryID   `json:"-"` // runtime cron entry of a window's end
+	NextRun     *time.Time     `json:"NextRun,omitempty"`
+	LastRun     *time.Time     `json:"LastRun,omitempty"`
+	NextEnd     *time.Time     `json:"NextEnd,omitempty"`
+	LastEnd     *time.Time     `json:"LastEnd,omitempty"`
+
+	token string // kept in memory only, to re-register the job after an update
+}
+
+type SchedulesResponse struct {
+	CurrentTime   time.Time  `json:"currentTime"`
+	AccountPaused bool       `json:"accountPaused"`
+	Schedules     []Schedule `json:"schedules"`
+}
+
+type ScheduleRequest struct {
+	Kind        string         `json:"kind"` // "single" (default) or "window"
+	Service     string         `json:"service"`
+	ServiceType string         `json:"serviceType"` // "project" or "database"
+	Action      string         `json:"action"`      // single schedules only
+	Cron        string         `json:"cron"`        // the start of a window
+	EndCron     string         `json:"endCron"`     // window end, or use duration
+	Duration    string         `json:"duration"`    // window length as a Go duration, e.g. "12h"
+	Timezone    string         `json:"timezone"`    // IANA zone, e.g. "Asia/Tehran"; empty means the server's local zone
+	Calendar    *calendar.Rule `json:"calendar"`    // optional Jalali calendar and holiday restrictions
+	Retry       *retry.Policy  `json:"retry"`       // optional, overrides the default retry policy
+}
+
+// validateTimezone checks tz against the tz database and rejects cron specs
+// that already carry their own zone, so the two cannot disagree.
+func validateTimezone(tz, cronSpec string) error {
+	if tz == "" {
+		return nil
+	}
+	if _, err := time.LoadLocation(tz); err != nil {
+		return fmt.Errorf("unknown time zone %s", tz)
+	}
+	if strings.HasPrefix(cronSpec, "CRON_TZ=") || strings.HasPrefix(cronSpec, "TZ=") {
+		return fmt.Errorf("cron expression already sets a time zone")
+	}
+	return nil
+}
+
+// validateSchedule checks everything about s that can be rejected up front,
+// including whether its cron expression parses.
+func validateSchedule(s Schedule) error {
+	switch s.Kind {
+	case ScheduleKindSingle:
+		if s.ServiceName == "" || (s.ServiceType != "project" && s.ServiceType != "database") || (s.Action != "on" && s.Action != "off") || s.CronSpec == "" {
+			return fmt.Errorf("Invalid input: service, serviceType, action, and cron are required")
+		}
+		if s.EndCronSpec != "" || s.Duration != "" {
+			return fmt.Errorf("Invalid input: endCron and duration are only valid for window schedules")
+		}
+	case ScheduleKindWindow:
+		if s.ServiceName == "" || (s.ServiceType != "project" && s.ServiceType != "database") || s.CronSpec == "" {
+			return fmt.Errorf("Invalid input: service, serviceType, and cron are required")
+		}
+		if s.Action != "" {
+			return fmt.Errorf("Invalid input: window schedules turn the target on at the start and off at the end, action must be empty")
+		}
+		if (s.EndCronSpec == "") == (s.Duration == "") {
+			return fmt.Errorf("Invalid input: window schedules need exactly one of endCron and duration")
+		}
+		if s.Duration != "" {
+			if _, err := parseWindowDuration(s.Duration); err != nil {
+				return fmt.Errorf("Invalid duration: %w", err)
+			}
+		}
+	default:
+		return fmt.Errorf("Invalid input: kind must be single or window")
+	}
+
+	if err := validateTimezone(s.Timezone, s.CronSpec); err != nil {
+		return fmt.Errorf("Invalid timezone: %w", err)
+	}
+	if err := validateTimezone(s.Timezone, s.EndCronSpec); err != nil {
+		return fmt.Errorf("Invalid timezone: %w", err)
+	}
+	if s.Calendar != nil {
+		if err := s.Calendar.Validate(); err != nil {
+			return fmt.Errorf("Invalid calendar rule: %w", err)
+		}
+	}
+	if s.Retry != nil {
+		if err := s.Retry.Validate(); err != nil {
+			return fmt.Errorf("Invalid retry policy: %w", err)
+		}
+	}
+	if _, err := cron.ParseStandard(cronSpecWithTimezone(s.CronSpec, s.Timezone)); err != nil {
+		return fmt.Errorf("Invalid cron expression: %w", err)
+	}
+	if s.EndCronSpec != "" {
+		if _, err := cron.ParseStandard(cronSpecWithTimezone(s.EndCronSpec, s.Timezone)); err != nil {
+			return fmt.Errorf("Invalid end cron expression: %w", err)
+		}
+	}
+	return nil
+}
+
+// cronSpecWithTimezone prefixes a cron expression with CRON_TZ so robfig/cron
+// evaluates it in the schedule's zone.
+func cronSpecWithTimezone(spec, tz string) string {
+	if tz == "" {
+		return spec
+	}
+	return "CRON_TZ=" + tz + " " + spec
+}
+
+// location returns the zone the schedule is evaluated in.
+func (s Schedule) location() *time.Location {
+	if s.Timezone != "" {
+		if loc, err := time.LoadLocation(s.Timezone); err == nil {
+			return loc
+		}
+	}
+	return time.Local
+}
+
+// registerSchedule adds the cron entries of s and records their IDs in it:
+// one entry for a single schedule, a start and an end entry for a window.
+// Callers hold mu.
+func registerSchedule(s *Schedule) error {
+	start, err := cron.ParseStandard(cronSpecWithTimezone(s.CronSpec, s.Timezone))
+	if err != nil {
+		return err
+	}
+
+	snapshot := *s
+	if s.Kind != ScheduleKindWindow {
+		s.JobID = scheduler.Schedule(start, cron.FuncJob(func() {
+			runSchedule(snapshot, snapshot.Action, start, nil)
+		}))
+		return nil
+	}
+
+	end, err := windowEnd(snapshot, start)
+	if err != nil {
+		return err
+	}
+	s.JobID = scheduler.Schedule(start, cron.FuncJob(func() {
+		runSchedule(snapshot, "on", start, nil)
+	}))
+	s.EndJobID = scheduler.Schedule(end, cron.FuncJob(func() {
+		runSchedule(snapshot, "off", end, start)
+	}))
+	return nil
+}
+
+// unregisterSchedule removes the cron entries of s and clears their IDs.
+// Callers hold mu.
+func unregisterSchedule(s *Schedule) {
+	if s.JobID != 0 {
+		scheduler.Remove(s.JobID)
+		s.JobID = 0
+	}
+	if s.EndJobID != 0 {
+		scheduler.Remove(s.EndJobID)
+		s.EndJobID = 0
+	}
+}
+
+// runSchedule turns the schedule's target on or off unless its calendar rule
+// excludes the day, retrying failures under the schedule's retry policy, and
+// records the run in the execution history. sched is the cron schedule that
+// fired. For the end of a window, windowStart is the window's start schedule
+// and the calendar is consulted for when the window was opened, so a window
+// skipped in the morning is skipped in the evening too, and one opened on
+// the evening before a day off is still closed the next morning.
+func runSchedule(s Schedule, action string, sched, windowStart cron.Schedule) {
+	now := time.Now()
+	planned := plannedTime(sched, now)
+	scheduleRunLag.Set(now.Sub(planned).Seconds())
+	a := scaleAction{owner: s.Owner, scheduleID: s.ID, serviceType: s.ServiceType, serviceName: s.ServiceName,
+		action: action, token: s.token, logger: accountLogger(s.Owner).With(logKeySchedule, s.ID, logKeyTarget, s.ServiceName)}
+
+	if reason, skip := s.calendarSkip(planned, windowStart); skip {
+		a.logger.Info(fmt.Sprintf("Skipped turning %s %s %s", action, s.ServiceType, s.ServiceName), "reason", reason)
+		recordSkippedExecution(a, planned, reason)
+		return
+	}
+
+	executeAction(a, s.Retry.WithDefaults(), planned)
+}
+
+// calendarSkip reports whether the schedule's calendar rule skips what it does
+// at t, and why. Inside a window, given its start schedule, the rule is
+// applied to when the window was opened: an overnight window opened on a
+// work day is closed the next morning even if that is a day off.
+func (s Schedule) calendarSkip(t time.Time, windowStart cron.Schedule) (string, bool) {
+	if windowStart != nil {
+		if opened, ok := windowOpenedAt(windowStart, t); ok {
+			t = opened
+		}
+	}
+	return s.Calendar.Skip(t.In(s.location()), holidaysFor(s.Owner))
+}
+
+func scheduleHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token, err := getTokenFromContext(r.Context())
+	if err != nil {
+		http.Error(w, `{"error": "Authentication required"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var req ScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	owner, err := getOwnerFromContext(r.Context())
+	if err != nil {
+		http.Error(w, `{"error": "Authentication required"}`, http.StatusUnauthorized)
+		return
+	}
+
+	logger := loggerFromContext(r.Context())
+
+	kind := req.Kind
+	if kind == "" {
+		kind = ScheduleKindSingle
+	}
+
+	scheduleID := uuid.NewString()
+	schedule := Schedule{ID: scheduleID, Owner: owner, Kind: kind, ServiceName: req.Service, ServiceType: req.ServiceType, Action: req.Action,
+		CronSpec: req.Cron, EndCronSpec: req.EndCron, Duration: req.Duration, Timezone: req.Timezone, Calendar: req.Calendar, Retry: req.Retry, Enabled: true, token: token}
+
+	if err := validateSchedule(schedule); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "%v"}`, err), http.StatusBadRequest)
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if shouldRun(schedule) {
+		if err := registerSchedule(&schedule); err != nil {
+			http.Error(w, fmt.Sprintf(`{"error": "Invalid cron expression: %v"}`, err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := insertSchedule(schedule); err != nil {
+		unregisterSchedule(&schedule)
+		logger.Error("Error saving schedule", "error", err)
+		http.Error(w, `{"error": "Failed to save schedule to store"}`, http.StatusInternalServerError)
+		return
+	}
+	logger.Info("Schedule added", logKeySchedule, schedule.ID, logKeyTarget, schedule.ServiceName, "cron", schedule.CronSpec)
+
+	schedules = append(schedules, schedule)
+	publishEvent(owner, EventScheduleCreated, schedule)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Schedule added successfully", "id": scheduleID})
+}
+
+// insertSchedule stores a new schedule together with its encrypted token.
+func insertSchedule(s Schedule) error {
+	record, err := storedSchedule(s)
+	if err != nil {
+		return err
+	}
+	record.TokenCiphertext, err = encryptToken(s.Owner, s.token)
+	if err != nil {
+		return fmt.Errorf("encrypting token: %w", err)
+	}
+	return dataStore.CreateSchedule(record)
+}
+
+// storedSchedule converts s into its stored form, without its token.
+func storedSchedule(s Schedule) (store.Schedule, error) {
+	calendarRule, err := marshalCalendarRule(s.Calendar)
+	if err != nil {
+		return store.Schedule{}, fmt.Errorf("encoding calendar rule: %w", err)
+	}
+	retryPolicy, err := marshalRetryPolicy(s.Retry)
+	if err != nil {
+		return store.Schedule{}, fmt.Errorf("encoding retry policy: %w", err)
+	}
+	return store.Schedule{ID: s.ID, Owner: s.Owner, Kind: s.Kind, ServiceName: s.ServiceName, ServiceType: s.ServiceType, Action: s.Action,
+		CronSpec: s.CronSpec, EndCronSpec: s.EndCronSpec, Duration: s.Duration, Timezone: s.Timezone,
+		CalendarRule: calendarRule, RetryPolicy: retryPolicy, Enabled: s.Enabled}, nil
+}
+
+func schedulesHandler(w http.ResponseWriter, r *http.Request) {
+	owner, err := getOwnerFromContext(r.Context())
+	if err != nil {
+		http.Error(w, `{"error": "Authentication required"}`, http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(accountSchedules(owner))
+}
+
+// accountSchedules returns the owner's schedules with their run times, as
+// listed by the API and the Telegram bot.
+func accountSchedules(owner string) SchedulesResponse {
+	mu.Lock()
+	currentSchedules := make([]Schedule, 0, len(schedules))
+	for _, s := range schedules {
+		if s.Owner == owner {
+			currentSchedules = append(currentSchedules, s)
+		}
+	}
+	accountPaused := pausedAccounts[owner]
+	mu.Unlock()
+
+	for i := range currentSchedules {
+		s := &currentSchedules[i]
+		s.NextRun, s.LastRun = entryRunTimes(s.JobID, s.location())
+		s.NextEnd, s.LastEnd = entryRunTimes(s.EndJobID, s.location())
+	}
+
+	return SchedulesResponse{
+		CurrentTime:   time.Now(),
+		AccountPaused: accountPaused,
+		Schedules:     currentSchedules,
+	}
+}
+
+// entryRunTimes returns the next and previous run of a cron entry in loc, or
+// nil for times that do not exist.
+func entryRunTimes(id cron.EntryID, loc *time.Location) (next, prev *time.Time) {
+	if id == 0 {
+		return nil, nil
+	}
+	entry := scheduler.Entry(id)
+	if !entry.Next.IsZero() {
+		t := entry.Next.In(loc)
+		next = &t
+	}
+	if !entry.Prev.IsZero() {
+		t := entry.Prev.In(loc)
+		prev = &t
+	}
+	return next, prev
+}
+
+func deleteScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	owner, err := getOwnerFromContext(r.Context())
+	if err != nil {
+		http.Error(w, `{"error": "Authentication required"}`, http.StatusUnauthorized)
+		return
+	}
+
+	pathParts := strings.Split(r.URL.Path, "/")
+	if len(pathParts) < 4 || pathParts[3] == "" {
+		http.Error(w, `{"error": "Invalid request: schedule ID missing"}`, http.StatusBadRequest)
+		return
+	}
+
+	parsedID, err := uuid.Parse(pathParts[3])
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Invalid schedule ID format: %v"}`, err), http.StatusBadRequest)
+		return
+	}
+	scheduleID := parsedID.String()
+	logger := loggerFromContext(r.Context())
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	// Schedules of other owners are reported as missing rather than forbidden
+	// so that IDs of other accounts cannot be probed.
+	var removed Schedule
+	found := false
+	for i, s := range schedules {
+		if s.ID == scheduleID && s.Owner == owner {
+			removed = s
+			schedules = append(schedules[:i], schedules[i+1:]...)
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		http.Error(w, `{"error": "Schedule not found"}`, http.StatusNotFound)
+		return
+	}
+
+	unregisterSchedule(&removed)
+
+	// Another replica may have deleted it from the store already.
+	if err := dataStore.DeleteSchedule(owner, scheduleID); err != nil && !errors.Is(err, store.ErrNotFound) {
+		logger.Error("Error deleting schedule", "error", err)
+		http.Error(w, `{"error": "Failed to delete schedule from store"}`, http.StatusInternalServerError)
+		return
+	}
+	logger.Info("Schedule deleted", logKeySchedule, scheduleID, logKeyTarget, removed.ServiceName)
+	publishEvent(owner, EventScheduleDeleted, removed)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Schedule deleted successfully"})
+}
+
+// ScheduleUpdateRequest changes only the fields that are present.
+type ScheduleUpdateRequest struct {
+	Service     *string                 `json:"service"`
+	ServiceType *string                 `json:"serviceType"`
+	Action      *string                 `json:"action"`
+	Cron        *string                 `json:"cron"`
+	EndCron     *string                 `json:"endCron"`
+	Duration    *string                 `json:"duration"`
+	Timezone    *string                 `json:"timezone"`
+	Calendar    optional[calendar.Rule] `json:"calendar"`
+	Retry       optional[retry.Policy]  `json:"retry"`
+	Enabled     *bool                   `json:"enabled"`
+}
+
+// optional is a field of a partial update that can also be cleared: it is
+// left alone when absent, cleared by null and replaced by anything else.
+type optional[T any] struct {
+	Set   bool
+	Value *T // nil to clear
+}
+
+func (o *optional[T]) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	if string(data) == "null" {
+		o.Value = nil
+		return nil
+	}
+	o.Value = new(T)
+	return json.Unmarshal(data, o.Value)
+}
+
+// scheduleItemHandler routes requests under /schedules/{id}.
+func scheduleItemHandler(w http.ResponseWriter, r *http.Request) {
+	pathParts := strings.Split(strings.TrimPrefix(r.URL.Path, "/schedules/"), "/")
+	parsedID, err := uuid.Parse(pathParts[0])
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Invalid schedule ID format: %v"}`, err), http.StatusBadRequest)
+		return
+	}
+	scheduleID := parsedID.String()
+
+	switch {
+	case len(pathParts) == 1:
+		if r.Method != http.MethodPatch {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		updateScheduleHandler(w, r, scheduleID)
+	case len(pathParts) == 2 && (pathParts[1] == "pause" || pathParts[1] == "resume"):
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		setScheduleEnabledHandler(w, r, scheduleID, pathParts[1] == "resume")
+	case len(pathParts) == 2 && pathParts[1] == "executions":
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		executionsHandler(w, r, scheduleID)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// updateScheduleHandler applies a partial update to a schedule.
+func updateScheduleHandler(w http.ResponseWriter, r *http.Request, scheduleID string) {
+	owner, err := getOwnerFromContext(r.Context())
+	if err != nil {
+		http.Error(w, `{"error": "Authentication required"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var req ScheduleUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	updated, err := updateSchedule(owner, scheduleID, func(s *Schedule) {
+		if req.Service != nil {
+			s.ServiceName = *req.Service
+		}
+		if req.ServiceType != nil {
+			s.ServiceType = *req.ServiceType
+		}
+		if req.Action != nil {
+			s.Action = *req.Action
+		}
+		if req.Cron != nil {
+			s.CronSpec = *req.Cron
+		}
+		if req.EndCron != nil {
+			s.EndCronSpec = *req.EndCron
+		}
+		if req.Duration != nil {
+			s.Duration = *req.Duration
+		}
+		if req.Timezone != nil {
+			s.Timezone = *req.Timezone
+		}
+		if req.Calendar.Set {
+			s.Calendar = req.Calendar.Value
+		}
+		if req.Retry.Set {
+			s.Retry = req.Retry.Value
+		}
+		if req.Enabled != nil {
+			s.Enabled = *req.Enabled
+		}
+	})
+	if err != nil {
+		writeScheduleUpdateError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(updated)
+}
+
+// setScheduleEnabledHandler pauses or resumes a single schedule.
+func setScheduleEnabledHandler(w http.ResponseWriter, r *http.Request, scheduleID string, enabled bool) {
+	owner, err := getOwnerFromContext(r.Context())
+	if err != nil {
+		http.Error(w, `{"error": "Authentication required"}`, http.StatusUnauthorized)
+		return
+	}
+
+	updated, err := updateSchedule(owner, scheduleID, func(s *Schedule) {
+		s.Enabled = enabled
+	})
+	if err != nil {
+		writeScheduleUpdateError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(updated)
+}
+
+// scheduleUpdateError carries the HTTP status an update failed with.
+type scheduleUpdateError struct {
+	status  int
+	message string
+}
+
+func (e *scheduleUpdateError) Error() string {
+	return e.message
+}
+
+func writeScheduleUpdateError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var updateErr *scheduleUpdateError
+	if errors.As(err, &updateErr) {
+		status = updateErr.status
+	}
+	http.Error(w, fmt.Sprintf(`{"error": "%v"}`, err), status)
+}
+
+// updateSchedule applies apply to the owner's schedule and makes the change
+// everywhere at once: the stored schedule, the cron entry and the in-memory
+// slice. The new job is registered before the store is updated and removed
+// again if that fails, so a failure leaves the old schedule running
+// untouched.
+func updateSchedule(owner, scheduleID string, apply func(*Schedule)) (Schedule, error) {
+	logger := accountLogger(owner).With(logKeySchedule, scheduleID)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	index := -1
+	for i, s := range schedules {
+		if s.ID == scheduleID && s.Owner == owner {
+			index = i
+			break
+		}
+	}
+	if index == -1 {
+		return Schedule{}, &scheduleUpdateError{http.StatusNotFound, "Schedule not found"}
+	}
+
+	current := schedules[index]
+	updated := current
+	apply(&updated)
+
+	if err := validateSchedule(updated); err != nil {
+		return Schedule{}, &scheduleUpdateError{http.StatusBadRequest, err.Error()}
+	}
+
+	updated.JobID, updated.EndJobID = 0, 0
+	if shouldRun(updated) {
+		if err := registerSchedule(&updated); err != nil {
+			return Schedule{}, &scheduleUpdateError{http.StatusBadRequest, fmt.Sprintf("Invalid cron expression: %v", err)}
+		}
+	}
+
+	record, err := storedSchedule(updated)
+	if err == nil {
+		err = dataStore.UpdateSchedule(record)
+	}
+	if err != nil {
+		unregisterSchedule(&updated)
+		if errors.Is(err, store.ErrNotFound) {
+			return Schedule{}, &scheduleUpdateError{http.StatusNotFound, "Schedule not found"}
+		}
+		logger.Error("Error updating schedule", "error", err)
+		return Schedule{}, &scheduleUpdateError{http.StatusInternalServerError, "Failed to update schedule in store"}
+	}
+
+	unregisterSchedule(&current)
+	schedules[index] = updated
+	logger.Info("Schedule updated", logKeyTarget, updated.ServiceName, "cron", updated.CronSpec, "enabled", updated.Enabled)
+	publishEvent(owner, EventScheduleUpdated, updated)
+	return updated, nil
+}
+
+// sameDefinition reports whether a and b would be registered identically.
+func sameDefinition(a, b Schedule) bool {
+	a.JobID, a.EndJobID, b.JobID, b.EndJobID = 0, 0, 0, 0
+	a.NextRun, a.LastRun, a.NextEnd, a.LastEnd = nil, nil, nil, nil
+	b.NextRun, b.LastRun, b.NextEnd, b.LastEnd = nil, nil, nil, nil
+	return reflect.DeepEqual(a, b)
+}
+
+// loadSchedules replaces the schedules in memory with those in the store and
+// registers them, each with the token it was created with.
+func loadSchedules() {
+	records, err := dataStore.Schedules()
+	if err != nil {
+		log.Printf("Error loading schedules: %v", err)
+		return
+	}
+
+	type storedSchedule struct {
+		Schedule
+		tokenCiphertext string
+	}
+	var stored []storedSchedule
+	for _, record := range records {
+		s := storedSchedule{Schedule: Schedule{ID: record.ID, Owner: record.Owner, Kind: record.Kind, ServiceName: record.ServiceName,
+			ServiceType: record.ServiceType, Action: record.Action, CronSpec: record.CronSpec, EndCronSpec: record.EndCronSpec,
+			Duration: record.Duration, Timezone: record.Timezone, Enabled: record.Enabled}, tokenCiphertext: record.TokenCiphertext}
+		if record.CalendarRule != nil {
+			s.Calendar = new(calendar.Rule)
+			if err := json.Unmarshal(record.CalendarRule, s.Calendar); err != nil {
+				log.Printf("Error decoding calendar rule of schedule %s: %v", s.ID, err)
+				continue
+			}
+		}
+		if record.RetryPolicy != nil {
+			s.Retry = new(retry.Policy)
+			if err := json.Unmarshal(record.RetryPolicy, s.Retry); err != nil {
+				log.Printf("Error decoding retry policy of schedule %s: %v", s.ID, err)
+				continue
+			}
+		}
+		stored = append(stored, s)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	// Schedules created by this server keep their token in memory even when
+	// it is not stored, and unchanged schedules keep their cron entries so a
+	// reload cannot make them miss a run.
+	existing := make(map[string]Schedule, len(schedules))
+	for _, s := range schedules {
+		existing[s.ID] = s
+	}
+
+	loaded := make([]Schedule, 0, len(stored))
+	active := 0
+	for _, row := range stored {
+		s := row.Schedule
+		old, known := existing[s.ID]
+		delete(existing, s.ID)
+
+		capturedToken, err := storedToken(s.Owner, row.tokenCiphertext)
+		if err != nil && !known {
+			log.Printf("Cannot re-add stored schedule for ServiceName=%s: %v", s.ServiceName, err)
+			continue
+		}
+		if err != nil {
+			capturedToken = old.token
+		}
+		s.token = capturedToken
+
+		run := shouldRun(s)
+		if known && sameDefinition(old, s) && (old.JobID != 0) == run {
+			s.JobID, s.EndJobID = old.JobID, old.EndJobID
+		} else {
+			if known {
+				unregisterSchedule(&old)
+			}
+			if run {
+				if err := registerSchedule(&s); err != nil {
+					log.Printf("Error re-adding stored cron job: %v", err)
+					continue
+				}
+			}
+		}
+		if run {
+			active++
+		}
+		loaded = append(loaded, s)
+	}
+	for _, removed := range existing {
+		unregisterSchedule(&removed)
+	}
+	schedules = loaded
+	log.Printf("Loaded %d stored schedules, %d of them active.", len(loaded), active)
+}
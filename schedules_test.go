@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/robfig/cron/v3"
+
+	"scheduler/store"
+)
+
+// failingUpdateStore is a store whose schedule updates always fail.
+type failingUpdateStore struct {
+	store.Store
+}
+
+func (failingUpdateStore) UpdateSchedule(store.Schedule) error {
+	return errors.New("store unavailable")
+}
+
+func TestUpdateScheduleRollsBackOnStoreError(t *testing.T) {
+	s := createSchedule(t, `{"service":"app","serviceType":"project","action":"on","cron":"0 8 * * *"}`)
+	entries := len(scheduler.Entries())
+
+	saved := dataStore
+	dataStore = failingUpdateStore{saved}
+	defer func() { dataStore = saved }()
+
+	_, err := updateSchedule(testOwner, s.ID, func(s *Schedule) {
+		s.CronSpec = "30 9 * * *"
+	})
+	var updateErr *scheduleUpdateError
+	if !errors.As(err, &updateErr) || updateErr.status != http.StatusInternalServerError {
+		t.Fatalf("updateSchedule error = %v, want a 500 scheduleUpdateError", err)
+	}
+
+	if got := len(scheduler.Entries()); got != entries {
+		t.Errorf("%d cron entries after the failed update, want %d", got, entries)
+	}
+	entry := scheduler.Entry(s.JobID)
+	if !entry.Valid() {
+		t.Fatalf("cron entry %d of the old schedule was removed", s.JobID)
+	}
+	old, _ := cron.ParseStandard("0 8 * * *")
+	now := time.Now()
+	if got, want := entry.Schedule.Next(now), old.Next(now); !got.Equal(want) {
+		t.Errorf("cron entry fires next at %s, want %s from the old spec", got, want)
+	}
+	if current, _ := findSchedule(s.ID); current.CronSpec != "0 8 * * *" || current.JobID != s.JobID {
+		t.Errorf("in-memory schedule changed to %q (job %d)", current.CronSpec, current.JobID)
+	}
+}
+
+func TestUpdateScheduleClearsCalendarAndRetry(t *testing.T) {
+	s := createSchedule(t, `{"service":"app","serviceType":"project","action":"on","cron":"0 8 * * *",
+		"calendar":{"workWeekOnly":true},"retry":{"maxAttempts":2}}`)
+	path := "/schedules/" + s.ID
+
+	if code, body := serve(t, scheduleItemHandler, http.MethodPatch, path, `{"cron":"0 9 * * *"}`); code != http.StatusOK {
+		t.Fatalf("PATCH: %d %s", code, body)
+	}
+	if s, _ = findSchedule(s.ID); s.Calendar == nil || s.Retry == nil {
+		t.Fatalf("absent fields were cleared: calendar %v, retry %v", s.Calendar, s.Retry)
+	}
+
+	if code, body := serve(t, scheduleItemHandler, http.MethodPatch, path, `{"calendar":null}`); code != http.StatusOK {
+		t.Fatalf("PATCH: %d %s", code, body)
+	}
+	if s, _ = findSchedule(s.ID); s.Calendar != nil || s.Retry == nil {
+		t.Fatalf("after clearing the calendar: calendar %v, retry %v", s.Calendar, s.Retry)
+	}
+
+	if code, body := serve(t, scheduleItemHandler, http.MethodPatch, path, `{"retry":null}`); code != http.StatusOK {
+		t.Fatalf("PATCH: %d %s", code, body)
+	}
+	if s, _ = findSchedule(s.ID); s.Retry != nil {
+		t.Fatalf("retry policy %v not cleared", s.Retry)
+	}
+
+	stored, err := dataStore.Schedules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range stored {
+		if record.ID == s.ID && (record.CalendarRule != nil || record.RetryPolicy != nil) {
+			t.Errorf("stored schedule keeps calendar %s and retry %s", record.CalendarRule, record.RetryPolicy)
+		}
+	}
+}
+
+func TestSchedulesAreIsolatedByAccount(t *testing.T) {
+	s := createSchedule(t, `{"service":"app","serviceType":"project","action":"on","cron":"0 8 * * *"}`)
+	if err := dataStore.AddExecution(Execution{ID: uuid.NewString(), ScheduleID: s.ID, Owner: testOwner, ServiceType: "project",
+		ServiceName: "app", Action: "on", PlannedAt: time.Now(), Attempts: 1, Outcome: ExecutionSucceeded}); err != nil {
+		t.Fatal(err)
+	}
+	const otherToken = "other-token"
+	path := "/schedules/" + s.ID
+
+	code, body := serveAs(t, otherToken, schedulesHandler, http.MethodGet, "/schedules", "")
+	if code != http.StatusOK || strings.Contains(body, s.ID) {
+		t.Errorf("other account's schedule list = %d %s, want 200 without %s", code, body, s.ID)
+	}
+
+	for _, req := range []struct {
+		name               string
+		handler            http.HandlerFunc
+		method, path, body string
+	}{
+		{"update", scheduleItemHandler, http.MethodPatch, path, `{"cron":"30 9 * * *"}`},
+		{"pause", scheduleItemHandler, http.MethodPost, path + "/pause", ""},
+		{"resume", scheduleItemHandler, http.MethodPost, path + "/resume", ""},
+		{"executions", scheduleItemHandler, http.MethodGet, path + "/executions", ""},
+		{"delete", deleteScheduleHandler, http.MethodDelete, "/schedule/delete/" + s.ID, ""},
+	} {
+		if code, body := serveAs(t, otherToken, req.handler, req.method, req.path, req.body); code != http.StatusNotFound {
+			t.Errorf("%s by another account = %d %s, want 404", req.name, code, body)
+		}
+	}
+
+	current, ok := findSchedule(s.ID)
+	if !ok || current.CronSpec != "0 8 * * *" || !current.Enabled {
+		t.Errorf("schedule after the other account's requests = %+v, %v, want it unchanged", current, ok)
+	}
+	code, body = serve(t, scheduleItemHandler, http.MethodGet, path+"/executions", "")
+	if code != http.StatusOK || !strings.Contains(body, `"total":1`) {
+		t.Errorf("owner's executions = %d %s, want the one execution", code, body)
+	}
+}
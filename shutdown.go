@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const defaultShutdownTimeout = 30 * time.Second
+
+var (
+	// shuttingDown is closed when the server starts shutting down, so
+	// requests that never finish on their own, like event streams, end.
+	shuttingDown = make(chan struct{})
+	// backgroundJobs tracks work started outside the cron scheduler that
+	// scales targets or writes to the store: dead-letter replays, Telegram
+	// commands, reconciler passes and log janitor passes.
+	backgroundJobs sync.WaitGroup
+)
+
+// isShuttingDown reports whether shutdown has begun, after which no new
+// background job may start.
+func isShuttingDown() bool {
+	select {
+	case <-shuttingDown:
+		return true
+	default:
+		return false
+	}
+}
+
+// shutdownTimeout reads SHUTDOWN_TIMEOUT, how long shutdown waits for
+// requests and running jobs to finish.
+func shutdownTimeout() time.Duration {
+	value := os.Getenv("SHUTDOWN_TIMEOUT")
+	if value == "" {
+		return defaultShutdownTimeout
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("Invalid SHUTDOWN_TIMEOUT %q, using %s", value, defaultShutdownTimeout)
+		return defaultShutdownTimeout
+	}
+	return d
+}
+
+// shutdown stops accepting requests, stops the cron scheduler, the reconciler
+// and the log janitor and waits for running scale jobs, reconciler and
+// janitor passes and webhook deliveries, then writes the queued logs, hands
+// over leadership and closes the store. Whatever is still running after
+// timeout is abandoned.
+func shutdown(server *http.Server, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	close(shuttingDown)
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Error stopping HTTP server: %v", err)
+	}
+
+	// The scheduler must not be restarted by a late election.
+	<-electionDone
+
+	jobsDone := make(chan struct{})
+	go func() {
+		<-scheduler.Stop().Done()
+		backgroundJobs.Wait()
+		close(jobsDone)
+	}()
+	select {
+	case <-jobsDone:
+		log.Println("All running jobs finished.")
+	case <-ctx.Done():
+		log.Printf("Jobs still running after %s, stopping anyway.", timeout)
+	}
+
+	// Webhooks and logs get a moment of their own even if the jobs used up
+	// the deadline.
+	deliveriesCtx, cancelDeliveries := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelDeliveries()
+	if err := stopWebhooks(deliveriesCtx); err != nil {
+		log.Printf("Abandoned webhook deliveries still under way: %v", err)
+	}
+
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelFlush()
+	if err := flushLogs(flushCtx); err != nil {
+		log.Printf("Error flushing logs: %v", err)
+	}
+
+	resignLeadership()
+	if err := dataStore.Close(); err != nil {
+		log.Printf("Error closing %s store: %v", storeBackend, err)
+	}
+	log.Println("Server stopped.")
+}
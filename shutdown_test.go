@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// startShutdown gives the test its own shuttingDown channel, restored when it
+// ends, and returns a function that closes it.
+func startShutdown(t *testing.T) func() {
+	t.Helper()
+	saved := shuttingDown
+	shuttingDown = make(chan struct{})
+	t.Cleanup(func() { shuttingDown = saved })
+	return func() { close(shuttingDown) }
+}
+
+// leading makes this replica the leader for the rest of the test.
+func leading(t *testing.T) {
+	t.Helper()
+	was := isLeader.Swap(true)
+	t.Cleanup(func() { isLeader.Store(was) })
+}
+
+// expectReturn fails the test unless loop returns within a second after
+// shutdown begins.
+func expectReturn(t *testing.T, name string, loop func()) {
+	t.Helper()
+	shutdown := startShutdown(t)
+	done := make(chan struct{})
+	go func() {
+		loop()
+		close(done)
+	}()
+	time.Sleep(30 * time.Millisecond)
+	shutdown()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("%s kept running after shutdown", name)
+	}
+}
+
+func TestBackgroundLoopsStopOnShutdown(t *testing.T) {
+	leading(t)
+	expectReturn(t, "reconciler", func() { runReconciler(5 * time.Millisecond) })
+	expectReturn(t, "log janitor", func() {
+		runLogJanitor(logRetention{maxAge: time.Hour, interval: 5 * time.Millisecond, batchSize: defaultPruneBatchSize})
+	})
+}
+
+func TestReconcileStartsNoCorrectionsDuringShutdown(t *testing.T) {
+	var scaleCalls atomic.Int32
+	stubLiara(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`{"projects":[{"project_id":"late","scale":0}]}`))
+			return
+		}
+		scaleCalls.Add(1)
+	})
+	opened := time.Now().Add(-time.Hour)
+	createSchedule(t, fmt.Sprintf(`{"kind":"window","service":"late","serviceType":"project","cron":"%d %d * * *","duration":"2h"}`,
+		opened.Minute(), opened.Hour()))
+
+	startShutdown(t)()
+	reconcile(context.Background(), time.Now())
+	if got := scaleCalls.Load(); got != 0 {
+		t.Errorf("%d scale calls after shutdown began, want none", got)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"scheduler/events"
+)
+
+// Event types published on eventBus.
+const (
+	EventScheduleCreated   = "schedule-created"   // data: Schedule
+	EventScheduleUpdated   = "schedule-updated"   // data: Schedule
+	EventScheduleDeleted   = "schedule-deleted"   // data: Schedule
+	EventExecutionStarted  = "execution-started"  // data: Execution
+	EventExecutionFinished = "execution-finished" // data: Execution
+	EventReconcileDrift    = "reconcile-drift"    // data: DriftEvent
+	EventScaleSucceeded    = "scale-succeeded"    // data: ScaleEvent
+	EventScaleFailed       = "scale-failed"       // data: ScaleEvent
+	EventRetriesExhausted  = "retries-exhausted"  // data: ScaleEvent of the last attempt
+)
+
+const (
+	// eventStreamBuffer is how many events a slow client may fall behind
+	// before it starts missing them.
+	eventStreamBuffer = 64
+	// eventStreamHeartbeat keeps idle connections open through proxies.
+	eventStreamHeartbeat = 30 * time.Second
+)
+
+var eventBus = events.NewBus()
+
+// DriftEvent describes a correction made by the reconciler.
+type DriftEvent struct {
+	ServiceType string   `json:"serviceType"`
+	ServiceName string   `json:"serviceName"`
+	Actual      string   `json:"actual"`  // "on" or "off"
+	Desired     string   `json:"desired"` // "on" or "off"
+	Windows     []string `json:"windows"` // IDs of the windows that decided the desired state
+}
+
+// ScaleEvent describes one call that turned a project or database on or off,
+// from a schedule run, a dead-letter replay, the reconciler or a Telegram
+// command. Webhooks get one per execution instead, describing its last
+// attempt.
+type ScaleEvent struct {
+	ScheduleID  string `json:"scheduleId,omitempty"`
+	ExecutionID string `json:"executionId,omitempty"`
+	ServiceType string `json:"serviceType"`
+	ServiceName string `json:"serviceName"`
+	Action      string `json:"action"`
+	Attempt     int    `json:"attempt,omitempty"`    // counting from 1; absent outside executions
+	StatusCode  int    `json:"statusCode,omitempty"` // 0 if the API was never reached
+	Error       string `json:"error,omitempty"`
+}
+
+// publishEvent publishes an event of the owner's account.
+func publishEvent(owner, eventType string, data any) {
+	eventBus.Publish(events.Event{Type: eventType, Account: owner, Data: data})
+}
+
+// eventsHandler streams the account's events as Server-Sent Events until the
+// client disconnects.
+func eventsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	owner, err := getOwnerFromContext(r.Context())
+	if err != nil {
+		http.Error(w, `{"error": "Authentication required"}`, http.StatusUnauthorized)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, `{"error": "Streaming not supported"}`, http.StatusInternalServerError)
+		return
+	}
+
+	sub := eventBus.Subscribe(eventStreamBuffer, func(e events.Event) bool {
+		return e.Account == owner
+	})
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(eventStreamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-shuttingDown:
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case e := <-sub.C:
+			data, err := json.Marshal(e)
+			if err != nil {
+				loggerFromContext(r.Context()).Error("Error encoding event", "event", e.Type, "error", err)
+				continue
+			}
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+		}
+		flusher.Flush()
+	}
+}
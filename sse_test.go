@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// eventStream is an open /events connection.
+type eventStream struct {
+	lines chan string
+}
+
+// openEventStream connects to /events with token and waits until the stream
+// is subscribed.
+func openEventStream(t *testing.T, url, token string) *eventStream {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("/events = %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	s := &eventStream{lines: make(chan string, 64)}
+	go func() {
+		defer close(s.lines)
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			s.lines <- scanner.Text()
+		}
+	}()
+	if line := s.next(t); line != ": connected" {
+		t.Fatalf("first line = %q, want the connected comment", line)
+	}
+	return s
+}
+
+// next returns the next line of the stream.
+func (s *eventStream) next(t *testing.T) string {
+	t.Helper()
+	select {
+	case line, ok := <-s.lines:
+		if !ok {
+			t.Fatal("stream ended")
+		}
+		return line
+	case <-time.After(2 * time.Second):
+		t.Fatal("no line received")
+		return ""
+	}
+}
+
+// nextEvent returns the event and data lines of the next event.
+func (s *eventStream) nextEvent(t *testing.T) (event, data string) {
+	t.Helper()
+	for {
+		line := s.next(t)
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		case line == "" && event != "":
+			return event, data
+		}
+	}
+}
+
+func TestEventsStreamOnlyOwnAccount(t *testing.T) {
+	// Closed after the streams, which the handlers wait for.
+	srv := httptest.NewServer(authMiddleware(eventsHandler))
+	t.Cleanup(srv.Close)
+	const otherToken = "other-token"
+	otherOwner := ownerFromToken(otherToken)
+
+	mine := openEventStream(t, srv.URL, testToken)
+	theirs := openEventStream(t, srv.URL, otherToken)
+
+	publishEvent(otherOwner, EventReconcileDrift, DriftEvent{ServiceName: "their-app"})
+	publishEvent(testOwner, EventReconcileDrift, DriftEvent{ServiceName: "my-app"})
+
+	if event, data := mine.nextEvent(t); event != EventReconcileDrift || !strings.Contains(data, "my-app") {
+		t.Errorf("own stream got %s %s, want the drift of my-app", event, data)
+	}
+	if event, data := theirs.nextEvent(t); event != EventReconcileDrift || !strings.Contains(data, "their-app") {
+		t.Errorf("other stream got %s %s, want the drift of their-app", event, data)
+	}
+
+	// Neither stream got the other's event before its own.
+	publishEvent(testOwner, EventScheduleDeleted, Schedule{ID: "marker"})
+	if event, data := mine.nextEvent(t); event != EventScheduleDeleted || !strings.Contains(data, "marker") {
+		t.Errorf("own stream got %s %s after its drift, want the marker", event, data)
+	}
+	publishEvent(otherOwner, EventScheduleDeleted, Schedule{ID: "marker"})
+	if event, _ := theirs.nextEvent(t); event != EventScheduleDeleted {
+		t.Errorf("other stream got %s after its drift, want the marker", event)
+	}
+}
@@ -23,6 +23,17 @@ document.addEventListener('DOMContentLoaded', () => {
     // Schedule elements
     const currentTimeDisplay = document.getElementById('current-time-display');
     const currentSchedulesList = document.getElementById('current-schedules');
+    const accountPauseButton = document.getElementById('account-pause-button');
+    const deadLettersList = document.getElementById('dead-letters');
+    const webhooksList = document.getElementById('webhooks');
+    const webhookForm = document.getElementById('webhook-form');
+    const telegramStatus = document.getElementById('telegram-status');
+    const telegramChatsList = document.getElementById('telegram-chats');
+    const telegramForm = document.getElementById('telegram-form');
+    const emailStatus = document.getElementById('email-status');
+    const emailRecipientsList = document.getElementById('email-recipients');
+    const emailForm = document.getElementById('email-form');
+    let accountPaused = false;
 
     // Log and Uptime elements
     const serverLogsPre = document.getElementById('server-logs');
@@ -33,6 +44,7 @@ document.addEventListener('DOMContentLoaded', () => {
     if (liaraToken) {
         showMainAppSection();
         loadAllData();
+        subscribeToEvents();
     } else {
         loginSection.style.display = 'block';
         mainAppSection.style.display = 'none';
@@ -55,6 +67,7 @@ document.addEventListener('DOMContentLoaded', () => {
             loginError.textContent = '';
             showMainAppSection();
             loadAllData();
+            subscribeToEvents();
         } else {
             const errorData = await response.json();
             loginError.textContent = errorData.error || 'Login failed.';
@@ -95,6 +108,9 @@ document.addEventListener('DOMContentLoaded', () => {
         const selectedProject = projectSelect.value;
         const action = document.getElementById('project-action-select').value;
         const cron = document.getElementById('project-cron-input').value;
+        const timezone = document.getElementById('project-timezone-input').value;
+        const calendar = calendarRule('project');
+        const windowEnd = document.getElementById('project-window-end-input').value;
 
         if (!selectedProject) {
             projectError.textContent = 'Please select a project.';
@@ -108,7 +124,7 @@ document.addEventListener('DOMContentLoaded', () => {
                 'Content-Type': 'application/json',
                 'Authorization': `Bearer ${liaraToken}`
             },
-            body: JSON.stringify({ service: selectedProject, serviceType: "project", action, cron }),
+            body: JSON.stringify(scheduleBody(selectedProject, "project", action, cron, windowEnd, timezone, calendar)),
         });
 
         if (response.ok) {
@@ -126,6 +142,9 @@ document.addEventListener('DOMContentLoaded', () => {
         const selectedDatabase = databaseSelect.value;
         const action = document.getElementById('database-action-select').value;
         const cron = document.getElementById('database-cron-input').value;
+        const timezone = document.getElementById('database-timezone-input').value;
+        const calendar = calendarRule('database');
+        const windowEnd = document.getElementById('database-window-end-input').value;
 
         if (!selectedDatabase) {
             databaseError.textContent = 'Please select a database.';
@@ -139,7 +158,7 @@ document.addEventListener('DOMContentLoaded', () => {
                 'Content-Type': 'application/json',
                 'Authorization': `Bearer ${liaraToken}`
             },
-            body: JSON.stringify({ service: selectedDatabase, serviceType: "database", action, cron }),
+            body: JSON.stringify(scheduleBody(selectedDatabase, "database", action, cron, windowEnd, timezone, calendar)),
         });
 
         if (response.ok) {
@@ -152,6 +171,28 @@ document.addEventListener('DOMContentLoaded', () => {
         }
     });
 
+    function scheduleBody(service, serviceType, action, cron, windowEnd, timezone, calendar) {
+        if (action !== 'window') {
+            return { service, serviceType, action, cron, timezone, calendar };
+        }
+        const body = { kind: 'window', service, serviceType, cron, timezone, calendar };
+        if (/^\d+(\.\d+)?(h|m|s)/.test(windowEnd.trim())) {
+            body.duration = windowEnd.trim();
+        } else {
+            body.endCron = windowEnd.trim();
+        }
+        return body;
+    }
+
+    function calendarRule(kind) {
+        const skipHolidays = document.getElementById(`${kind}-skip-holidays-input`).checked;
+        const workWeekOnly = document.getElementById(`${kind}-workweek-input`).checked;
+        if (!skipHolidays && !workWeekOnly) {
+            return null;
+        }
+        return { skipHolidays, workWeekOnly };
+    }
+
     function showMainAppSection() {
         loginSection.style.display = 'none';
         mainAppSection.style.display = 'block';
@@ -161,10 +202,64 @@ document.addEventListener('DOMContentLoaded', () => {
         fetchProjects();
         fetchDatabases();
         fetchSchedules();
+        fetchDeadLetters();
+        fetchWebhooks();
+        fetchTelegramChats();
+        fetchEmailRecipients();
         fetchLogs();
         fetchUptime();
     }
 
+    // subscribeToEvents follows /events and refreshes the affected lists as
+    // soon as something happens. EventSource cannot send the Authorization
+    // header, so the stream is read with fetch.
+    async function subscribeToEvents() {
+        try {
+            const response = await fetch('/events', {
+                headers: {
+                    'Authorization': `Bearer ${liaraToken}`
+                }
+            });
+            if (!response.ok) {
+                throw new Error(`status ${response.status}`);
+            }
+
+            const reader = response.body.getReader();
+            const decoder = new TextDecoder();
+            let buffered = '';
+            while (true) {
+                const { value, done } = await reader.read();
+                if (done) {
+                    break;
+                }
+                buffered += decoder.decode(value, { stream: true });
+                const messages = buffered.split('\n\n');
+                buffered = messages.pop();
+                messages.forEach(handleEventMessage);
+            }
+        } catch (error) {
+            console.error('Event stream error:', error);
+        }
+        setTimeout(subscribeToEvents, 5000);
+    }
+
+    function handleEventMessage(message) {
+        const eventLine = message.split('\n').find(line => line.startsWith('event: '));
+        if (!eventLine) {
+            return; // comment or keep-alive
+        }
+        const type = eventLine.slice('event: '.length);
+        if (type.startsWith('schedule-')) {
+            fetchSchedules();
+        } else if (type === 'execution-finished') {
+            fetchSchedules();
+            fetchDeadLetters();
+            fetchLogs();
+        } else if (type === 'reconcile-drift') {
+            fetchLogs();
+        }
+    }
+
     async function fetchProjects() {
         projectStatusList.innerHTML = '<li>Loading projects...</li>';
         projectSelect.innerHTML = '<option value="">Loading projects...</option>';
@@ -265,6 +360,8 @@ document.addEventListener('DOMContentLoaded', () => {
                 const currentTime = new Date(data.currentTime);
 
                 currentTimeDisplay.textContent = `Current Time: ${formatDate(currentTime)}`;
+                accountPaused = data.accountPaused;
+                accountPauseButton.textContent = accountPaused ? 'Resume All Schedules' : 'Pause All Schedules';
 
                 currentSchedulesList.innerHTML = '';
                 if (schedules.length === 0) {
@@ -273,13 +370,31 @@ document.addEventListener('DOMContentLoaded', () => {
                     schedules.forEach(schedule => {
                         const li = document.createElement('li');
                         let scheduleText = `Service: ${schedule.ServiceName} (${schedule.ServiceType}) | Action: ${schedule.Action} | Cron: ${schedule.CronSpec}`;
+                        if (schedule.Kind === 'window') {
+                            scheduleText = `Service: ${schedule.ServiceName} (${schedule.ServiceType}) | Window: on at ${schedule.CronSpec}, off ${schedule.EndCronSpec ? `at ${schedule.EndCronSpec}` : `after ${schedule.Duration}`}`;
+                        }
+                        if (schedule.Timezone) {
+                            scheduleText += ` | Time Zone: ${schedule.Timezone}`;
+                        }
+                        if (schedule.Calendar && schedule.Calendar.skipHolidays) {
+                            scheduleText += ' | Skips holidays';
+                        }
+                        if (schedule.Calendar && schedule.Calendar.workWeekOnly) {
+                            scheduleText += ' | Sat-Wed only';
+                        }
 
                         if (schedule.LastRun) {
                             scheduleText += ` | Last Run: ${formatDate(new Date(schedule.LastRun))}`;
                         }
+                        if (!schedule.Enabled) {
+                            scheduleText += ' | Paused';
+                        }
                         if (schedule.NextRun) {
                             scheduleText += ` | Next Run: ${formatDate(new Date(schedule.NextRun))}`;
                         }
+                        if (schedule.NextEnd) {
+                            scheduleText += ` | Next End: ${formatDate(new Date(schedule.NextEnd))}`;
+                        }
 
                         li.textContent = scheduleText;
 
@@ -288,9 +403,35 @@ document.addEventListener('DOMContentLoaded', () => {
                         deleteButton.classList.add('delete-button');
                         deleteButton.addEventListener('click', async () => {
                             if (confirm(`Are you sure you want to delete the schedule for ${schedule.ServiceType} "${schedule.ServiceName}"?`)) {
-                                await deleteSchedule(schedule.JobID);
+                                await deleteSchedule(schedule.ID);
+                            }
+                        });
+                        const pauseButton = document.createElement('button');
+                        pauseButton.textContent = schedule.Enabled ? 'Pause' : 'Resume';
+                        pauseButton.classList.add('edit-button');
+                        pauseButton.addEventListener('click', async () => {
+                            await postScheduleAction(`/schedules/${schedule.ID}/${schedule.Enabled ? 'pause' : 'resume'}`);
+                        });
+                        li.appendChild(pauseButton);
+
+                        const editButton = document.createElement('button');
+                        editButton.textContent = 'Edit Cron';
+                        editButton.classList.add('edit-button');
+                        editButton.addEventListener('click', async () => {
+                            const cron = prompt('New cron expression:', schedule.CronSpec);
+                            if (cron && cron !== schedule.CronSpec) {
+                                await updateSchedule(schedule.ID, { cron });
                             }
                         });
+                        li.appendChild(editButton);
+
+                        const historyButton = document.createElement('button');
+                        historyButton.textContent = 'History';
+                        historyButton.classList.add('edit-button');
+                        historyButton.addEventListener('click', async () => {
+                            await showExecutions(schedule.ID);
+                        });
+                        li.appendChild(historyButton);
                         li.appendChild(deleteButton);
                         currentSchedulesList.appendChild(li);
                     });
@@ -306,9 +447,95 @@ document.addEventListener('DOMContentLoaded', () => {
         }
     }
 
-    async function deleteSchedule(jobID) {
+    accountPauseButton.addEventListener('click', async () => {
+        await postScheduleAction(accountPaused ? '/account/resume' : '/account/pause');
+    });
+
+    async function postScheduleAction(url) {
+        try {
+            const response = await fetch(url, {
+                method: 'POST',
+                headers: {
+                    'Authorization': `Bearer ${liaraToken}`
+                }
+            });
+
+            if (response.ok) {
+                fetchSchedules();
+            } else {
+                const errorData = await response.json();
+                alert(`Failed to update schedule: ${errorData.error || 'Unknown error'}`);
+                console.error('Failed to update schedule:', errorData.error);
+            }
+        } catch (error) {
+            alert('Network error or server unavailable.');
+            console.error('Network error:', error);
+        }
+    }
+
+    async function updateSchedule(scheduleID, changes) {
+        try {
+            const response = await fetch(`/schedules/${scheduleID}`, {
+                method: 'PATCH',
+                headers: {
+                    'Content-Type': 'application/json',
+                    'Authorization': `Bearer ${liaraToken}`
+                },
+                body: JSON.stringify(changes),
+            });
+
+            if (response.ok) {
+                fetchSchedules();
+            } else {
+                const errorData = await response.json();
+                alert(`Failed to update schedule: ${errorData.error || 'Unknown error'}`);
+                console.error('Failed to update schedule:', errorData.error);
+            }
+        } catch (error) {
+            alert('Network error or server unavailable.');
+            console.error('Network error:', error);
+        }
+    }
+
+    async function showExecutions(scheduleID) {
+        try {
+            const response = await fetch(`/schedules/${scheduleID}/executions?limit=10`, {
+                headers: {
+                    'Authorization': `Bearer ${liaraToken}`
+                }
+            });
+
+            if (response.ok) {
+                const data = await response.json();
+                if (data.executions.length === 0) {
+                    alert('This schedule has not run yet.');
+                    return;
+                }
+                const lines = data.executions.map(execution => {
+                    let line = `${formatDate(new Date(execution.plannedAt))}: turn ${execution.action} ${execution.outcome}`;
+                    if (execution.attempts > 1) {
+                        line += ` after ${execution.attempts} attempts`;
+                    }
+                    if (execution.error) {
+                        line += ` (${execution.error})`;
+                    }
+                    return line;
+                });
+                alert(`Last ${lines.length} of ${data.total} runs:\n\n${lines.join('\n')}`);
+            } else {
+                const errorData = await response.json();
+                alert(`Failed to load history: ${errorData.error || 'Unknown error'}`);
+                console.error('Failed to fetch executions:', errorData.error);
+            }
+        } catch (error) {
+            alert('Network error or server unavailable.');
+            console.error('Network error:', error);
+        }
+    }
+
+    async function deleteSchedule(scheduleID) {
         try {
-            const response = await fetch(`/schedule/delete/${jobID}`, {
+            const response = await fetch(`/schedule/delete/${scheduleID}`, {
                 method: 'DELETE',
                 headers: {
                     'Authorization': `Bearer ${liaraToken}`
@@ -329,10 +556,396 @@ document.addEventListener('DOMContentLoaded', () => {
         }
     }
 
+    async function fetchDeadLetters() {
+        try {
+            const response = await fetch('/dead-letters', {
+                headers: {
+                    'Authorization': `Bearer ${liaraToken}`
+                }
+            });
+            if (response.ok) {
+                const deadLetters = await response.json();
+                deadLettersList.innerHTML = '';
+                if (deadLetters.length === 0) {
+                    deadLettersList.innerHTML = '<li>No failed actions.</li>';
+                    return;
+                }
+                deadLetters.forEach(deadLetter => {
+                    const li = document.createElement('li');
+                    let text = `Turn ${deadLetter.action} ${deadLetter.serviceType} ${deadLetter.serviceName} | Failed: ${formatDate(new Date(deadLetter.failedAt))} | Attempts: ${deadLetter.attempts} | Error: ${deadLetter.error}`;
+                    if (deadLetter.replayedAt) {
+                        text += ` | Replayed: ${formatDate(new Date(deadLetter.replayedAt))}`;
+                    }
+                    li.textContent = text;
+
+                    const replayButton = document.createElement('button');
+                    replayButton.textContent = 'Replay';
+                    replayButton.classList.add('edit-button');
+                    replayButton.addEventListener('click', async () => {
+                        await deadLetterRequest(`/dead-letters/${deadLetter.id}/replay`, 'POST');
+                    });
+                    const discardButton = document.createElement('button');
+                    discardButton.textContent = 'Discard';
+                    discardButton.classList.add('delete-button');
+                    discardButton.addEventListener('click', async () => {
+                        await deadLetterRequest(`/dead-letters/${deadLetter.id}`, 'DELETE');
+                    });
+                    li.appendChild(replayButton);
+                    li.appendChild(discardButton);
+                    deadLettersList.appendChild(li);
+                });
+            } else {
+                const errorData = await response.json();
+                deadLettersList.innerHTML = '<li>Error loading failed actions.</li>';
+                console.error('Failed to fetch dead letters:', errorData.error);
+            }
+        } catch (error) {
+            deadLettersList.innerHTML = '<li>Network error or server unavailable.</li>';
+            console.error('Network error:', error);
+        }
+    }
+
+    async function deadLetterRequest(url, method) {
+        try {
+            const response = await fetch(url, {
+                method,
+                headers: {
+                    'Authorization': `Bearer ${liaraToken}`
+                }
+            });
+
+            if (response.ok) {
+                fetchDeadLetters();
+            } else {
+                const errorData = await response.json();
+                alert(`Failed to update failed action: ${errorData.error || 'Unknown error'}`);
+                console.error('Failed to update dead letter:', errorData.error);
+            }
+        } catch (error) {
+            alert('Network error or server unavailable.');
+            console.error('Network error:', error);
+        }
+    }
+
+    webhookForm.addEventListener('submit', async (e) => {
+        e.preventDefault();
+        const url = document.getElementById('webhook-url-input').value;
+        const events = Array.from(webhookForm.querySelectorAll('input[name="events"]:checked')).map(input => input.value);
+        try {
+            const response = await fetch('/webhooks', {
+                method: 'POST',
+                headers: {
+                    'Content-Type': 'application/json',
+                    'Authorization': `Bearer ${liaraToken}`
+                },
+                body: JSON.stringify({ url, events }),
+            });
+
+            if (response.ok) {
+                const webhook = await response.json();
+                // The secret is never shown again.
+                alert(`Webhook added. Its signing secret is:\n\n${webhook.secret}\n\nStore it now; it cannot be shown again.`);
+                webhookForm.reset();
+                fetchWebhooks();
+            } else {
+                const errorData = await response.json();
+                alert(`Failed to add webhook: ${errorData.error || 'Unknown error'}`);
+                console.error('Failed to add webhook:', errorData.error);
+            }
+        } catch (error) {
+            alert('Network error or server unavailable.');
+            console.error('Network error:', error);
+        }
+    });
+
+    async function fetchWebhooks() {
+        try {
+            const response = await fetch('/webhooks', {
+                headers: {
+                    'Authorization': `Bearer ${liaraToken}`
+                }
+            });
+            if (response.ok) {
+                const webhooks = await response.json();
+                webhooksList.innerHTML = '';
+                if (webhooks.length === 0) {
+                    webhooksList.innerHTML = '<li>No webhooks added yet.</li>';
+                    return;
+                }
+                for (const webhook of webhooks) {
+                    const li = document.createElement('li');
+                    let text = `${webhook.url} | Events: ${webhook.events.join(', ')}`;
+                    const deliveries = await fetchWebhookDeliveries(webhook.id);
+                    if (deliveries.length > 0) {
+                        const last = deliveries[0];
+                        text += ` | Last delivery: ${last.event} ${last.status} after ${last.attempts} attempt(s), ${formatDate(new Date(last.createdAt))}`;
+                        if (last.error) {
+                            text += ` (${last.error})`;
+                        }
+                    }
+                    li.textContent = text;
+
+                    const pingButton = document.createElement('button');
+                    pingButton.textContent = 'Ping';
+                    pingButton.classList.add('edit-button');
+                    pingButton.addEventListener('click', async () => {
+                        await webhookRequest(`/webhooks/${webhook.id}/ping`, 'POST');
+                    });
+                    const deleteButton = document.createElement('button');
+                    deleteButton.textContent = 'Delete';
+                    deleteButton.classList.add('delete-button');
+                    deleteButton.addEventListener('click', async () => {
+                        await webhookRequest(`/webhooks/${webhook.id}`, 'DELETE');
+                    });
+                    li.appendChild(pingButton);
+                    li.appendChild(deleteButton);
+                    webhooksList.appendChild(li);
+                }
+            } else {
+                const errorData = await response.json();
+                webhooksList.innerHTML = '<li>Error loading webhooks.</li>';
+                console.error('Failed to fetch webhooks:', errorData.error);
+            }
+        } catch (error) {
+            webhooksList.innerHTML = '<li>Network error or server unavailable.</li>';
+            console.error('Network error:', error);
+        }
+    }
+
+    async function fetchWebhookDeliveries(webhookId) {
+        const response = await fetch(`/webhooks/${webhookId}/deliveries?limit=1`, {
+            headers: {
+                'Authorization': `Bearer ${liaraToken}`
+            }
+        });
+        return response.ok ? response.json() : [];
+    }
+
+    async function webhookRequest(url, method) {
+        try {
+            const response = await fetch(url, {
+                method,
+                headers: {
+                    'Authorization': `Bearer ${liaraToken}`
+                }
+            });
+
+            if (response.ok) {
+                // A ping is sent in the background; give it a moment.
+                setTimeout(fetchWebhooks, 1000);
+            } else {
+                const errorData = await response.json();
+                alert(`Failed to update webhook: ${errorData.error || 'Unknown error'}`);
+                console.error('Failed to update webhook:', errorData.error);
+            }
+        } catch (error) {
+            alert('Network error or server unavailable.');
+            console.error('Network error:', error);
+        }
+    }
+
+    telegramForm.addEventListener('submit', async (e) => {
+        e.preventDefault();
+        const chatId = Number(document.getElementById('telegram-chat-input').value);
+        const code = document.getElementById('telegram-code-input').value.trim();
+        try {
+            const response = await fetch('/telegram/chats', {
+                method: 'POST',
+                headers: {
+                    'Content-Type': 'application/json',
+                    'Authorization': `Bearer ${liaraToken}`
+                },
+                body: JSON.stringify({ chatId, code }),
+            });
+
+            if (response.ok) {
+                telegramForm.reset();
+                fetchTelegramChats();
+            } else {
+                const errorData = await response.json();
+                alert(`Failed to link chat: ${errorData.error || 'Unknown error'}`);
+                console.error('Failed to link chat:', errorData.error);
+            }
+        } catch (error) {
+            alert('Network error or server unavailable.');
+            console.error('Network error:', error);
+        }
+    });
+
+    async function fetchTelegramChats() {
+        try {
+            const response = await fetch('/telegram/chats', {
+                headers: {
+                    'Authorization': `Bearer ${liaraToken}`
+                }
+            });
+            if (response.status === 503) {
+                // The server has no bot configured.
+                telegramStatus.textContent = 'The Telegram bot is not configured on this server.';
+                telegramChatsList.innerHTML = '';
+                telegramForm.style.display = 'none';
+                return;
+            }
+            if (response.ok) {
+                const chats = await response.json();
+                telegramChatsList.innerHTML = '';
+                if (chats.length === 0) {
+                    telegramChatsList.innerHTML = '<li>No Telegram chats linked yet.</li>';
+                    return;
+                }
+                for (const chat of chats) {
+                    const li = document.createElement('li');
+                    li.textContent = `${chat.title || chat.chatId} (${chat.chatId}) | Linked: ${formatDate(new Date(chat.linkedAt))}`;
+
+                    const unlinkButton = document.createElement('button');
+                    unlinkButton.textContent = 'Unlink';
+                    unlinkButton.classList.add('delete-button');
+                    unlinkButton.addEventListener('click', async () => {
+                        await unlinkTelegramChat(chat.chatId);
+                    });
+                    li.appendChild(unlinkButton);
+                    telegramChatsList.appendChild(li);
+                }
+            } else {
+                const errorData = await response.json();
+                telegramChatsList.innerHTML = '<li>Error loading Telegram chats.</li>';
+                console.error('Failed to fetch Telegram chats:', errorData.error);
+            }
+        } catch (error) {
+            telegramChatsList.innerHTML = '<li>Network error or server unavailable.</li>';
+            console.error('Network error:', error);
+        }
+    }
+
+    async function unlinkTelegramChat(chatId) {
+        try {
+            const response = await fetch(`/telegram/chats/${chatId}`, {
+                method: 'DELETE',
+                headers: {
+                    'Authorization': `Bearer ${liaraToken}`
+                }
+            });
+
+            if (response.ok) {
+                fetchTelegramChats();
+            } else {
+                const errorData = await response.json();
+                alert(`Failed to unlink chat: ${errorData.error || 'Unknown error'}`);
+                console.error('Failed to unlink chat:', errorData.error);
+            }
+        } catch (error) {
+            alert('Network error or server unavailable.');
+            console.error('Network error:', error);
+        }
+    }
+
+    emailForm.addEventListener('submit', async (e) => {
+        e.preventDefault();
+        const address = document.getElementById('email-address-input').value;
+        const failures = document.getElementById('email-failures-input').checked;
+        const digest = document.getElementById('email-digest-select').value;
+        try {
+            const response = await fetch('/email/recipients', {
+                method: 'POST',
+                headers: {
+                    'Content-Type': 'application/json',
+                    'Authorization': `Bearer ${liaraToken}`
+                },
+                body: JSON.stringify({ address, failures, digest }),
+            });
+
+            if (response.ok) {
+                emailForm.reset();
+                fetchEmailRecipients();
+            } else {
+                const errorData = await response.json();
+                alert(`Failed to add recipient: ${errorData.error || 'Unknown error'}`);
+                console.error('Failed to add recipient:', errorData.error);
+            }
+        } catch (error) {
+            alert('Network error or server unavailable.');
+            console.error('Network error:', error);
+        }
+    });
+
+    async function fetchEmailRecipients() {
+        try {
+            const response = await fetch('/email/recipients', {
+                headers: {
+                    'Authorization': `Bearer ${liaraToken}`
+                }
+            });
+            if (response.status === 503) {
+                // The server has no SMTP server configured.
+                emailStatus.textContent = 'Email is not configured on this server.';
+                emailRecipientsList.innerHTML = '';
+                emailForm.style.display = 'none';
+                return;
+            }
+            if (response.ok) {
+                const recipients = await response.json();
+                emailRecipientsList.innerHTML = '';
+                if (recipients.length === 0) {
+                    emailRecipientsList.innerHTML = '<li>No email recipients added yet.</li>';
+                    return;
+                }
+                for (const recipient of recipients) {
+                    const receives = [];
+                    if (recipient.failures) {
+                        receives.push('failed runs');
+                    }
+                    if (recipient.digest) {
+                        receives.push(`${recipient.digest} digest`);
+                    }
+                    const li = document.createElement('li');
+                    li.textContent = `${recipient.address} | ${receives.join(', ')} | Added: ${formatDate(new Date(recipient.createdAt))}`;
+
+                    const deleteButton = document.createElement('button');
+                    deleteButton.textContent = 'Delete';
+                    deleteButton.classList.add('delete-button');
+                    deleteButton.addEventListener('click', async () => {
+                        await deleteEmailRecipient(recipient.id);
+                    });
+                    li.appendChild(deleteButton);
+                    emailRecipientsList.appendChild(li);
+                }
+            } else {
+                const errorData = await response.json();
+                emailRecipientsList.innerHTML = '<li>Error loading email recipients.</li>';
+                console.error('Failed to fetch email recipients:', errorData.error);
+            }
+        } catch (error) {
+            emailRecipientsList.innerHTML = '<li>Network error or server unavailable.</li>';
+            console.error('Network error:', error);
+        }
+    }
+
+    async function deleteEmailRecipient(id) {
+        try {
+            const response = await fetch(`/email/recipients/${id}`, {
+                method: 'DELETE',
+                headers: {
+                    'Authorization': `Bearer ${liaraToken}`
+                }
+            });
+
+            if (response.ok) {
+                fetchEmailRecipients();
+            } else {
+                const errorData = await response.json();
+                alert(`Failed to delete recipient: ${errorData.error || 'Unknown error'}`);
+                console.error('Failed to delete recipient:', errorData.error);
+            }
+        } catch (error) {
+            alert('Network error or server unavailable.');
+            console.error('Network error:', error);
+        }
+    }
+
     async function fetchLogs() {
         serverLogsPre.textContent = 'Loading logs...';
         try {
-            const response = await fetch('/logs', {
+            const response = await fetch('/logs?format=text', {
                 headers: {
                     'Authorization': `Bearer ${liaraToken}`
                 }
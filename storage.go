@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"scheduler/store"
+)
+
+var (
+	// dataStore keeps schedules, executions, logs and the rest of the state
+	// across restarts.
+	dataStore store.Store
+	// storeBackend names the kind of dataStore: postgres, sqlite, file or
+	// memory.
+	storeBackend string
+)
+
+// storeBackendFromEnv reads STORE_BACKEND. Without it, DATABASE_URL selects
+// Postgres and everything else is kept in memory.
+func storeBackendFromEnv() string {
+	if backend := os.Getenv("STORE_BACKEND"); backend != "" {
+		return backend
+	}
+	if os.Getenv("DATABASE_URL") != "" {
+		return "postgres"
+	}
+	return "memory"
+}
+
+// openStore opens the backend selected by STORE_BACKEND. The SQLite and file
+// stores are kept at STORE_PATH. With Postgres, db is set as well, for
+// leader election and state sync.
+func openStore() (store.Store, error) {
+	backend := storeBackendFromEnv()
+	path := os.Getenv("STORE_PATH")
+	fileOptions := store.FileOptions{MaxLogsPerAccount: retention.memoryLogCapacity()}
+
+	switch backend {
+	case "postgres":
+		connStr := os.Getenv("DATABASE_URL")
+		if connStr == "" {
+			return nil, fmt.Errorf("STORE_BACKEND is postgres but DATABASE_URL is not set")
+		}
+		p, err := store.OpenPostgres(connStr)
+		if err != nil {
+			return nil, err
+		}
+		// Schedules created before ownership existed belong to the account
+		// of LIARA_API_TOKEN, the only token they could ever run with.
+		if envToken := os.Getenv("LIARA_API_TOKEN"); envToken != "" {
+			if _, err := p.AdoptUnownedSchedules(ownerFromToken(envToken)); err != nil {
+				p.Close()
+				return nil, fmt.Errorf("assigning owner to existing schedules: %w", err)
+			}
+		}
+		db = p.DB()
+		log.Println("Successfully connected to PostgreSQL database.")
+		return p, nil
+	case "sqlite":
+		path = sqlitePath()
+		s, err := store.OpenSQLite(path)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Using SQLite database %s.", path)
+		return s, nil
+	case "file":
+		if path == "" {
+			path = "data"
+		}
+		f, err := store.NewFile(path, fileOptions)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Using file store in %s.", path)
+		return f, nil
+	case "memory":
+		log.Println("No store configured, using in-memory storage for logs and schedules.")
+		return store.NewFile("", fileOptions)
+	default:
+		return nil, fmt.Errorf("unknown STORE_BACKEND %q (available: postgres, sqlite, file, memory)", backend)
+	}
+}
+
+// sqlitePath returns where the SQLite store is kept.
+func sqlitePath() string {
+	if path := os.Getenv("STORE_PATH"); path != "" {
+		return path
+	}
+	return "scheduler.db"
+}
+
+// openMigrator connects to the SQL store selected by STORE_BACKEND without
+// migrating it.
+func openMigrator() (*store.Migrator, error) {
+	switch backend := storeBackendFromEnv(); backend {
+	case "postgres":
+		connStr := os.Getenv("DATABASE_URL")
+		if connStr == "" {
+			return nil, fmt.Errorf("STORE_BACKEND is postgres but DATABASE_URL is not set")
+		}
+		return store.PostgresMigrator(connStr)
+	case "sqlite":
+		return store.SQLiteMigrator(sqlitePath())
+	default:
+		return nil, fmt.Errorf("the %s store has no schema to migrate, set DATABASE_URL or STORE_BACKEND=sqlite", backend)
+	}
+}
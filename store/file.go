@@ -0,0 +1,856 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxLogsPerAccount = 1000
+	defaultMaxExecutions     = 10000
+)
+
+// FileOptions bound what a File store keeps. Older logs and executions are
+// dropped as new ones arrive.
+type FileOptions struct {
+	MaxLogsPerAccount int // 0 means 1000
+	MaxExecutions     int // across all accounts; 0 means 10000
+}
+
+// File keeps everything in memory and, if it has a directory, in plain files
+// there: state.json holds schedules, accounts, holidays, dead letters,
+// webhooks, Telegram chats and email recipients and is replaced on every change, while
+// executions.ndjson and logs.ndjson are appended to and compacted now and
+// then. It suits a single server.
+type File struct {
+	dir  string
+	opts FileOptions
+
+	mu         sync.Mutex
+	state      fileState
+	executions []Execution // oldest first
+	logs       map[string]*logRing
+	nextLogID  int64
+
+	executionFile  *os.File
+	executionLines int // lines in executionFile, to know when to compact it
+	logFile        *os.File
+	logLines       int
+}
+
+// fileState is the content of state.json.
+type fileState struct {
+	Schedules      []Schedule       `json:"schedules"`
+	PausedAccounts []string         `json:"pausedAccounts"`
+	Holidays       []Holiday        `json:"holidays"`
+	DeadLetters    []fileDeadLetter `json:"deadLetters"`
+	Webhooks       []fileWebhook    `json:"webhooks"`
+	// WebhookDeliveries are kept oldest first.
+	WebhookDeliveries []fileWebhookDelivery `json:"webhookDeliveries"`
+	TelegramChats     []fileTelegramChat    `json:"telegramChats"`
+	EmailRecipients   []fileEmailRecipient  `json:"emailRecipients"`
+}
+
+// The records whose JSON leaves out the owner, the secret of a webhook or
+// the token of a Telegram chat or email recipient are wrapped to keep them.
+type (
+	fileDeadLetter struct {
+		Owner string `json:"owner"`
+		DeadLetter
+	}
+	fileExecution struct {
+		Owner string `json:"owner"`
+		Execution
+	}
+	fileLogEntry struct {
+		Owner string `json:"owner"`
+		LogEntry
+	}
+	fileWebhook struct {
+		Owner  string `json:"owner"`
+		Secret string `json:"secret"`
+		Webhook
+	}
+	fileWebhookDelivery struct {
+		Owner string `json:"owner"`
+		WebhookDelivery
+	}
+	fileTelegramChat struct {
+		Owner           string `json:"owner"`
+		TokenCiphertext string `json:"tokenCiphertext,omitempty"`
+		TelegramChat
+	}
+	fileEmailRecipient struct {
+		Owner           string `json:"owner"`
+		TokenCiphertext string `json:"tokenCiphertext,omitempty"`
+		EmailRecipient
+	}
+)
+
+// NewFile opens the store kept in dir, creating dir if needed. With an empty
+// dir nothing is written to disk and everything is lost on exit.
+func NewFile(dir string, opts FileOptions) (*File, error) {
+	if opts.MaxLogsPerAccount <= 0 {
+		opts.MaxLogsPerAccount = defaultMaxLogsPerAccount
+	}
+	if opts.MaxExecutions <= 0 {
+		opts.MaxExecutions = defaultMaxExecutions
+	}
+	f := &File{dir: dir, opts: opts, logs: make(map[string]*logRing), nextLogID: 1}
+	if dir == "" {
+		return f, nil
+	}
+
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "state.json"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &f.state); err != nil {
+			return nil, fmt.Errorf("reading state.json: %w", err)
+		}
+	}
+
+	f.executionLines, err = readLines(filepath.Join(dir, "executions.ndjson"), func(line []byte) error {
+		var e fileExecution
+		if err := json.Unmarshal(line, &e); err != nil {
+			return err
+		}
+		e.Execution.Owner = e.Owner
+		f.addExecution(e.Execution)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading executions.ndjson: %w", err)
+	}
+	f.logLines, err = readLines(filepath.Join(dir, "logs.ndjson"), func(line []byte) error {
+		var e fileLogEntry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return err
+		}
+		e.LogEntry.Owner = e.Owner
+		f.addLog(e.LogEntry)
+		f.nextLogID = max(f.nextLogID, e.ID+1)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading logs.ndjson: %w", err)
+	}
+
+	if f.executionFile, err = openAppend(filepath.Join(dir, "executions.ndjson")); err != nil {
+		return nil, err
+	}
+	if f.logFile, err = openAppend(filepath.Join(dir, "logs.ndjson")); err != nil {
+		f.executionFile.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// readLines calls fn with each line of the NDJSON file at path and returns
+// how many lines there were. A missing file has none. A last line that does
+// not decode was cut short by a crash and is skipped.
+func readLines(path string, fn func([]byte) error) (int, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	n := 0
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(bytes.TrimSpace(line)) > 0 && fn(line) == nil {
+				n++
+			}
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		if err := fn(line); err != nil {
+			return n, fmt.Errorf("line %d: %w", n+1, err)
+		}
+		n++
+	}
+}
+
+func openAppend(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+}
+
+// appendLines writes records to file, one JSON document per line.
+func appendLines(file *os.File, records []any) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// writeFile replaces the file at path with data, so that a crash leaves
+// either the old or the new content.
+func writeFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// rewriteLines replaces the NDJSON file held open in *file with records and
+// reopens it for appending.
+func rewriteLines(file **os.File, records []any) error {
+	path := (*file).Name()
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	if err := writeFile(path, buf.Bytes()); err != nil {
+		return err
+	}
+	reopened, err := openAppend(path)
+	if err != nil {
+		return err
+	}
+	(*file).Close()
+	*file = reopened
+	return nil
+}
+
+// update applies change to a copy of the state and keeps the copy once it
+// has been saved, so a failed save changes nothing.
+func (f *File) update(change func(s *fileState) error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	s := fileState{
+		Schedules:         slices.Clone(f.state.Schedules),
+		PausedAccounts:    slices.Clone(f.state.PausedAccounts),
+		Holidays:          slices.Clone(f.state.Holidays),
+		DeadLetters:       slices.Clone(f.state.DeadLetters),
+		Webhooks:          slices.Clone(f.state.Webhooks),
+		WebhookDeliveries: slices.Clone(f.state.WebhookDeliveries),
+		TelegramChats:     slices.Clone(f.state.TelegramChats),
+		EmailRecipients:   slices.Clone(f.state.EmailRecipients),
+	}
+	if err := change(&s); err != nil {
+		return err
+	}
+	if f.dir != "" {
+		data, err := json.MarshalIndent(s, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := writeFile(filepath.Join(f.dir, "state.json"), data); err != nil {
+			return fmt.Errorf("saving state: %w", err)
+		}
+	}
+	f.state = s
+	return nil
+}
+
+func (f *File) Schedules() ([]Schedule, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append(make([]Schedule, 0, len(f.state.Schedules)), f.state.Schedules...), nil
+}
+
+func (f *File) CreateSchedule(sched Schedule) error {
+	return f.update(func(s *fileState) error {
+		for _, existing := range s.Schedules {
+			if existing.ID == sched.ID {
+				return fmt.Errorf("schedule %s already exists", sched.ID)
+			}
+		}
+		s.Schedules = append(s.Schedules, sched)
+		return nil
+	})
+}
+
+func (f *File) UpdateSchedule(sched Schedule) error {
+	return f.update(func(s *fileState) error {
+		for i, existing := range s.Schedules {
+			if existing.ID == sched.ID && existing.Owner == sched.Owner {
+				sched.TokenCiphertext = existing.TokenCiphertext
+				s.Schedules[i] = sched
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+}
+
+func (f *File) DeleteSchedule(owner, id string) error {
+	return f.update(func(s *fileState) error {
+		for i, existing := range s.Schedules {
+			if existing.ID == id && existing.Owner == owner {
+				s.Schedules = slices.Delete(s.Schedules, i, i+1)
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+}
+
+func (f *File) Credentials() ([]Credential, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	credentials := make([]Credential, 0)
+	for _, sched := range f.state.Schedules {
+		if sched.TokenCiphertext != "" {
+			credentials = append(credentials, Credential{ScheduleID: sched.ID, Owner: sched.Owner, Ciphertext: sched.TokenCiphertext})
+		}
+	}
+	return credentials, nil
+}
+
+func (f *File) UpdateCredentials(credentials []Credential) error {
+	return f.update(func(s *fileState) error {
+		for _, c := range credentials {
+			for i, sched := range s.Schedules {
+				if sched.ID == c.ScheduleID && sched.Owner == c.Owner {
+					s.Schedules[i].TokenCiphertext = c.Ciphertext
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (f *File) PausedAccounts() ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append(make([]string, 0, len(f.state.PausedAccounts)), f.state.PausedAccounts...), nil
+}
+
+func (f *File) SetAccountPaused(owner string, paused bool) error {
+	return f.update(func(s *fileState) error {
+		s.PausedAccounts = slices.DeleteFunc(s.PausedAccounts, func(o string) bool { return o == owner })
+		if paused {
+			s.PausedAccounts = append(s.PausedAccounts, owner)
+		}
+		return nil
+	})
+}
+
+func (f *File) Holidays() ([]Holiday, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append(make([]Holiday, 0, len(f.state.Holidays)), f.state.Holidays...), nil
+}
+
+func (f *File) ReplaceHolidays(owner string, holidays []Holiday) error {
+	return f.update(func(s *fileState) error {
+		s.Holidays = slices.DeleteFunc(s.Holidays, func(h Holiday) bool { return h.Owner == owner })
+		for _, h := range holidays {
+			h.Owner = owner
+			// A date given twice keeps its last name.
+			s.Holidays = slices.DeleteFunc(s.Holidays, func(existing Holiday) bool {
+				return existing.Owner == owner && existing.Date == h.Date
+			})
+			s.Holidays = append(s.Holidays, h)
+		}
+		return nil
+	})
+}
+
+func (f *File) DeadLetters() ([]DeadLetter, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	letters := make([]DeadLetter, 0, len(f.state.DeadLetters))
+	for _, letter := range f.state.DeadLetters {
+		letter.DeadLetter.Owner = letter.Owner
+		letters = append(letters, letter.DeadLetter)
+	}
+	sort.SliceStable(letters, func(i, j int) bool { return letters[i].FailedAt.Before(letters[j].FailedAt) })
+	return letters, nil
+}
+
+func (f *File) AddDeadLetter(letter DeadLetter) error {
+	return f.update(func(s *fileState) error {
+		s.DeadLetters = append(s.DeadLetters, fileDeadLetter{Owner: letter.Owner, DeadLetter: letter})
+		return nil
+	})
+}
+
+func (f *File) DeleteDeadLetter(owner, id string) error {
+	return f.update(func(s *fileState) error {
+		for i, letter := range s.DeadLetters {
+			if letter.ID == id && letter.Owner == owner {
+				s.DeadLetters = slices.Delete(s.DeadLetters, i, i+1)
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+}
+
+func (f *File) MarkDeadLetterReplayed(owner, id string, at time.Time) error {
+	return f.update(func(s *fileState) error {
+		for i, letter := range s.DeadLetters {
+			if letter.ID == id && letter.Owner == owner {
+				if letter.ReplayedAt != nil {
+					return ErrAlreadyReplayed
+				}
+				s.DeadLetters[i].ReplayedAt = &at
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+}
+
+func (f *File) Webhooks(owner string) ([]Webhook, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	webhooks := make([]Webhook, 0)
+	for _, w := range f.state.Webhooks {
+		if w.Owner == owner {
+			w.Webhook.Owner, w.Webhook.Secret = w.Owner, w.Secret
+			webhooks = append(webhooks, w.Webhook)
+		}
+	}
+	sort.SliceStable(webhooks, func(i, j int) bool { return webhooks[i].CreatedAt.Before(webhooks[j].CreatedAt) })
+	return webhooks, nil
+}
+
+func (f *File) CreateWebhook(w Webhook) error {
+	return f.update(func(s *fileState) error {
+		s.Webhooks = append(s.Webhooks, fileWebhook{Owner: w.Owner, Secret: w.Secret, Webhook: w})
+		return nil
+	})
+}
+
+func (f *File) DeleteWebhook(owner, id string) error {
+	return f.update(func(s *fileState) error {
+		for i, w := range s.Webhooks {
+			if w.ID == id && w.Owner == owner {
+				s.Webhooks = slices.Delete(s.Webhooks, i, i+1)
+				s.WebhookDeliveries = slices.DeleteFunc(s.WebhookDeliveries, func(d fileWebhookDelivery) bool { return d.WebhookID == id })
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+}
+
+func (f *File) SaveWebhookDelivery(d WebhookDelivery) error {
+	return f.update(func(s *fileState) error {
+		stored := fileWebhookDelivery{Owner: d.Owner, WebhookDelivery: d}
+		i := slices.IndexFunc(s.WebhookDeliveries, func(existing fileWebhookDelivery) bool { return existing.ID == d.ID })
+		if i >= 0 {
+			s.WebhookDeliveries[i] = stored
+			return nil
+		}
+		s.WebhookDeliveries = append(s.WebhookDeliveries, stored)
+
+		kept := 0
+		for i := len(s.WebhookDeliveries) - 1; i >= 0; i-- {
+			if s.WebhookDeliveries[i].WebhookID != d.WebhookID {
+				continue
+			}
+			if kept++; kept > WebhookDeliveriesKept {
+				s.WebhookDeliveries = slices.Delete(s.WebhookDeliveries, i, i+1)
+			}
+		}
+		return nil
+	})
+}
+
+func (f *File) WebhookDeliveries(owner, webhookID string, limit int) ([]WebhookDelivery, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	deliveries := make([]WebhookDelivery, 0)
+	for i := len(f.state.WebhookDeliveries) - 1; i >= 0 && len(deliveries) < limit; i-- {
+		d := f.state.WebhookDeliveries[i]
+		if d.Owner == owner && d.WebhookID == webhookID {
+			d.WebhookDelivery.Owner = d.Owner
+			deliveries = append(deliveries, d.WebhookDelivery)
+		}
+	}
+	return deliveries, nil
+}
+
+func (f *File) TelegramChats() ([]TelegramChat, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	chats := make([]TelegramChat, 0, len(f.state.TelegramChats))
+	for _, c := range f.state.TelegramChats {
+		chats = append(chats, c.unwrap())
+	}
+	sort.SliceStable(chats, func(i, j int) bool { return chats[i].LinkedAt.Before(chats[j].LinkedAt) })
+	return chats, nil
+}
+
+func (f *File) TelegramChat(chatID int64) (TelegramChat, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, c := range f.state.TelegramChats {
+		if c.ChatID == chatID {
+			return c.unwrap(), nil
+		}
+	}
+	return TelegramChat{}, ErrNotFound
+}
+
+func (f *File) LinkTelegramChat(c TelegramChat) error {
+	return f.update(func(s *fileState) error {
+		stored := fileTelegramChat{Owner: c.Owner, TokenCiphertext: c.TokenCiphertext, TelegramChat: c}
+		i := slices.IndexFunc(s.TelegramChats, func(existing fileTelegramChat) bool { return existing.ChatID == c.ChatID })
+		if i >= 0 {
+			s.TelegramChats[i] = stored
+			return nil
+		}
+		s.TelegramChats = append(s.TelegramChats, stored)
+		return nil
+	})
+}
+
+func (f *File) UnlinkTelegramChat(owner string, chatID int64) error {
+	return f.update(func(s *fileState) error {
+		for i, c := range s.TelegramChats {
+			if c.ChatID == chatID && c.Owner == owner {
+				s.TelegramChats = slices.Delete(s.TelegramChats, i, i+1)
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+}
+
+func (c fileTelegramChat) unwrap() TelegramChat {
+	chat := c.TelegramChat
+	chat.Owner, chat.TokenCiphertext = c.Owner, c.TokenCiphertext
+	return chat
+}
+
+func (f *File) EmailRecipients() ([]EmailRecipient, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	recipients := make([]EmailRecipient, 0, len(f.state.EmailRecipients))
+	for _, r := range f.state.EmailRecipients {
+		recipient := r.EmailRecipient
+		recipient.Owner, recipient.TokenCiphertext = r.Owner, r.TokenCiphertext
+		recipients = append(recipients, recipient)
+	}
+	sort.SliceStable(recipients, func(i, j int) bool { return recipients[i].CreatedAt.Before(recipients[j].CreatedAt) })
+	return recipients, nil
+}
+
+func (f *File) SaveEmailRecipient(r EmailRecipient) error {
+	return f.update(func(s *fileState) error {
+		stored := fileEmailRecipient{Owner: r.Owner, TokenCiphertext: r.TokenCiphertext, EmailRecipient: r}
+		i := slices.IndexFunc(s.EmailRecipients, func(existing fileEmailRecipient) bool { return existing.ID == r.ID })
+		if i >= 0 {
+			s.EmailRecipients[i] = stored
+			return nil
+		}
+		s.EmailRecipients = append(s.EmailRecipients, stored)
+		return nil
+	})
+}
+
+func (f *File) DeleteEmailRecipient(owner, id string) error {
+	return f.update(func(s *fileState) error {
+		for i, r := range s.EmailRecipients {
+			if r.ID == id && r.Owner == owner {
+				s.EmailRecipients = slices.Delete(s.EmailRecipients, i, i+1)
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+}
+
+// addExecution keeps e in memory, dropping the oldest executions beyond the
+// limit.
+func (f *File) addExecution(e Execution) {
+	f.executions = append(f.executions, e)
+	if over := len(f.executions) - f.opts.MaxExecutions; over > 0 {
+		f.executions = slices.Delete(f.executions, 0, over)
+	}
+}
+
+func (f *File) AddExecution(e Execution) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.executionFile != nil {
+		if err := appendLines(f.executionFile, []any{fileExecution{e.Owner, e}}); err != nil {
+			return err
+		}
+		f.executionLines++
+	}
+	f.addExecution(e)
+
+	if f.executionFile != nil && f.executionLines > 2*f.opts.MaxExecutions {
+		records := make([]any, len(f.executions))
+		for i, e := range f.executions {
+			records[i] = fileExecution{e.Owner, e}
+		}
+		if err := rewriteLines(&f.executionFile, records); err != nil {
+			return fmt.Errorf("compacting executions: %w", err)
+		}
+		f.executionLines = len(records)
+	}
+	return nil
+}
+
+func (f *File) Executions(filter ExecutionFilter) ([]Execution, int, error) {
+	f.mu.Lock()
+	var matching []Execution
+	for _, e := range f.executions {
+		if filter.Matches(e) {
+			matching = append(matching, e)
+		}
+	}
+	f.mu.Unlock()
+
+	sort.SliceStable(matching, func(i, j int) bool {
+		a, b := matching[i], matching[j]
+		if !a.PlannedAt.Equal(b.PlannedAt) {
+			return a.PlannedAt.After(b.PlannedAt)
+		}
+		return a.StartedAt.After(b.StartedAt)
+	})
+	page := make([]Execution, 0)
+	if filter.Offset < len(matching) {
+		page = append(page, matching[filter.Offset:min(filter.Offset+filter.Limit, len(matching))]...)
+	}
+	return page, len(matching), nil
+}
+
+// addLog keeps e in its owner's ring buffer.
+func (f *File) addLog(e LogEntry) {
+	ring, ok := f.logs[e.Owner]
+	if !ok {
+		ring = newLogRing(f.opts.MaxLogsPerAccount)
+		f.logs[e.Owner] = ring
+	}
+	ring.push(e)
+}
+
+func (f *File) AppendLogs(entries []LogEntry) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	records := make([]any, len(entries))
+	for i := range entries {
+		entries[i].ID = f.nextLogID + int64(i)
+		records[i] = fileLogEntry{entries[i].Owner, entries[i]}
+	}
+	if f.logFile != nil {
+		if err := appendLines(f.logFile, records); err != nil {
+			for i := range entries {
+				entries[i].ID = 0
+			}
+			return err
+		}
+		f.logLines += len(records)
+	}
+	f.nextLogID += int64(len(entries))
+	for _, e := range entries {
+		f.addLog(e)
+	}
+
+	if f.logFile != nil && f.logLines > 2*max(f.heldLogs(), f.opts.MaxLogsPerAccount) {
+		if err := f.compactLogs(); err != nil {
+			return fmt.Errorf("compacting logs: %w", err)
+		}
+	}
+	return nil
+}
+
+func (f *File) heldLogs() int {
+	n := 0
+	for _, ring := range f.logs {
+		n += ring.len()
+	}
+	return n
+}
+
+// compactLogs rewrites logs.ndjson with only the entries held in memory.
+func (f *File) compactLogs() error {
+	var entries []LogEntry
+	for _, ring := range f.logs {
+		for i := 0; i < ring.len(); i++ {
+			entries = append(entries, ring.at(i))
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	records := make([]any, len(entries))
+	for i, e := range entries {
+		records[i] = fileLogEntry{e.Owner, e}
+	}
+	if err := rewriteLines(&f.logFile, records); err != nil {
+		return err
+	}
+	f.logLines = len(records)
+	return nil
+}
+
+func (f *File) Logs(filter LogFilter, newestFirst bool) ([]LogEntry, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entries := make([]LogEntry, 0)
+	ring, ok := f.logs[filter.Owner]
+	if !ok {
+		return entries, nil
+	}
+	for n := 0; n < ring.len(); n++ {
+		i := n
+		if newestFirst {
+			i = ring.len() - 1 - n
+		}
+		e := ring.at(i)
+		if !filter.Matches(e) {
+			continue
+		}
+		entries = append(entries, e)
+		if filter.Limit > 0 && len(entries) == filter.Limit {
+			break
+		}
+	}
+	return entries, nil
+}
+
+func (f *File) PruneLogs(opts PruneOptions) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// Entries leave each ring from the oldest, so only how many go needs
+	// deciding before the archive has them.
+	drop := make(map[string]int)
+	var pruned []LogEntry
+	for owner, ring := range f.logs {
+		n := 0
+		for n < ring.len() {
+			tooOld := !opts.Before.IsZero() && ring.at(n).Timestamp.Before(opts.Before)
+			tooMany := opts.MaxEntries > 0 && ring.len()-n > opts.MaxEntries
+			if !tooOld && !tooMany {
+				break
+			}
+			pruned = append(pruned, ring.at(n))
+			n++
+		}
+		if n > 0 {
+			drop[owner] = n
+		}
+	}
+	if len(pruned) == 0 {
+		return 0, nil
+	}
+	if opts.Archive != nil {
+		if err := opts.Archive(pruned); err != nil {
+			return 0, err
+		}
+	}
+	for owner, n := range drop {
+		f.logs[owner].dropOldest(n)
+	}
+	if f.logFile != nil {
+		if err := f.compactLogs(); err != nil {
+			return len(pruned), fmt.Errorf("compacting logs: %w", err)
+		}
+	}
+	return len(pruned), nil
+}
+
+func (f *File) Ping(ctx context.Context) error {
+	if f.dir == "" {
+		return nil
+	}
+	_, err := os.Stat(f.dir)
+	return err
+}
+
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.executionFile == nil {
+		return nil
+	}
+	return errors.Join(f.executionFile.Close(), f.logFile.Close())
+}
+
+// logRing is a fixed-size buffer of log entries that overwrites the oldest
+// entry when full.
+type logRing struct {
+	entries []LogEntry
+	start   int // index of the oldest entry
+	size    int
+}
+
+func newLogRing(capacity int) *logRing {
+	return &logRing{entries: make([]LogEntry, capacity)}
+}
+
+func (r *logRing) push(e LogEntry) {
+	if r.size < len(r.entries) {
+		r.entries[(r.start+r.size)%len(r.entries)] = e
+		r.size++
+		return
+	}
+	r.entries[r.start] = e
+	r.start = (r.start + 1) % len(r.entries)
+}
+
+// at returns the i-th oldest entry.
+func (r *logRing) at(i int) LogEntry {
+	return r.entries[(r.start+i)%len(r.entries)]
+}
+
+func (r *logRing) len() int {
+	return r.size
+}
+
+// dropOldest removes the n oldest entries.
+func (r *logRing) dropOldest(n int) {
+	for ; n > 0 && r.size > 0; n-- {
+		r.entries[r.start] = LogEntry{}
+		r.start = (r.start + 1) % len(r.entries)
+		r.size--
+	}
+}
@@ -0,0 +1,226 @@
+package store_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"scheduler/store"
+	"scheduler/store/storetest"
+)
+
+// legacySQLiteSchema is what the SQLite store created with CREATE TABLE IF
+// NOT EXISTS before it had migrations.
+const legacySQLiteSchema = `
+CREATE TABLE IF NOT EXISTS schedules (
+	id TEXT PRIMARY KEY,
+	owner TEXT NOT NULL DEFAULT '',
+	kind TEXT NOT NULL DEFAULT 'single',
+	service_name TEXT NOT NULL,
+	service_type TEXT NOT NULL,
+	action TEXT NOT NULL,
+	cron_spec TEXT NOT NULL,
+	end_cron_spec TEXT NOT NULL DEFAULT '',
+	duration TEXT NOT NULL DEFAULT '',
+	timezone TEXT NOT NULL DEFAULT '',
+	calendar_rule TEXT,
+	retry_policy TEXT,
+	enabled BOOLEAN NOT NULL DEFAULT TRUE,
+	token_ciphertext TEXT
+);
+CREATE TABLE IF NOT EXISTS accounts (
+	owner TEXT PRIMARY KEY,
+	paused BOOLEAN NOT NULL DEFAULT FALSE
+);
+CREATE TABLE IF NOT EXISTS logs (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	owner TEXT,
+	timestamp INTEGER NOT NULL,
+	level TEXT NOT NULL DEFAULT 'INFO',
+	message TEXT NOT NULL,
+	schedule_id TEXT NOT NULL DEFAULT '',
+	execution_id TEXT NOT NULL DEFAULT '',
+	target TEXT NOT NULL DEFAULT '',
+	attrs TEXT
+);
+INSERT INTO schedules (id, owner, service_name, service_type, action, cron_spec)
+	VALUES ('7f0c4c1e-5a8e-4f7e-9a0e-3f6b1d2c4e5a', 'owner', 'legacy-app', 'project', 'off', '0 20 * * *');
+INSERT INTO accounts (owner, paused) VALUES ('owner', TRUE);`
+
+// legacyPostgresSchema is the first schema the server created, with
+// schedules keyed by their cron entry ID and logs by the raw token.
+const legacyPostgresSchema = `
+CREATE TABLE schedules (
+	job_id BIGINT PRIMARY KEY,
+	service_name TEXT NOT NULL,
+	service_type TEXT NOT NULL,
+	action TEXT NOT NULL,
+	cron_spec TEXT NOT NULL
+);
+CREATE TABLE logs (
+	id SERIAL PRIMARY KEY,
+	token TEXT NOT NULL,
+	timestamp TIMESTAMPTZ DEFAULT NOW(),
+	message TEXT NOT NULL
+);
+INSERT INTO schedules (job_id, service_name, service_type, action, cron_spec)
+	VALUES (3, 'legacy-app', 'project', 'off', '0 20 * * *');
+INSERT INTO logs (token, message) VALUES ('legacy-token', 'legacy message');`
+
+func TestSQLiteMigrations(t *testing.T) {
+	t.Run("UpAndDown", func(t *testing.T) {
+		storetest.RunMigrations(t, func(t *testing.T) *store.Migrator {
+			m, err := store.SQLiteMigrator(filepath.Join(t.TempDir(), "test.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return m
+		})
+	})
+
+	t.Run("UpToDate", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "test.db")
+		expectUpToDate(t,
+			func() (store.Store, error) { return store.OpenSQLite(path) },
+			func() (*store.Migrator, error) { return store.SQLiteMigrator(path) })
+	})
+
+	t.Run("LegacySchema", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "test.db")
+		db, err := sql.Open("sqlite", path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec(legacySQLiteSchema); err != nil {
+			t.Fatal(err)
+		}
+		db.Close()
+
+		s, err := store.OpenSQLite(path)
+		if err != nil {
+			t.Fatalf("OpenSQLite on the legacy schema: %v", err)
+		}
+		defer s.Close()
+		expectLegacySchedule(t, s, "owner")
+		paused, err := s.PausedAccounts()
+		if err != nil || len(paused) != 1 || paused[0] != "owner" {
+			t.Errorf("PausedAccounts() = %v, %v, want [owner]", paused, err)
+		}
+		expectAllApplied(t, func() (*store.Migrator, error) { return store.SQLiteMigrator(path) })
+	})
+}
+
+func TestPostgresMigrations(t *testing.T) {
+	connStr := os.Getenv("TEST_DATABASE_URL")
+	if connStr == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	t.Run("UpAndDown", func(t *testing.T) {
+		storetest.RunMigrations(t, func(t *testing.T) *store.Migrator {
+			m, err := store.PostgresMigrator(postgresSchema(t, connStr))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return m
+		})
+	})
+
+	t.Run("UpToDate", func(t *testing.T) {
+		schemaConnStr := postgresSchema(t, connStr)
+		expectUpToDate(t,
+			func() (store.Store, error) { return store.OpenPostgres(schemaConnStr) },
+			func() (*store.Migrator, error) { return store.PostgresMigrator(schemaConnStr) })
+	})
+
+	t.Run("LegacySchema", func(t *testing.T) {
+		schemaConnStr := postgresSchema(t, connStr)
+		db, err := sql.Open("postgres", schemaConnStr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec(legacyPostgresSchema); err != nil {
+			db.Close()
+			t.Fatal(err)
+		}
+		db.Close()
+
+		s, err := store.OpenPostgres(schemaConnStr)
+		if err != nil {
+			t.Fatalf("OpenPostgres on the legacy schema: %v", err)
+		}
+		defer s.Close()
+		// Schedules from before ownership have no owner until adopted.
+		expectLegacySchedule(t, s, "")
+		sum := sha256.Sum256([]byte("legacy-token"))
+		logs, err := s.Logs(store.LogFilter{Owner: hex.EncodeToString(sum[:])}, false)
+		if err != nil || len(logs) != 1 || logs[0].Message != "legacy message" {
+			t.Errorf("Logs of the legacy token's owner = %v, %v, want the legacy message", logs, err)
+		}
+		expectAllApplied(t, func() (*store.Migrator, error) { return store.PostgresMigrator(schemaConnStr) })
+	})
+}
+
+// expectUpToDate opens a store twice and checks that the second time finds
+// nothing to migrate.
+func expectUpToDate(t *testing.T, open func() (store.Store, error), migrator func() (*store.Migrator, error)) {
+	t.Helper()
+	for i := 0; i < 2; i++ {
+		s, err := open()
+		if err != nil {
+			t.Fatalf("opening the store (%d): %v", i+1, err)
+		}
+		if err := s.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m, err := migrator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if n, err := m.Up(context.Background()); err != nil || n != 0 {
+		t.Errorf("Up on an up-to-date database applied %d migrations, %v", n, err)
+	}
+	expectAllApplied(t, migrator)
+}
+
+// expectAllApplied checks that every known migration is applied.
+func expectAllApplied(t *testing.T, migrator func() (*store.Migrator, error)) {
+	t.Helper()
+	m, err := migrator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	statuses, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			t.Errorf("migration %d_%s pending", status.Version, status.Name)
+		}
+	}
+}
+
+// expectLegacySchedule checks that the schedule inserted with the legacy
+// schema survived the upgrade with an ID.
+func expectLegacySchedule(t *testing.T, s store.Store, owner string) {
+	t.Helper()
+	schedules, err := s.Schedules()
+	if err != nil {
+		t.Fatalf("Schedules: %v", err)
+	}
+	if len(schedules) != 1 {
+		t.Fatalf("%d schedules after the upgrade, want 1", len(schedules))
+	}
+	got := schedules[0]
+	if got.ID == "" || got.Owner != owner || got.ServiceName != "legacy-app" || got.CronSpec != "0 20 * * *" || !got.Enabled {
+		t.Errorf("legacy schedule upgraded to %+v", got)
+	}
+}
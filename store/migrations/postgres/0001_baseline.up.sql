@@ -0,0 +1,124 @@
+-- The schema as it stood before versioned migrations. Every statement is
+-- idempotent so that databases created by older versions, which have some of
+-- these tables already, are brought to the same point as new ones.
+
+CREATE TABLE IF NOT EXISTS schedules (
+	id UUID PRIMARY KEY,
+	owner TEXT NOT NULL DEFAULT '',
+	kind TEXT NOT NULL DEFAULT 'single',
+	service_name TEXT NOT NULL,
+	service_type TEXT NOT NULL,
+	action TEXT NOT NULL,
+	cron_spec TEXT NOT NULL,
+	end_cron_spec TEXT NOT NULL DEFAULT '',
+	duration TEXT NOT NULL DEFAULT '',
+	timezone TEXT NOT NULL DEFAULT '',
+	calendar_rule JSONB,
+	retry_policy JSONB,
+	enabled BOOLEAN NOT NULL DEFAULT TRUE,
+	token_ciphertext TEXT
+);
+
+-- Tables created before schedules had stable IDs were keyed by the cron
+-- EntryID, which is reassigned on every restart. Give each row a UUID and
+-- drop job_id; entry IDs now only live in memory.
+DO $$
+BEGIN
+	IF EXISTS (SELECT 1 FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = 'schedules' AND column_name = 'job_id') THEN
+		ALTER TABLE schedules ADD COLUMN IF NOT EXISTS id UUID;
+		UPDATE schedules SET id = gen_random_uuid() WHERE id IS NULL;
+		ALTER TABLE schedules DROP CONSTRAINT IF EXISTS schedules_pkey;
+		ALTER TABLE schedules ADD PRIMARY KEY (id);
+		ALTER TABLE schedules DROP COLUMN job_id;
+	END IF;
+END $$;
+
+-- Schedules created before ownership existed are left without an owner
+-- until AdoptUnownedSchedules gives them one.
+ALTER TABLE schedules ADD COLUMN IF NOT EXISTS owner TEXT NOT NULL DEFAULT '';
+ALTER TABLE schedules ADD COLUMN IF NOT EXISTS kind TEXT NOT NULL DEFAULT 'single';
+ALTER TABLE schedules ADD COLUMN IF NOT EXISTS end_cron_spec TEXT NOT NULL DEFAULT '';
+ALTER TABLE schedules ADD COLUMN IF NOT EXISTS duration TEXT NOT NULL DEFAULT '';
+ALTER TABLE schedules ADD COLUMN IF NOT EXISTS timezone TEXT NOT NULL DEFAULT '';
+ALTER TABLE schedules ADD COLUMN IF NOT EXISTS calendar_rule JSONB;
+ALTER TABLE schedules ADD COLUMN IF NOT EXISTS retry_policy JSONB;
+ALTER TABLE schedules ADD COLUMN IF NOT EXISTS enabled BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE schedules ADD COLUMN IF NOT EXISTS token_ciphertext TEXT;
+
+CREATE TABLE IF NOT EXISTS logs (
+	id SERIAL PRIMARY KEY,
+	token TEXT,
+	owner TEXT,
+	timestamp TIMESTAMPTZ DEFAULT NOW(),
+	level TEXT NOT NULL DEFAULT 'INFO',
+	message TEXT NOT NULL,
+	schedule_id TEXT NOT NULL DEFAULT '',
+	execution_id TEXT NOT NULL DEFAULT '',
+	target TEXT NOT NULL DEFAULT '',
+	attrs JSONB
+);
+
+-- Logs used to be keyed by the raw token; key them by owner instead and stop
+-- storing tokens in this table.
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS owner TEXT;
+ALTER TABLE logs ALTER COLUMN token DROP NOT NULL;
+UPDATE logs SET owner = encode(sha256(token::bytea), 'hex'), token = NULL WHERE owner IS NULL;
+
+-- Log entries used to be a single message string; the fields the logs API
+-- filters on now have columns of their own.
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS level TEXT NOT NULL DEFAULT 'INFO';
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS schedule_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS execution_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS target TEXT NOT NULL DEFAULT '';
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS attrs JSONB;
+CREATE INDEX IF NOT EXISTS logs_owner_timestamp_idx ON logs (owner, timestamp);
+CREATE INDEX IF NOT EXISTS logs_owner_id_idx ON logs (owner, id);
+
+CREATE TABLE IF NOT EXISTS accounts (
+	owner TEXT PRIMARY KEY,
+	paused BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE TABLE IF NOT EXISTS holidays (
+	owner TEXT NOT NULL,
+	jalali_date TEXT NOT NULL,
+	name TEXT NOT NULL,
+	PRIMARY KEY (owner, jalali_date)
+);
+
+-- schedule_id is deliberately not a foreign key: dead letters outlive the
+-- schedule they came from.
+CREATE TABLE IF NOT EXISTS dead_letters (
+	id UUID PRIMARY KEY,
+	owner TEXT NOT NULL,
+	schedule_id UUID,
+	service_type TEXT NOT NULL,
+	service_name TEXT NOT NULL,
+	action TEXT NOT NULL,
+	attempts INTEGER NOT NULL,
+	status_code INTEGER NOT NULL DEFAULT 0,
+	error TEXT NOT NULL,
+	failed_at TIMESTAMPTZ NOT NULL,
+	replayed_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS dead_letters_owner_failed_at_idx ON dead_letters (owner, failed_at);
+
+-- Like dead letters, executions are kept after their schedule is deleted.
+CREATE TABLE IF NOT EXISTS executions (
+	id UUID PRIMARY KEY,
+	owner TEXT NOT NULL,
+	schedule_id UUID NOT NULL,
+	service_type TEXT NOT NULL,
+	service_name TEXT NOT NULL,
+	action TEXT NOT NULL,
+	planned_at TIMESTAMPTZ NOT NULL,
+	started_at TIMESTAMPTZ NOT NULL,
+	finished_at TIMESTAMPTZ NOT NULL,
+	status_code INTEGER NOT NULL DEFAULT 0,
+	response_body TEXT NOT NULL DEFAULT '',
+	attempts INTEGER NOT NULL DEFAULT 0,
+	outcome TEXT NOT NULL,
+	error TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS executions_schedule_planned_at_idx ON executions (schedule_id, planned_at);
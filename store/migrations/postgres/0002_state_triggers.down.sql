@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS dead_letters_notify ON dead_letters;
+DROP TRIGGER IF EXISTS holidays_notify ON holidays;
+DROP TRIGGER IF EXISTS accounts_notify ON accounts;
+DROP TRIGGER IF EXISTS schedules_notify ON schedules;
+DROP FUNCTION IF EXISTS notify_scheduler_state();
@@ -0,0 +1,23 @@
+-- Every replica reloads its in-memory state when another one changes it.
+-- The channel name must match store.StateChannel.
+CREATE OR REPLACE FUNCTION notify_scheduler_state() RETURNS trigger AS $$
+BEGIN
+	PERFORM pg_notify('scheduler_state', TG_TABLE_NAME);
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Older versions created these triggers on every start.
+DROP TRIGGER IF EXISTS schedules_notify ON schedules;
+DROP TRIGGER IF EXISTS accounts_notify ON accounts;
+DROP TRIGGER IF EXISTS holidays_notify ON holidays;
+DROP TRIGGER IF EXISTS dead_letters_notify ON dead_letters;
+
+CREATE TRIGGER schedules_notify AFTER INSERT OR UPDATE OR DELETE ON schedules
+	FOR EACH STATEMENT EXECUTE PROCEDURE notify_scheduler_state();
+CREATE TRIGGER accounts_notify AFTER INSERT OR UPDATE OR DELETE ON accounts
+	FOR EACH STATEMENT EXECUTE PROCEDURE notify_scheduler_state();
+CREATE TRIGGER holidays_notify AFTER INSERT OR UPDATE OR DELETE ON holidays
+	FOR EACH STATEMENT EXECUTE PROCEDURE notify_scheduler_state();
+CREATE TRIGGER dead_letters_notify AFTER INSERT OR UPDATE OR DELETE ON dead_letters
+	FOR EACH STATEMENT EXECUTE PROCEDURE notify_scheduler_state();
@@ -0,0 +1,24 @@
+CREATE TABLE webhooks (
+	id UUID PRIMARY KEY,
+	owner TEXT NOT NULL,
+	url TEXT NOT NULL,
+	events TEXT NOT NULL DEFAULT '', -- comma-separated; empty for all
+	secret TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX webhooks_owner_idx ON webhooks (owner);
+
+CREATE TABLE webhook_deliveries (
+	id UUID PRIMARY KEY,
+	webhook_id UUID NOT NULL,
+	owner TEXT NOT NULL,
+	event TEXT NOT NULL,
+	payload TEXT NOT NULL,
+	status TEXT NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	status_code INTEGER NOT NULL DEFAULT 0,
+	error TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL,
+	completed_at TIMESTAMPTZ
+);
+CREATE INDEX webhook_deliveries_webhook_created_at_idx ON webhook_deliveries (webhook_id, created_at);
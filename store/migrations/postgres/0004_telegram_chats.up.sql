@@ -0,0 +1,8 @@
+CREATE TABLE telegram_chats (
+	chat_id BIGINT PRIMARY KEY,
+	owner TEXT NOT NULL,
+	title TEXT NOT NULL DEFAULT '',
+	token_ciphertext TEXT NOT NULL DEFAULT '',
+	linked_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX telegram_chats_owner_idx ON telegram_chats (owner);
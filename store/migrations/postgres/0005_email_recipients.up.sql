@@ -0,0 +1,10 @@
+CREATE TABLE email_recipients (
+	id UUID PRIMARY KEY,
+	owner TEXT NOT NULL,
+	address TEXT NOT NULL,
+	failures BOOLEAN NOT NULL DEFAULT TRUE,
+	digest TEXT NOT NULL DEFAULT '', -- daily, weekly or empty for none
+	token_ciphertext TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX email_recipients_owner_idx ON email_recipients (owner);
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS logs;
+DROP TABLE IF EXISTS executions;
+DROP TABLE IF EXISTS dead_letters;
+DROP TABLE IF EXISTS holidays;
+DROP TABLE IF EXISTS accounts;
+DROP TABLE IF EXISTS schedules;
@@ -0,0 +1,75 @@
+CREATE TABLE IF NOT EXISTS schedules (
+	id TEXT PRIMARY KEY,
+	owner TEXT NOT NULL DEFAULT '',
+	kind TEXT NOT NULL DEFAULT 'single',
+	service_name TEXT NOT NULL,
+	service_type TEXT NOT NULL,
+	action TEXT NOT NULL,
+	cron_spec TEXT NOT NULL,
+	end_cron_spec TEXT NOT NULL DEFAULT '',
+	duration TEXT NOT NULL DEFAULT '',
+	timezone TEXT NOT NULL DEFAULT '',
+	calendar_rule TEXT,
+	retry_policy TEXT,
+	enabled BOOLEAN NOT NULL DEFAULT TRUE,
+	token_ciphertext TEXT
+);
+
+CREATE TABLE IF NOT EXISTS accounts (
+	owner TEXT PRIMARY KEY,
+	paused BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE TABLE IF NOT EXISTS holidays (
+	owner TEXT NOT NULL,
+	jalali_date TEXT NOT NULL,
+	name TEXT NOT NULL,
+	PRIMARY KEY (owner, jalali_date)
+);
+
+CREATE TABLE IF NOT EXISTS dead_letters (
+	id TEXT PRIMARY KEY,
+	owner TEXT NOT NULL,
+	schedule_id TEXT,
+	service_type TEXT NOT NULL,
+	service_name TEXT NOT NULL,
+	action TEXT NOT NULL,
+	attempts INTEGER NOT NULL,
+	status_code INTEGER NOT NULL DEFAULT 0,
+	error TEXT NOT NULL,
+	failed_at INTEGER NOT NULL,
+	replayed_at INTEGER
+);
+CREATE INDEX IF NOT EXISTS dead_letters_owner_failed_at_idx ON dead_letters (owner, failed_at);
+
+CREATE TABLE IF NOT EXISTS executions (
+	id TEXT PRIMARY KEY,
+	owner TEXT NOT NULL,
+	schedule_id TEXT NOT NULL,
+	service_type TEXT NOT NULL,
+	service_name TEXT NOT NULL,
+	action TEXT NOT NULL,
+	planned_at INTEGER NOT NULL,
+	started_at INTEGER NOT NULL,
+	finished_at INTEGER NOT NULL,
+	status_code INTEGER NOT NULL DEFAULT 0,
+	response_body TEXT NOT NULL DEFAULT '',
+	attempts INTEGER NOT NULL DEFAULT 0,
+	outcome TEXT NOT NULL,
+	error TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS executions_schedule_planned_at_idx ON executions (schedule_id, planned_at);
+
+CREATE TABLE IF NOT EXISTS logs (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	owner TEXT,
+	timestamp INTEGER NOT NULL,
+	level TEXT NOT NULL DEFAULT 'INFO',
+	message TEXT NOT NULL,
+	schedule_id TEXT NOT NULL DEFAULT '',
+	execution_id TEXT NOT NULL DEFAULT '',
+	target TEXT NOT NULL DEFAULT '',
+	attrs TEXT
+);
+CREATE INDEX IF NOT EXISTS logs_owner_id_idx ON logs (owner, id);
+CREATE INDEX IF NOT EXISTS logs_owner_timestamp_idx ON logs (owner, timestamp);
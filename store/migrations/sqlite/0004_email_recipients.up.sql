@@ -0,0 +1,10 @@
+CREATE TABLE email_recipients (
+	id TEXT PRIMARY KEY,
+	owner TEXT NOT NULL,
+	address TEXT NOT NULL,
+	failures BOOLEAN NOT NULL DEFAULT TRUE,
+	digest TEXT NOT NULL DEFAULT '', -- daily, weekly or empty for none
+	token_ciphertext TEXT NOT NULL DEFAULT '',
+	created_at INTEGER NOT NULL
+);
+CREATE INDEX email_recipients_owner_idx ON email_recipients (owner);
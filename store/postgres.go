@@ -0,0 +1,115 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// schemaLockKey is the Postgres advisory lock held while migrating the
+// schema. It is arbitrary but must not change between versions.
+const schemaLockKey int64 = 0x6c69617261_02
+
+// StateChannel is notified by triggers whenever schedules, accounts, holidays
+// or dead letters change, so that replicas sharing the database can reload.
+// The triggers are created by migration 0002.
+const StateChannel = "scheduler_state"
+
+// Postgres keeps everything in a PostgreSQL database, which several
+// replicas can share.
+type Postgres struct {
+	sqlStore
+}
+
+// OpenPostgres connects to the database at connStr and applies any pending
+// migrations.
+func OpenPostgres(connStr string) (*Postgres, error) {
+	db, err := connectPostgres(connStr)
+	if err != nil {
+		return nil, err
+	}
+	if err := migrateUp(db, newPostgresMigrator); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Postgres{sqlStore{
+		db:        db,
+		timeArg:   func(t time.Time) any { return t },
+		ilike:     "ILIKE",
+		attrsText: "attrs::text",
+	}}, nil
+}
+
+// PostgresMigrator connects to the database at connStr without changing its
+// schema, for managing migrations by hand.
+func PostgresMigrator(connStr string) (*Migrator, error) {
+	db, err := connectPostgres(connStr)
+	if err != nil {
+		return nil, err
+	}
+	m, err := newPostgresMigrator(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+func connectPostgres(connStr string) (*sql.DB, error) {
+	db, err := sql.Open("postgres", connStr)
+	if err != nil {
+		return nil, fmt.Errorf("opening database connection: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("connecting to the database: %w", err)
+	}
+	return db, nil
+}
+
+// DB returns the connection pool, for the features only Postgres offers:
+// leader election and change notifications.
+func (p *Postgres) DB() *sql.DB {
+	return p.db
+}
+
+// AdoptUnownedSchedules gives the schedules created before ownership existed
+// to owner.
+func (p *Postgres) AdoptUnownedSchedules(owner string) (int64, error) {
+	result, err := p.db.Exec("UPDATE schedules SET owner = $1 WHERE owner = ''", owner)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// newPostgresMigrator returns the migrator of the Postgres schema. Replicas
+// starting together take turns holding an advisory lock.
+func newPostgresMigrator(db *sql.DB) (*Migrator, error) {
+	migrations, err := loadMigrations("migrations/postgres")
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		db:         db,
+		migrations: migrations,
+		createTable: `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL
+		);`,
+		timeArg: func(t time.Time) any { return t },
+		lock: func(ctx context.Context, conn *sql.Conn) (func(), error) {
+			if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", schemaLockKey); err != nil {
+				return nil, err
+			}
+			return func() {
+				conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", schemaLockKey)
+			}, nil
+		},
+	}, nil
+}
@@ -0,0 +1,715 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sqlStore implements Store on a SQL database. Postgres and SQLite share it;
+// they differ only in how timestamps are stored and in a few operators.
+type sqlStore struct {
+	db *sql.DB
+	// timeArg converts a time into a query argument that compares in time
+	// order.
+	timeArg func(time.Time) any
+	// ilike is the case-insensitive LIKE operator.
+	ilike string
+	// attrsText is the logs.attrs column as text.
+	attrsText string
+}
+
+// timeValue scans a timestamp stored natively or as Unix nanoseconds; NULL
+// scans as the zero time.
+type timeValue struct{ t *time.Time }
+
+func (v timeValue) Scan(src any) error {
+	switch src := src.(type) {
+	case nil:
+		*v.t = time.Time{}
+	case time.Time:
+		*v.t = src
+	case int64:
+		*v.t = time.Unix(0, src).UTC()
+	default:
+		return fmt.Errorf("cannot scan %T into a time", src)
+	}
+	return nil
+}
+
+// nullTimeValue is timeValue for optional timestamps; NULL scans as nil.
+type nullTimeValue struct{ t **time.Time }
+
+func (v nullTimeValue) Scan(src any) error {
+	if src == nil {
+		*v.t = nil
+		return nil
+	}
+	var t time.Time
+	if err := (timeValue{&t}).Scan(src); err != nil {
+		return err
+	}
+	*v.t = &t
+	return nil
+}
+
+// nullIfEmpty stores an empty string as NULL.
+func nullIfEmpty(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// nullJSON stores a nil JSON document as NULL.
+func nullJSON(b []byte) sql.NullString {
+	return sql.NullString{String: string(b), Valid: b != nil}
+}
+
+// expectRow turns an update that changed nothing into ErrNotFound.
+func expectRow(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *sqlStore) Schedules() ([]Schedule, error) {
+	rows, err := s.db.Query("SELECT id, owner, kind, service_name, service_type, action, cron_spec, end_cron_spec, duration, timezone, calendar_rule, retry_policy, enabled, token_ciphertext FROM schedules")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schedules := make([]Schedule, 0)
+	for rows.Next() {
+		var sched Schedule
+		var calendarRule, retryPolicy []byte
+		var tokenCiphertext sql.NullString
+		if err := rows.Scan(&sched.ID, &sched.Owner, &sched.Kind, &sched.ServiceName, &sched.ServiceType, &sched.Action, &sched.CronSpec, &sched.EndCronSpec,
+			&sched.Duration, &sched.Timezone, &calendarRule, &retryPolicy, &sched.Enabled, &tokenCiphertext); err != nil {
+			return nil, err
+		}
+		// The driver may reuse the buffers of []byte columns.
+		if calendarRule != nil {
+			sched.CalendarRule = append([]byte(nil), calendarRule...)
+		}
+		if retryPolicy != nil {
+			sched.RetryPolicy = append([]byte(nil), retryPolicy...)
+		}
+		sched.TokenCiphertext = tokenCiphertext.String
+		schedules = append(schedules, sched)
+	}
+	return schedules, rows.Err()
+}
+
+func (s *sqlStore) CreateSchedule(sched Schedule) error {
+	_, err := s.db.Exec("INSERT INTO schedules (id, owner, kind, service_name, service_type, action, cron_spec, end_cron_spec, duration, timezone, calendar_rule, retry_policy, enabled, token_ciphertext) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)",
+		sched.ID, sched.Owner, sched.Kind, sched.ServiceName, sched.ServiceType, sched.Action, sched.CronSpec, sched.EndCronSpec, sched.Duration, sched.Timezone,
+		nullJSON(sched.CalendarRule), nullJSON(sched.RetryPolicy), sched.Enabled, nullIfEmpty(sched.TokenCiphertext))
+	return err
+}
+
+func (s *sqlStore) UpdateSchedule(sched Schedule) error {
+	return expectRow(s.db.Exec("UPDATE schedules SET service_name = $1, service_type = $2, action = $3, cron_spec = $4, end_cron_spec = $5, duration = $6, timezone = $7, calendar_rule = $8, retry_policy = $9, enabled = $10 WHERE id = $11 AND owner = $12",
+		sched.ServiceName, sched.ServiceType, sched.Action, sched.CronSpec, sched.EndCronSpec, sched.Duration, sched.Timezone,
+		nullJSON(sched.CalendarRule), nullJSON(sched.RetryPolicy), sched.Enabled, sched.ID, sched.Owner))
+}
+
+func (s *sqlStore) DeleteSchedule(owner, id string) error {
+	return expectRow(s.db.Exec("DELETE FROM schedules WHERE id = $1 AND owner = $2", id, owner))
+}
+
+func (s *sqlStore) Credentials() ([]Credential, error) {
+	rows, err := s.db.Query("SELECT id, owner, token_ciphertext FROM schedules WHERE token_ciphertext IS NOT NULL")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	credentials := make([]Credential, 0)
+	for rows.Next() {
+		var c Credential
+		if err := rows.Scan(&c.ScheduleID, &c.Owner, &c.Ciphertext); err != nil {
+			return nil, err
+		}
+		credentials = append(credentials, c)
+	}
+	return credentials, rows.Err()
+}
+
+func (s *sqlStore) UpdateCredentials(credentials []Credential) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, c := range credentials {
+		if _, err := tx.Exec("UPDATE schedules SET token_ciphertext = $1 WHERE id = $2 AND owner = $3", c.Ciphertext, c.ScheduleID, c.Owner); err != nil {
+			return fmt.Errorf("schedule %s: %w", c.ScheduleID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) PausedAccounts() ([]string, error) {
+	rows, err := s.db.Query("SELECT owner FROM accounts WHERE paused")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	owners := make([]string, 0)
+	for rows.Next() {
+		var owner string
+		if err := rows.Scan(&owner); err != nil {
+			return nil, err
+		}
+		owners = append(owners, owner)
+	}
+	return owners, rows.Err()
+}
+
+func (s *sqlStore) SetAccountPaused(owner string, paused bool) error {
+	_, err := s.db.Exec("INSERT INTO accounts (owner, paused) VALUES ($1, $2) ON CONFLICT (owner) DO UPDATE SET paused = EXCLUDED.paused",
+		owner, paused)
+	return err
+}
+
+func (s *sqlStore) Holidays() ([]Holiday, error) {
+	rows, err := s.db.Query("SELECT owner, jalali_date, name FROM holidays")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	holidays := make([]Holiday, 0)
+	for rows.Next() {
+		var h Holiday
+		if err := rows.Scan(&h.Owner, &h.Date, &h.Name); err != nil {
+			return nil, err
+		}
+		holidays = append(holidays, h)
+	}
+	return holidays, rows.Err()
+}
+
+func (s *sqlStore) ReplaceHolidays(owner string, holidays []Holiday) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM holidays WHERE owner = $1", owner); err != nil {
+		return err
+	}
+	for _, h := range holidays {
+		_, err := tx.Exec("INSERT INTO holidays (owner, jalali_date, name) VALUES ($1, $2, $3) ON CONFLICT (owner, jalali_date) DO UPDATE SET name = EXCLUDED.name",
+			owner, h.Date, h.Name)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) DeadLetters() ([]DeadLetter, error) {
+	rows, err := s.db.Query("SELECT id, owner, schedule_id, service_type, service_name, action, attempts, status_code, error, failed_at, replayed_at FROM dead_letters ORDER BY failed_at ASC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	letters := make([]DeadLetter, 0)
+	for rows.Next() {
+		var letter DeadLetter
+		var scheduleID sql.NullString
+		if err := rows.Scan(&letter.ID, &letter.Owner, &scheduleID, &letter.ServiceType, &letter.ServiceName, &letter.Action,
+			&letter.Attempts, &letter.StatusCode, &letter.Error, timeValue{&letter.FailedAt}, nullTimeValue{&letter.ReplayedAt}); err != nil {
+			return nil, err
+		}
+		letter.ScheduleID = scheduleID.String
+		letters = append(letters, letter)
+	}
+	return letters, rows.Err()
+}
+
+func (s *sqlStore) AddDeadLetter(letter DeadLetter) error {
+	var replayedAt any
+	if letter.ReplayedAt != nil {
+		replayedAt = s.timeArg(*letter.ReplayedAt)
+	}
+	_, err := s.db.Exec("INSERT INTO dead_letters (id, owner, schedule_id, service_type, service_name, action, attempts, status_code, error, failed_at, replayed_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)",
+		letter.ID, letter.Owner, nullIfEmpty(letter.ScheduleID), letter.ServiceType, letter.ServiceName, letter.Action, letter.Attempts, letter.StatusCode,
+		letter.Error, s.timeArg(letter.FailedAt), replayedAt)
+	return err
+}
+
+func (s *sqlStore) DeleteDeadLetter(owner, id string) error {
+	return expectRow(s.db.Exec("DELETE FROM dead_letters WHERE id = $1 AND owner = $2", id, owner))
+}
+
+func (s *sqlStore) MarkDeadLetterReplayed(owner, id string, at time.Time) error {
+	err := expectRow(s.db.Exec("UPDATE dead_letters SET replayed_at = $1 WHERE id = $2 AND owner = $3 AND replayed_at IS NULL",
+		s.timeArg(at), id, owner))
+	if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	var exists bool
+	if err := s.db.QueryRow("SELECT EXISTS (SELECT 1 FROM dead_letters WHERE id = $1 AND owner = $2)", id, owner).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return ErrAlreadyReplayed
+	}
+	return ErrNotFound
+}
+
+func (s *sqlStore) AddExecution(e Execution) error {
+	_, err := s.db.Exec("INSERT INTO executions (id, owner, schedule_id, service_type, service_name, action, planned_at, started_at, finished_at, status_code, response_body, attempts, outcome, error) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)",
+		e.ID, e.Owner, e.ScheduleID, e.ServiceType, e.ServiceName, e.Action, s.timeArg(e.PlannedAt), s.timeArg(e.StartedAt), s.timeArg(e.FinishedAt),
+		e.StatusCode, e.ResponseBody, e.Attempts, e.Outcome, e.Error)
+	return err
+}
+
+// conditions builds a WHERE clause whose "?" placeholders are numbered in
+// the order the conditions are added.
+type conditions struct {
+	clauses []string
+	args    []any
+}
+
+func (c *conditions) add(clause string, args ...any) {
+	for _, arg := range args {
+		c.args = append(c.args, arg)
+		clause = strings.Replace(clause, "?", "$"+strconv.Itoa(len(c.args)), 1)
+	}
+	c.clauses = append(c.clauses, clause)
+}
+
+func (c *conditions) String() string {
+	return strings.Join(c.clauses, " AND ")
+}
+
+func (s *sqlStore) Executions(f ExecutionFilter) ([]Execution, int, error) {
+	var where conditions
+	where.add("owner = ?", f.Owner)
+	where.add("schedule_id = ?", f.ScheduleID)
+	if !f.From.IsZero() {
+		where.add("planned_at >= ?", s.timeArg(f.From))
+	}
+	if !f.To.IsZero() {
+		where.add("planned_at < ?", s.timeArg(f.To))
+	}
+
+	var total int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM executions WHERE "+where.String(), where.args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.db.Query("SELECT id, schedule_id, service_type, service_name, action, planned_at, started_at, finished_at, status_code, response_body, attempts, outcome, error FROM executions WHERE "+where.String()+
+		" ORDER BY planned_at DESC, started_at DESC LIMIT "+strconv.Itoa(f.Limit)+" OFFSET "+strconv.Itoa(f.Offset), where.args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	executions := make([]Execution, 0)
+	for rows.Next() {
+		e := Execution{Owner: f.Owner}
+		if err := rows.Scan(&e.ID, &e.ScheduleID, &e.ServiceType, &e.ServiceName, &e.Action, timeValue{&e.PlannedAt}, timeValue{&e.StartedAt}, timeValue{&e.FinishedAt},
+			&e.StatusCode, &e.ResponseBody, &e.Attempts, &e.Outcome, &e.Error); err != nil {
+			return nil, 0, err
+		}
+		executions = append(executions, e)
+	}
+	return executions, total, rows.Err()
+}
+
+func (s *sqlStore) AppendLogs(entries []LogEntry) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare("INSERT INTO logs (owner, timestamp, level, message, schedule_id, execution_id, target, attrs) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	ids := make([]int64, len(entries))
+	for i, entry := range entries {
+		attrs, err := marshalAttrs(entry.Attrs)
+		if err != nil {
+			return err
+		}
+		if err := stmt.QueryRow(entry.Owner, s.timeArg(entry.Timestamp), entry.Level, entry.Message, entry.ScheduleID, entry.ExecutionID, entry.Target, attrs).Scan(&ids[i]); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	for i := range entries {
+		entries[i].ID = ids[i]
+	}
+	return nil
+}
+
+// marshalAttrs encodes attributes for the attrs column; no attributes are
+// stored as NULL.
+func marshalAttrs(attrs map[string]string) (sql.NullString, error) {
+	if len(attrs) == 0 {
+		return sql.NullString{}, nil
+	}
+	encoded, err := json.Marshal(attrs)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(encoded), Valid: true}, nil
+}
+
+const logColumns = "id, COALESCE(owner, ''), timestamp, level, message, schedule_id, execution_id, target, attrs"
+
+func scanLogs(rows *sql.Rows) ([]LogEntry, error) {
+	defer rows.Close()
+	entries := make([]LogEntry, 0)
+	for rows.Next() {
+		var e LogEntry
+		var attrs []byte
+		if err := rows.Scan(&e.ID, &e.Owner, timeValue{&e.Timestamp}, &e.Level, &e.Message, &e.ScheduleID, &e.ExecutionID, &e.Target, &attrs); err != nil {
+			return nil, err
+		}
+		if attrs != nil {
+			if err := json.Unmarshal(attrs, &e.Attrs); err != nil {
+				return nil, fmt.Errorf("decoding attributes of log %d: %w", e.ID, err)
+			}
+		}
+		// Entries written before logs were structured end in a newline.
+		e.Message = strings.TrimSuffix(e.Message, "\n")
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func (s *sqlStore) Logs(f LogFilter, newestFirst bool) ([]LogEntry, error) {
+	var where conditions
+	where.add("owner = ?", f.Owner)
+	if f.LevelSet {
+		levels := levelsFrom(f.MinLevel)
+		args := make([]any, len(levels))
+		for i, level := range levels {
+			args[i] = level
+		}
+		where.add("level IN ("+strings.TrimSuffix(strings.Repeat("?, ", len(levels)), ", ")+")", args...)
+	}
+	if f.ScheduleID != "" {
+		where.add("schedule_id = ?", f.ScheduleID)
+	}
+	if f.Target != "" {
+		where.add("target = ?", f.Target)
+	}
+	if !f.From.IsZero() {
+		where.add("timestamp >= ?", s.timeArg(f.From))
+	}
+	if !f.To.IsZero() {
+		where.add("timestamp < ?", s.timeArg(f.To))
+	}
+	if f.Text != "" {
+		pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(f.Text) + "%"
+		where.add("(message "+s.ilike+" ? ESCAPE '\\' OR "+s.attrsText+" "+s.ilike+" ? ESCAPE '\\')", pattern, pattern)
+	}
+	if f.Before > 0 {
+		where.add("id < ?", f.Before)
+	}
+
+	query := "SELECT " + logColumns + " FROM logs WHERE " + where.String()
+	if newestFirst {
+		query += " ORDER BY id DESC"
+	} else {
+		query += " ORDER BY id ASC"
+	}
+	if f.Limit > 0 {
+		query += " LIMIT " + strconv.Itoa(f.Limit)
+	}
+
+	rows, err := s.db.Query(query, where.args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanLogs(rows)
+}
+
+func (s *sqlStore) PruneLogs(opts PruneOptions) (int, error) {
+	total := 0
+	if !opts.Before.IsZero() {
+		n, err := s.pruneLogBatches(opts,
+			"SELECT id FROM logs WHERE timestamp < $1 ORDER BY id LIMIT $2", s.timeArg(opts.Before))
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	if opts.MaxEntries > 0 {
+		owners, err := s.ownersOverLimit(opts.MaxEntries)
+		if err != nil {
+			return total, err
+		}
+		for _, owner := range owners {
+			// Everything below the MaxEntries-th newest ID goes.
+			n, err := s.pruneLogBatches(opts,
+				"SELECT id FROM logs WHERE owner = $1 AND id < (SELECT id FROM logs WHERE owner = $1 ORDER BY id DESC LIMIT 1 OFFSET "+strconv.Itoa(opts.MaxEntries-1)+") ORDER BY id LIMIT $2", owner)
+			total += n
+			if err != nil {
+				return total, err
+			}
+		}
+	}
+	return total, nil
+}
+
+// ownersOverLimit lists the owners with more than max log entries.
+func (s *sqlStore) ownersOverLimit(max int) ([]string, error) {
+	rows, err := s.db.Query("SELECT owner FROM logs WHERE owner IS NOT NULL GROUP BY owner HAVING COUNT(*) > $1", max)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var owners []string
+	for rows.Next() {
+		var owner string
+		if err := rows.Scan(&owner); err != nil {
+			return nil, err
+		}
+		owners = append(owners, owner)
+	}
+	return owners, rows.Err()
+}
+
+// pruneLogBatches repeatedly deletes the rows selected by selectIDs, which
+// takes arg as $1 and the batch size as $2, until none are left. Each batch
+// is archived within its transaction, so rows are only deleted once their
+// archive has been written.
+func (s *sqlStore) pruneLogBatches(opts PruneOptions, selectIDs string, arg any) (int, error) {
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+	total := 0
+	for {
+		n, err := s.pruneLogBatch(opts.Archive, batchSize, selectIDs, arg)
+		total += n
+		if err != nil || n < batchSize {
+			return total, err
+		}
+	}
+}
+
+func (s *sqlStore) pruneLogBatch(archive func([]LogEntry) error, batchSize int, selectIDs string, arg any) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query("DELETE FROM logs WHERE id IN ("+selectIDs+") RETURNING "+logColumns, arg, batchSize)
+	if err != nil {
+		return 0, err
+	}
+	entries, err := scanLogs(rows)
+	if err != nil {
+		return 0, err
+	}
+	if archive != nil && len(entries) > 0 {
+		if err := archive(entries); err != nil {
+			return 0, err
+		}
+	}
+	return len(entries), tx.Commit()
+}
+
+func (s *sqlStore) Webhooks(owner string) ([]Webhook, error) {
+	rows, err := s.db.Query("SELECT id, owner, url, events, secret, created_at FROM webhooks WHERE owner = $1 ORDER BY created_at ASC, id ASC", owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := make([]Webhook, 0)
+	for rows.Next() {
+		var w Webhook
+		var events string
+		if err := rows.Scan(&w.ID, &w.Owner, &w.URL, &events, &w.Secret, timeValue{&w.CreatedAt}); err != nil {
+			return nil, err
+		}
+		if events != "" {
+			w.Events = strings.Split(events, ",")
+		}
+		webhooks = append(webhooks, w)
+	}
+	return webhooks, rows.Err()
+}
+
+func (s *sqlStore) CreateWebhook(w Webhook) error {
+	_, err := s.db.Exec("INSERT INTO webhooks (id, owner, url, events, secret, created_at) VALUES ($1, $2, $3, $4, $5, $6)",
+		w.ID, w.Owner, w.URL, strings.Join(w.Events, ","), w.Secret, s.timeArg(w.CreatedAt))
+	return err
+}
+
+func (s *sqlStore) DeleteWebhook(owner, id string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := expectRow(tx.Exec("DELETE FROM webhooks WHERE id = $1 AND owner = $2", id, owner)); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM webhook_deliveries WHERE webhook_id = $1", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) SaveWebhookDelivery(d WebhookDelivery) error {
+	var completedAt any
+	if d.CompletedAt != nil {
+		completedAt = s.timeArg(*d.CompletedAt)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO webhook_deliveries (id, webhook_id, owner, event, payload, status, attempts, status_code, error, created_at, completed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (id) DO UPDATE SET status = EXCLUDED.status, attempts = EXCLUDED.attempts, status_code = EXCLUDED.status_code,
+			error = EXCLUDED.error, completed_at = EXCLUDED.completed_at`,
+		d.ID, d.WebhookID, d.Owner, d.Event, string(d.Payload), d.Status, d.Attempts, d.StatusCode, d.Error, s.timeArg(d.CreatedAt), completedAt)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`DELETE FROM webhook_deliveries WHERE webhook_id = $1 AND id NOT IN
+		(SELECT id FROM webhook_deliveries WHERE webhook_id = $1 ORDER BY created_at DESC, id DESC LIMIT $2)`,
+		d.WebhookID, WebhookDeliveriesKept)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) WebhookDeliveries(owner, webhookID string, limit int) ([]WebhookDelivery, error) {
+	rows, err := s.db.Query("SELECT id, webhook_id, owner, event, payload, status, attempts, status_code, error, created_at, completed_at FROM webhook_deliveries WHERE owner = $1 AND webhook_id = $2 ORDER BY created_at DESC, id DESC LIMIT $3",
+		owner, webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := make([]WebhookDelivery, 0)
+	for rows.Next() {
+		var d WebhookDelivery
+		var payload string
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.Owner, &d.Event, &payload, &d.Status, &d.Attempts, &d.StatusCode, &d.Error,
+			timeValue{&d.CreatedAt}, nullTimeValue{&d.CompletedAt}); err != nil {
+			return nil, err
+		}
+		d.Payload = json.RawMessage(payload)
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+func (s *sqlStore) TelegramChats() ([]TelegramChat, error) {
+	rows, err := s.db.Query("SELECT chat_id, owner, title, token_ciphertext, linked_at FROM telegram_chats ORDER BY linked_at ASC, chat_id ASC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	chats := make([]TelegramChat, 0)
+	for rows.Next() {
+		var c TelegramChat
+		if err := rows.Scan(&c.ChatID, &c.Owner, &c.Title, &c.TokenCiphertext, timeValue{&c.LinkedAt}); err != nil {
+			return nil, err
+		}
+		chats = append(chats, c)
+	}
+	return chats, rows.Err()
+}
+
+func (s *sqlStore) TelegramChat(chatID int64) (TelegramChat, error) {
+	c := TelegramChat{ChatID: chatID}
+	err := s.db.QueryRow("SELECT owner, title, token_ciphertext, linked_at FROM telegram_chats WHERE chat_id = $1", chatID).
+		Scan(&c.Owner, &c.Title, &c.TokenCiphertext, timeValue{&c.LinkedAt})
+	if errors.Is(err, sql.ErrNoRows) {
+		return c, ErrNotFound
+	}
+	return c, err
+}
+
+func (s *sqlStore) LinkTelegramChat(c TelegramChat) error {
+	_, err := s.db.Exec(`INSERT INTO telegram_chats (chat_id, owner, title, token_ciphertext, linked_at) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (chat_id) DO UPDATE SET owner = EXCLUDED.owner, title = EXCLUDED.title,
+			token_ciphertext = EXCLUDED.token_ciphertext, linked_at = EXCLUDED.linked_at`,
+		c.ChatID, c.Owner, c.Title, c.TokenCiphertext, s.timeArg(c.LinkedAt))
+	return err
+}
+
+func (s *sqlStore) UnlinkTelegramChat(owner string, chatID int64) error {
+	return expectRow(s.db.Exec("DELETE FROM telegram_chats WHERE chat_id = $1 AND owner = $2", chatID, owner))
+}
+
+func (s *sqlStore) EmailRecipients() ([]EmailRecipient, error) {
+	rows, err := s.db.Query("SELECT id, owner, address, failures, digest, token_ciphertext, created_at FROM email_recipients ORDER BY created_at ASC, id ASC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	recipients := make([]EmailRecipient, 0)
+	for rows.Next() {
+		var r EmailRecipient
+		if err := rows.Scan(&r.ID, &r.Owner, &r.Address, &r.Failures, &r.Digest, &r.TokenCiphertext, timeValue{&r.CreatedAt}); err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, r)
+	}
+	return recipients, rows.Err()
+}
+
+func (s *sqlStore) SaveEmailRecipient(r EmailRecipient) error {
+	_, err := s.db.Exec(`INSERT INTO email_recipients (id, owner, address, failures, digest, token_ciphertext, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (id) DO UPDATE SET owner = EXCLUDED.owner, address = EXCLUDED.address, failures = EXCLUDED.failures,
+			digest = EXCLUDED.digest, token_ciphertext = EXCLUDED.token_ciphertext, created_at = EXCLUDED.created_at`,
+		r.ID, r.Owner, r.Address, r.Failures, r.Digest, r.TokenCiphertext, s.timeArg(r.CreatedAt))
+	return err
+}
+
+func (s *sqlStore) DeleteEmailRecipient(owner, id string) error {
+	return expectRow(s.db.Exec("DELETE FROM email_recipients WHERE id = $1 AND owner = $2", id, owner))
+}
+
+func (s *sqlStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+func (s *sqlStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,91 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLite keeps everything in a single SQLite database file, for a server
+// running alone. Timestamps are stored as Unix nanoseconds, which compare in
+// time order whatever the time zone they were written in.
+type SQLite struct {
+	sqlStore
+}
+
+// OpenSQLite opens the database file at path, creating it if needed, and
+// applies any pending migrations.
+func OpenSQLite(path string) (*SQLite, error) {
+	db, err := connectSQLite(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := migrateUp(db, newSQLiteMigrator); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrating %s: %w", path, err)
+	}
+	return &SQLite{sqlStore{
+		db:        db,
+		timeArg:   func(t time.Time) any { return t.UnixNano() },
+		ilike:     "LIKE", // case-insensitive for ASCII
+		attrsText: "attrs",
+	}}, nil
+}
+
+// SQLiteMigrator opens the database file at path without changing its
+// schema, for managing migrations by hand.
+func SQLiteMigrator(path string) (*Migrator, error) {
+	db, err := connectSQLite(path)
+	if err != nil {
+		return nil, err
+	}
+	m, err := newSQLiteMigrator(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+func connectSQLite(path string) (*sql.DB, error) {
+	// Transactions take the write lock when they begin, so two processes
+	// migrating the same file cannot both apply a migration.
+	dsn := "file:" + (&url.URL{Path: path}).EscapedPath() + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_txlock=immediate"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", path, err)
+	}
+	// SQLite has a single writer; one connection avoids "database is
+	// locked" errors between the pool's own connections.
+	db.SetMaxOpenConns(1)
+	return db, nil
+}
+
+// newSQLiteMigrator returns the migrator of the SQLite schema. A SQLite file
+// serves a single server, so there is no lock beyond the transaction each
+// migration runs in; of two concurrent runs the later one fails instead of
+// applying a migration twice.
+func newSQLiteMigrator(db *sql.DB) (*Migrator, error) {
+	migrations, err := loadMigrations("migrations/sqlite")
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		db:         db,
+		migrations: migrations,
+		createTable: `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at INTEGER NOT NULL
+		);`,
+		timeArg: func(t time.Time) any { return t.UnixNano() },
+		lock: func(ctx context.Context, conn *sql.Conn) (func(), error) {
+			return func() {}, nil
+		},
+	}, nil
+}
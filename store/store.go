@@ -0,0 +1,350 @@
+// Package store persists what the scheduler knows across restarts: schedules
+// and the credentials they run with, account pause switches, holidays, dead
+// letters, the execution history, the audit logs, webhooks with their
+// deliveries, linked Telegram chats and email recipients. Postgres suits
+// deployments with several replicas; SQLite and plain files suit a single
+// server, and a file store without a directory keeps everything in memory.
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when a record to change does not exist or belongs
+// to another owner.
+var ErrNotFound = errors.New("not found")
+
+// ErrAlreadyReplayed is returned when a dead letter is marked as replayed a
+// second time.
+var ErrAlreadyReplayed = errors.New("already replayed")
+
+// Store is implemented by every backend. Records are always scoped to an
+// owner where the methods take one.
+type Store interface {
+	// Schedules returns every stored schedule.
+	Schedules() ([]Schedule, error)
+	CreateSchedule(s Schedule) error
+	// UpdateSchedule replaces the definition of the owner's schedule s.ID;
+	// its credential is left alone.
+	UpdateSchedule(s Schedule) error
+	DeleteSchedule(owner, id string) error
+
+	// Credentials returns the stored token of every schedule that has one.
+	Credentials() ([]Credential, error)
+	// UpdateCredentials replaces the given schedules' tokens, all or none.
+	UpdateCredentials(credentials []Credential) error
+
+	// PausedAccounts returns the owners whose schedules are all paused.
+	PausedAccounts() ([]string, error)
+	SetAccountPaused(owner string, paused bool) error
+
+	// Holidays returns every owner's custom holidays.
+	Holidays() ([]Holiday, error)
+	// ReplaceHolidays replaces all of the owner's custom holidays.
+	ReplaceHolidays(owner string, holidays []Holiday) error
+
+	// DeadLetters returns every dead letter, oldest first.
+	DeadLetters() ([]DeadLetter, error)
+	AddDeadLetter(letter DeadLetter) error
+	DeleteDeadLetter(owner, id string) error
+	// MarkDeadLetterReplayed claims the owner's dead letter for a replay. It
+	// returns ErrAlreadyReplayed if the letter was claimed before, so that
+	// only one request, on any replica, replays it.
+	MarkDeadLetterReplayed(owner, id string, at time.Time) error
+
+	AddExecution(e Execution) error
+	// Executions returns the page of executions selected by f, newest
+	// first, and how many match f in total.
+	Executions(f ExecutionFilter) ([]Execution, int, error)
+
+	// AppendLogs stores entries and assigns their IDs, which increase in
+	// the order entries are stored.
+	AppendLogs(entries []LogEntry) error
+	// Logs returns the entries selected by f, newest first if newestFirst
+	// is set and oldest first otherwise.
+	Logs(f LogFilter, newestFirst bool) ([]LogEntry, error)
+	// PruneLogs deletes the entries beyond the retention limits and returns
+	// how many it deleted.
+	PruneLogs(opts PruneOptions) (int, error)
+
+	// Webhooks returns the owner's webhooks, oldest first.
+	Webhooks(owner string) ([]Webhook, error)
+	CreateWebhook(w Webhook) error
+	// DeleteWebhook deletes the owner's webhook and its deliveries.
+	DeleteWebhook(owner, id string) error
+	// SaveWebhookDelivery stores d, replacing the stored delivery with the
+	// same ID. Only the latest WebhookDeliveriesKept deliveries of each
+	// webhook are kept.
+	SaveWebhookDelivery(d WebhookDelivery) error
+	// WebhookDeliveries returns up to limit deliveries of the owner's
+	// webhook, newest first.
+	WebhookDeliveries(owner, webhookID string, limit int) ([]WebhookDelivery, error)
+
+	// TelegramChats returns every linked Telegram chat, oldest link first.
+	TelegramChats() ([]TelegramChat, error)
+	// TelegramChat returns the chat with the given ID, whatever its owner.
+	TelegramChat(chatID int64) (TelegramChat, error)
+	// LinkTelegramChat stores c, replacing the stored link of the same chat.
+	LinkTelegramChat(c TelegramChat) error
+	UnlinkTelegramChat(owner string, chatID int64) error
+
+	// EmailRecipients returns every account's email recipients, oldest
+	// first.
+	EmailRecipients() ([]EmailRecipient, error)
+	// SaveEmailRecipient stores r, replacing the stored recipient with the
+	// same ID.
+	SaveEmailRecipient(r EmailRecipient) error
+	DeleteEmailRecipient(owner, id string) error
+
+	// Ping checks that the backend is reachable.
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+// Schedule is the stored definition of a schedule. The calendar rule and
+// retry policy are kept as JSON, nil when the schedule has none.
+type Schedule struct {
+	ID           string
+	Owner        string
+	Kind         string
+	ServiceName  string
+	ServiceType  string
+	Action       string
+	CronSpec     string
+	EndCronSpec  string
+	Duration     string
+	Timezone     string
+	CalendarRule []byte
+	RetryPolicy  []byte
+	Enabled      bool
+	// TokenCiphertext is the encrypted token the schedule runs with, empty
+	// when it is not stored.
+	TokenCiphertext string
+}
+
+// Credential is the encrypted token of a schedule.
+type Credential struct {
+	ScheduleID string
+	Owner      string
+	Ciphertext string
+}
+
+// Holiday is a custom holiday of an owner on a Jalali date (YYYY-MM-DD).
+type Holiday struct {
+	Owner string
+	Date  string
+	Name  string
+}
+
+// DeadLetter is a scale action that failed for good, kept so it can be
+// inspected and replayed.
+type DeadLetter struct {
+	ID          string     `json:"id"`
+	Owner       string     `json:"-"`
+	ScheduleID  string     `json:"scheduleId,omitempty"`
+	ServiceType string     `json:"serviceType"`
+	ServiceName string     `json:"serviceName"`
+	Action      string     `json:"action"`
+	Attempts    int        `json:"attempts"`
+	StatusCode  int        `json:"statusCode,omitempty"` // last API status; 0 if the API was never reached
+	Error       string     `json:"error"`
+	FailedAt    time.Time  `json:"failedAt"`
+	ReplayedAt  *time.Time `json:"replayedAt,omitempty"`
+}
+
+// Execution is one run of a schedule's action, including all its attempts.
+type Execution struct {
+	ID           string    `json:"id"`
+	ScheduleID   string    `json:"scheduleId"`
+	Owner        string    `json:"-"`
+	ServiceType  string    `json:"serviceType"`
+	ServiceName  string    `json:"serviceName"`
+	Action       string    `json:"action"`
+	PlannedAt    time.Time `json:"plannedAt"`
+	StartedAt    time.Time `json:"startedAt"`
+	FinishedAt   time.Time `json:"finishedAt"`
+	StatusCode   int       `json:"statusCode,omitempty"` // of the last attempt; 0 if the API was never reached
+	ResponseBody string    `json:"responseBody,omitempty"`
+	Attempts     int       `json:"attempts"`
+	Outcome      string    `json:"outcome"`
+	Error        string    `json:"error,omitempty"` // the last error, or why the run was skipped
+}
+
+// Webhook is an account's subscription to events, which are sent to URL as
+// signed HTTP POST requests.
+type Webhook struct {
+	ID        string    `json:"id"`
+	Owner     string    `json:"-"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"` // event types sent; empty for all
+	Secret    string    `json:"-"`      // HMAC key of the signatures
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// WebhookDeliveriesKept is how many deliveries of each webhook are kept.
+const WebhookDeliveriesKept = 100
+
+// WebhookDelivery is one event sent to a webhook, including all its attempts.
+type WebhookDelivery struct {
+	ID          string          `json:"id"`
+	WebhookID   string          `json:"webhookId"`
+	Owner       string          `json:"-"`
+	Event       string          `json:"event"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"` // pending, delivered or failed
+	Attempts    int             `json:"attempts"`
+	StatusCode  int             `json:"statusCode,omitempty"` // of the last attempt; 0 if there was no response
+	Error       string          `json:"error,omitempty"`      // of the last attempt
+	CreatedAt   time.Time       `json:"createdAt"`
+	CompletedAt *time.Time      `json:"completedAt,omitempty"` // once delivered or given up on
+}
+
+// TelegramChat is a Telegram chat linked to an account. It receives the
+// account's notifications and may control it through the bot.
+type TelegramChat struct {
+	ChatID int64  `json:"chatId"`
+	Owner  string `json:"-"`
+	Title  string `json:"title"` // the chat's title or user name when it was linked
+	// TokenCiphertext is the encrypted token the chat's commands run with,
+	// empty when it is not stored.
+	TokenCiphertext string    `json:"-"`
+	LinkedAt        time.Time `json:"linkedAt"`
+}
+
+// EmailRecipient is an address that receives an account's notifications by
+// email.
+type EmailRecipient struct {
+	ID       string `json:"id"`
+	Owner    string `json:"-"`
+	Address  string `json:"address"`
+	Failures bool   `json:"failures"` // whether failed runs are sent right away
+	Digest   string `json:"digest"`   // "daily", "weekly" or "" for no digest
+	// TokenCiphertext is the encrypted token the digest looks up prices
+	// with, empty when it is not stored.
+	TokenCiphertext string    `json:"-"`
+	CreatedAt       time.Time `json:"createdAt"`
+}
+
+// ExecutionFilter selects a page of a schedule's executions. From and To
+// bound the planned time and may be zero.
+type ExecutionFilter struct {
+	Owner      string
+	ScheduleID string
+	From, To   time.Time
+	Limit      int
+	Offset     int
+}
+
+func (f ExecutionFilter) Matches(e Execution) bool {
+	return e.Owner == f.Owner && e.ScheduleID == f.ScheduleID &&
+		(f.From.IsZero() || !e.PlannedAt.Before(f.From)) &&
+		(f.To.IsZero() || e.PlannedAt.Before(f.To))
+}
+
+// LogEntry is one audit entry of an account. The well-known attributes have
+// fields of their own so they can be filtered on; the rest go into Attrs.
+type LogEntry struct {
+	ID          int64             `json:"id"`
+	Owner       string            `json:"-"`
+	Timestamp   time.Time         `json:"timestamp"`
+	Level       string            `json:"level"`
+	Message     string            `json:"message"`
+	ScheduleID  string            `json:"scheduleId,omitempty"`
+	ExecutionID string            `json:"executionId,omitempty"`
+	Target      string            `json:"target,omitempty"`
+	Attrs       map[string]string `json:"attrs,omitempty"`
+}
+
+// String renders the entry as one line of text.
+func (e LogEntry) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s %s", e.Timestamp.Format(time.RFC3339), e.Level, e.Message)
+	writeAttr := func(key, value string) {
+		if value == "" {
+			return
+		}
+		if strings.ContainsAny(value, " =\"\n") {
+			value = strconv.Quote(value)
+		}
+		fmt.Fprintf(&b, " %s=%s", key, value)
+	}
+	writeAttr("schedule", e.ScheduleID)
+	writeAttr("execution", e.ExecutionID)
+	writeAttr("target", e.Target)
+	keys := make([]string, 0, len(e.Attrs))
+	for key := range e.Attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		writeAttr(key, e.Attrs[key])
+	}
+	return b.String()
+}
+
+// LogFilter selects an account's log entries. Zero fields do not filter.
+type LogFilter struct {
+	Owner      string
+	MinLevel   slog.Level
+	LevelSet   bool
+	ScheduleID string
+	Target     string
+	From, To   time.Time
+	Text       string // case-insensitive substring of the message or an attribute
+	Before     int64  // cursor: only entries with a smaller ID
+	Limit      int    // 0 for no limit
+}
+
+func (f LogFilter) Matches(e LogEntry) bool {
+	if f.LevelSet {
+		var level slog.Level
+		if level.UnmarshalText([]byte(e.Level)) == nil && level < f.MinLevel {
+			return false
+		}
+	}
+	if f.Text != "" {
+		text := strings.ToLower(f.Text)
+		found := strings.Contains(strings.ToLower(e.Message), text)
+		for _, value := range e.Attrs {
+			found = found || strings.Contains(strings.ToLower(value), text)
+		}
+		if !found {
+			return false
+		}
+	}
+	return e.Owner == f.Owner &&
+		(f.ScheduleID == "" || e.ScheduleID == f.ScheduleID) &&
+		(f.Target == "" || e.Target == f.Target) &&
+		(f.From.IsZero() || !e.Timestamp.Before(f.From)) &&
+		(f.To.IsZero() || e.Timestamp.Before(f.To)) &&
+		(f.Before == 0 || e.ID < f.Before)
+}
+
+// levelsFrom lists the level names at or above min, as stored.
+func levelsFrom(min slog.Level) []string {
+	var levels []string
+	for _, level := range []slog.Level{slog.LevelDebug, slog.LevelInfo, slog.LevelWarn, slog.LevelError} {
+		if level >= min {
+			levels = append(levels, level.String())
+		}
+	}
+	return levels
+}
+
+// PruneOptions are the retention limits of PruneLogs.
+type PruneOptions struct {
+	Before     time.Time // entries older than this go; zero keeps them regardless of age
+	MaxEntries int       // per owner; 0 is unlimited
+	BatchSize  int       // rows deleted per statement by SQL backends
+	// Archive, if set, is given the entries about to be deleted. If it
+	// fails, they are kept and PruneLogs returns its error.
+	Archive func([]LogEntry) error
+}
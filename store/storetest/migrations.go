@@ -0,0 +1,97 @@
+package storetest
+
+import (
+	"context"
+	"testing"
+
+	"scheduler/store"
+)
+
+// RunMigrations applies every migration of a backend to a throwaway
+// database, reverts them all and applies them again, checking what Status
+// reports along the way. open must return a migrator of an empty database,
+// which RunMigrations closes:
+//
+//	func TestSQLiteMigrations(t *testing.T) {
+//		storetest.RunMigrations(t, func(t *testing.T) *store.Migrator {
+//			m, err := store.SQLiteMigrator(filepath.Join(t.TempDir(), "test.db"))
+//			if err != nil {
+//				t.Fatal(err)
+//			}
+//			return m
+//		})
+//	}
+func RunMigrations(t *testing.T, open func(t *testing.T) *store.Migrator) {
+	ctx := context.Background()
+	m := open(t)
+	defer func() {
+		if err := m.Close(); err != nil {
+			t.Errorf("Close: %v", err)
+		}
+	}()
+
+	total := len(m.Migrations())
+	if total == 0 {
+		t.Fatal("no migrations")
+	}
+	for i, migration := range m.Migrations() {
+		if i > 0 && migration.Version <= m.Migrations()[i-1].Version {
+			t.Fatalf("migration %d listed after %d", migration.Version, m.Migrations()[i-1].Version)
+		}
+	}
+	expectApplied(t, m, 0)
+
+	up := func(want int) {
+		t.Helper()
+		n, err := m.Up(ctx)
+		if err != nil {
+			t.Fatalf("Up: %v", err)
+		}
+		if n != want {
+			t.Fatalf("Up applied %d migrations, want %d", n, want)
+		}
+	}
+	down := func(steps, want int) {
+		t.Helper()
+		n, err := m.Down(ctx, steps)
+		if err != nil {
+			t.Fatalf("Down(%d): %v", steps, err)
+		}
+		if n != want {
+			t.Fatalf("Down(%d) reverted %d migrations, want %d", steps, n, want)
+		}
+	}
+
+	up(total)
+	expectApplied(t, m, total)
+	up(0)
+
+	down(1, 1)
+	expectApplied(t, m, total-1)
+	up(1)
+
+	// Every down migration must leave the database as its up migration
+	// found it, or applying them again fails.
+	down(total+1, total)
+	expectApplied(t, m, 0)
+	down(1, 0)
+	up(total)
+	expectApplied(t, m, total)
+}
+
+// expectApplied checks that exactly the first n migrations are applied.
+func expectApplied(t *testing.T, m *store.Migrator, n int) {
+	t.Helper()
+	statuses, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if len(statuses) != len(m.Migrations()) {
+		t.Fatalf("Status lists %d migrations, want %d", len(statuses), len(m.Migrations()))
+	}
+	for i, status := range statuses {
+		if applied := status.AppliedAt != nil; applied != (i < n) {
+			t.Errorf("migration %d_%s applied = %v, want %v", status.Version, status.Name, applied, i < n)
+		}
+	}
+}
@@ -0,0 +1,677 @@
+// Package storetest is the conformance suite every store backend must pass.
+// A backend's test calls Run with a function that opens an empty store:
+//
+//	func TestSQLite(t *testing.T) {
+//		storetest.Run(t, func(t *testing.T) store.Store {
+//			s, err := store.OpenSQLite(filepath.Join(t.TempDir(), "test.db"))
+//			if err != nil {
+//				t.Fatal(err)
+//			}
+//			return s
+//		})
+//	}
+package storetest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"scheduler/store"
+)
+
+// base is a time that every backend stores exactly; Postgres keeps
+// microseconds.
+var base = time.Date(2025, 3, 20, 8, 30, 0, 0, time.UTC)
+
+// Run runs the suite. open is called once per subtest and must return an
+// empty store, which Run closes.
+func Run(t *testing.T, open func(t *testing.T) store.Store) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s store.Store)
+	}{
+		{"Schedules", testSchedules},
+		{"Credentials", testCredentials},
+		{"Accounts", testAccounts},
+		{"Holidays", testHolidays},
+		{"DeadLetters", testDeadLetters},
+		{"Executions", testExecutions},
+		{"Logs", testLogs},
+		{"PruneLogs", testPruneLogs},
+		{"Webhooks", testWebhooks},
+		{"WebhookDeliveries", testWebhookDeliveries},
+		{"TelegramChats", testTelegramChats},
+		{"EmailRecipients", testEmailRecipients},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := open(t)
+			defer func() {
+				if err := s.Close(); err != nil {
+					t.Errorf("Close: %v", err)
+				}
+			}()
+			if err := s.Ping(context.Background()); err != nil {
+				t.Fatalf("Ping: %v", err)
+			}
+			test.fn(t, s)
+		})
+	}
+}
+
+func check(t *testing.T, what string, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%s: %v", what, err)
+	}
+}
+
+func checkNotFound(t *testing.T, what string, err error) {
+	t.Helper()
+	if !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("%s: got error %v, want ErrNotFound", what, err)
+	}
+}
+
+// sameJSON compares JSON documents by value, since backends may reformat
+// them.
+func sameJSON(a, b []byte) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	var va, vb any
+	return json.Unmarshal(a, &va) == nil && json.Unmarshal(b, &vb) == nil && reflect.DeepEqual(va, vb)
+}
+
+func findSchedule(t *testing.T, s store.Store, id string) (store.Schedule, bool) {
+	t.Helper()
+	schedules, err := s.Schedules()
+	check(t, "Schedules", err)
+	for _, sched := range schedules {
+		if sched.ID == id {
+			return sched, true
+		}
+	}
+	return store.Schedule{}, false
+}
+
+func sameSchedule(a, b store.Schedule) bool {
+	rulesMatch := sameJSON(a.CalendarRule, b.CalendarRule) && sameJSON(a.RetryPolicy, b.RetryPolicy)
+	a.CalendarRule, b.CalendarRule, a.RetryPolicy, b.RetryPolicy = nil, nil, nil, nil
+	return rulesMatch && reflect.DeepEqual(a, b)
+}
+
+func testSchedules(t *testing.T, s store.Store) {
+	schedules, err := s.Schedules()
+	check(t, "Schedules", err)
+	if len(schedules) != 0 {
+		t.Fatalf("new store has %d schedules", len(schedules))
+	}
+
+	window := store.Schedule{
+		ID: "0b5c7a8e-3c1f-4d0a-9a57-7f3f3e1c2d01", Owner: "alice", Kind: "window",
+		ServiceName: "api", ServiceType: "app", Action: "start",
+		CronSpec: "0 8 * * *", EndCronSpec: "0 20 * * *", Timezone: "Asia/Tehran",
+		CalendarRule:    []byte(`{"skipHolidays": true, "days": ["sat", "sun"]}`),
+		RetryPolicy:     []byte(`{"maxAttempts": 3}`),
+		Enabled:         true,
+		TokenCiphertext: "v1:secret",
+	}
+	single := store.Schedule{
+		ID: "0b5c7a8e-3c1f-4d0a-9a57-7f3f3e1c2d02", Owner: "bob", Kind: "single",
+		ServiceName: "db", ServiceType: "database", Action: "stop", CronSpec: "@daily",
+	}
+	check(t, "CreateSchedule", s.CreateSchedule(window))
+	check(t, "CreateSchedule", s.CreateSchedule(single))
+
+	for _, want := range []store.Schedule{window, single} {
+		got, ok := findSchedule(t, s, want.ID)
+		if !ok {
+			t.Fatalf("schedule %s not stored", want.ID)
+		}
+		if !sameSchedule(got, want) {
+			t.Errorf("stored schedule:\n got %+v\nwant %+v", got, want)
+		}
+	}
+
+	// Updates change the definition but not the credential.
+	updated := window
+	updated.CronSpec = "0 9 * * *"
+	updated.CalendarRule = nil
+	updated.Enabled = false
+	updated.TokenCiphertext = ""
+	check(t, "UpdateSchedule", s.UpdateSchedule(updated))
+	got, _ := findSchedule(t, s, window.ID)
+	updated.TokenCiphertext = window.TokenCiphertext
+	if !sameSchedule(got, updated) {
+		t.Errorf("updated schedule:\n got %+v\nwant %+v", got, updated)
+	}
+
+	stolen := single
+	stolen.Owner = "alice"
+	checkNotFound(t, "UpdateSchedule of another owner's schedule", s.UpdateSchedule(stolen))
+	checkNotFound(t, "DeleteSchedule of another owner's schedule", s.DeleteSchedule("alice", single.ID))
+	if _, ok := findSchedule(t, s, single.ID); !ok {
+		t.Error("another owner deleted a schedule")
+	}
+
+	check(t, "DeleteSchedule", s.DeleteSchedule("bob", single.ID))
+	if _, ok := findSchedule(t, s, single.ID); ok {
+		t.Error("deleted schedule still stored")
+	}
+	checkNotFound(t, "DeleteSchedule of a deleted schedule", s.DeleteSchedule("bob", single.ID))
+}
+
+func testCredentials(t *testing.T, s store.Store) {
+	check(t, "CreateSchedule", s.CreateSchedule(store.Schedule{ID: "1d7e0b64-4f0c-4b43-8f4e-5a4a7a0f1e01", Owner: "alice", Kind: "single",
+		ServiceName: "api", ServiceType: "app", Action: "start", CronSpec: "@daily", Enabled: true, TokenCiphertext: "v1:old"}))
+	check(t, "CreateSchedule", s.CreateSchedule(store.Schedule{ID: "1d7e0b64-4f0c-4b43-8f4e-5a4a7a0f1e02", Owner: "alice", Kind: "single",
+		ServiceName: "web", ServiceType: "app", Action: "stop", CronSpec: "@daily", Enabled: true}))
+
+	credentials, err := s.Credentials()
+	check(t, "Credentials", err)
+	want := []store.Credential{{ScheduleID: "1d7e0b64-4f0c-4b43-8f4e-5a4a7a0f1e01", Owner: "alice", Ciphertext: "v1:old"}}
+	if !reflect.DeepEqual(credentials, want) {
+		t.Fatalf("Credentials = %+v, want %+v", credentials, want)
+	}
+
+	want[0].Ciphertext = "v2:new"
+	check(t, "UpdateCredentials", s.UpdateCredentials(want))
+	credentials, err = s.Credentials()
+	check(t, "Credentials", err)
+	if !reflect.DeepEqual(credentials, want) {
+		t.Errorf("after UpdateCredentials, Credentials = %+v, want %+v", credentials, want)
+	}
+}
+
+func testAccounts(t *testing.T, s store.Store) {
+	paused := func() []string {
+		t.Helper()
+		owners, err := s.PausedAccounts()
+		check(t, "PausedAccounts", err)
+		sort.Strings(owners)
+		return owners
+	}
+	if owners := paused(); len(owners) != 0 {
+		t.Fatalf("new store has paused accounts %v", owners)
+	}
+
+	check(t, "SetAccountPaused", s.SetAccountPaused("alice", true))
+	check(t, "SetAccountPaused", s.SetAccountPaused("bob", true))
+	check(t, "SetAccountPaused", s.SetAccountPaused("bob", true))
+	if owners := paused(); !reflect.DeepEqual(owners, []string{"alice", "bob"}) {
+		t.Errorf("PausedAccounts = %v, want [alice bob]", owners)
+	}
+	check(t, "SetAccountPaused", s.SetAccountPaused("alice", false))
+	check(t, "SetAccountPaused", s.SetAccountPaused("carol", false))
+	if owners := paused(); !reflect.DeepEqual(owners, []string{"bob"}) {
+		t.Errorf("PausedAccounts = %v, want [bob]", owners)
+	}
+}
+
+func testHolidays(t *testing.T, s store.Store) {
+	holidays := func() []store.Holiday {
+		t.Helper()
+		all, err := s.Holidays()
+		check(t, "Holidays", err)
+		sort.Slice(all, func(i, j int) bool {
+			return all[i].Owner+all[i].Date < all[j].Owner+all[j].Date
+		})
+		return all
+	}
+
+	check(t, "ReplaceHolidays", s.ReplaceHolidays("alice", []store.Holiday{{Date: "1404-01-01", Name: "Nowruz"}, {Date: "1404-01-13", Name: "Sizdah Bedar"}}))
+	check(t, "ReplaceHolidays", s.ReplaceHolidays("bob", []store.Holiday{{Date: "1404-01-01", Name: "نوروز"}}))
+	check(t, "ReplaceHolidays", s.ReplaceHolidays("alice", []store.Holiday{{Date: "1404-01-02", Name: "Nowruz"}, {Date: "1404-01-02", Name: "Nowruz 2"}}))
+
+	want := []store.Holiday{
+		{Owner: "alice", Date: "1404-01-02", Name: "Nowruz 2"},
+		{Owner: "bob", Date: "1404-01-01", Name: "نوروز"},
+	}
+	if got := holidays(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Holidays = %+v, want %+v", got, want)
+	}
+
+	check(t, "ReplaceHolidays", s.ReplaceHolidays("alice", nil))
+	if got := holidays(); !reflect.DeepEqual(got, want[1:]) {
+		t.Errorf("after clearing alice's holidays, Holidays = %+v, want %+v", got, want[1:])
+	}
+}
+
+func testDeadLetters(t *testing.T, s store.Store) {
+	newer := store.DeadLetter{ID: "5f0e4c1a-9d1b-4a7e-8c3b-2e6f7a8b9c01", Owner: "alice", ScheduleID: "1d7e0b64-4f0c-4b43-8f4e-5a4a7a0f1e01",
+		ServiceType: "app", ServiceName: "api", Action: "start", Attempts: 3, StatusCode: 502, Error: "bad gateway", FailedAt: base.Add(time.Hour)}
+	older := store.DeadLetter{ID: "5f0e4c1a-9d1b-4a7e-8c3b-2e6f7a8b9c02", Owner: "bob",
+		ServiceType: "database", ServiceName: "db", Action: "stop", Attempts: 1, Error: "connection refused", FailedAt: base}
+	check(t, "AddDeadLetter", s.AddDeadLetter(newer))
+	check(t, "AddDeadLetter", s.AddDeadLetter(older))
+
+	letters, err := s.DeadLetters()
+	check(t, "DeadLetters", err)
+	if len(letters) != 2 || !sameDeadLetter(letters[0], older) || !sameDeadLetter(letters[1], newer) {
+		t.Fatalf("DeadLetters = %+v, want %+v oldest first", letters, []store.DeadLetter{older, newer})
+	}
+
+	checkNotFound(t, "MarkDeadLetterReplayed of another owner's letter", s.MarkDeadLetterReplayed("bob", newer.ID, base))
+	replayedAt := base.Add(2 * time.Hour)
+	check(t, "MarkDeadLetterReplayed", s.MarkDeadLetterReplayed("alice", newer.ID, replayedAt))
+	newer.ReplayedAt = &replayedAt
+	if err := s.MarkDeadLetterReplayed("alice", newer.ID, replayedAt.Add(time.Minute)); !errors.Is(err, store.ErrAlreadyReplayed) {
+		t.Errorf("MarkDeadLetterReplayed of a replayed letter: error %v, want ErrAlreadyReplayed", err)
+	}
+
+	checkNotFound(t, "DeleteDeadLetter of another owner's letter", s.DeleteDeadLetter("alice", older.ID))
+	check(t, "DeleteDeadLetter", s.DeleteDeadLetter("bob", older.ID))
+	checkNotFound(t, "DeleteDeadLetter of a deleted letter", s.DeleteDeadLetter("bob", older.ID))
+
+	letters, err = s.DeadLetters()
+	check(t, "DeadLetters", err)
+	if len(letters) != 1 || !sameDeadLetter(letters[0], newer) {
+		t.Errorf("DeadLetters = %+v, want [%+v]", letters, newer)
+	}
+}
+
+func sameDeadLetter(a, b store.DeadLetter) bool {
+	if (a.ReplayedAt == nil) != (b.ReplayedAt == nil) || a.ReplayedAt != nil && !a.ReplayedAt.Equal(*b.ReplayedAt) {
+		return false
+	}
+	if !a.FailedAt.Equal(b.FailedAt) {
+		return false
+	}
+	a.FailedAt, b.FailedAt, a.ReplayedAt, b.ReplayedAt = time.Time{}, time.Time{}, nil, nil
+	return a == b
+}
+
+func testWebhooks(t *testing.T, s store.Store) {
+	first := store.Webhook{ID: "3c9a7e10-2b4d-4f6a-9c8e-1a2b3c4d5e01", Owner: "alice", URL: "https://example.com/hook",
+		Secret: "s3cret", CreatedAt: base}
+	second := store.Webhook{ID: "3c9a7e10-2b4d-4f6a-9c8e-1a2b3c4d5e02", Owner: "alice", URL: "https://example.com/failures",
+		Events: []string{"scale-failed", "retries-exhausted"}, Secret: "other", CreatedAt: base.Add(time.Minute)}
+	other := store.Webhook{ID: "3c9a7e10-2b4d-4f6a-9c8e-1a2b3c4d5e03", Owner: "bob", URL: "https://example.org/", Secret: "x", CreatedAt: base}
+	for _, w := range []store.Webhook{second, first, other} {
+		check(t, "CreateWebhook", s.CreateWebhook(w))
+	}
+
+	webhooks, err := s.Webhooks("alice")
+	check(t, "Webhooks", err)
+	if len(webhooks) != 2 || !sameWebhook(webhooks[0], first) || !sameWebhook(webhooks[1], second) {
+		t.Fatalf("Webhooks = %+v, want %+v oldest first", webhooks, []store.Webhook{first, second})
+	}
+
+	checkNotFound(t, "DeleteWebhook of another owner's webhook", s.DeleteWebhook("bob", first.ID))
+	check(t, "DeleteWebhook", s.DeleteWebhook("alice", first.ID))
+	checkNotFound(t, "DeleteWebhook of a deleted webhook", s.DeleteWebhook("alice", first.ID))
+
+	webhooks, err = s.Webhooks("alice")
+	check(t, "Webhooks", err)
+	if len(webhooks) != 1 || !sameWebhook(webhooks[0], second) {
+		t.Errorf("Webhooks = %+v, want [%+v]", webhooks, second)
+	}
+}
+
+func sameWebhook(a, b store.Webhook) bool {
+	return a.ID == b.ID && a.Owner == b.Owner && a.URL == b.URL && a.Secret == b.Secret &&
+		reflect.DeepEqual(a.Events, b.Events) && a.CreatedAt.Equal(b.CreatedAt)
+}
+
+func testWebhookDeliveries(t *testing.T, s store.Store) {
+	webhook := store.Webhook{ID: "3c9a7e10-2b4d-4f6a-9c8e-1a2b3c4d5e11", Owner: "alice", URL: "https://example.com/hook", Secret: "s", CreatedAt: base}
+	check(t, "CreateWebhook", s.CreateWebhook(webhook))
+
+	delivery := func(i int) store.WebhookDelivery {
+		return store.WebhookDelivery{ID: fmt.Sprintf("3c9a7e10-2b4d-4f6a-9c8e-1a2b3c4d%04d", i), WebhookID: webhook.ID, Owner: "alice",
+			Event: "scale-succeeded", Payload: json.RawMessage(`{"n":` + fmt.Sprint(i) + `}`), Status: "pending",
+			CreatedAt: base.Add(time.Duration(i) * time.Second)}
+	}
+
+	first := delivery(0)
+	check(t, "SaveWebhookDelivery", s.SaveWebhookDelivery(first))
+	completedAt := base.Add(time.Minute)
+	first.Status, first.Attempts, first.StatusCode, first.Error, first.CompletedAt = "failed", 3, 500, "internal error", &completedAt
+	check(t, "SaveWebhookDelivery of an existing delivery", s.SaveWebhookDelivery(first))
+
+	deliveries, err := s.WebhookDeliveries("alice", webhook.ID, 10)
+	check(t, "WebhookDeliveries", err)
+	if len(deliveries) != 1 || !sameDelivery(deliveries[0], first) {
+		t.Fatalf("WebhookDeliveries = %+v, want [%+v]", deliveries, first)
+	}
+	deliveries, err = s.WebhookDeliveries("bob", webhook.ID, 10)
+	check(t, "WebhookDeliveries", err)
+	if len(deliveries) != 0 {
+		t.Errorf("WebhookDeliveries of another owner = %+v, want none", deliveries)
+	}
+
+	// Only the latest deliveries are kept.
+	for i := 1; i <= store.WebhookDeliveriesKept; i++ {
+		check(t, "SaveWebhookDelivery", s.SaveWebhookDelivery(delivery(i)))
+	}
+	deliveries, err = s.WebhookDeliveries("alice", webhook.ID, store.WebhookDeliveriesKept+10)
+	check(t, "WebhookDeliveries", err)
+	if len(deliveries) != store.WebhookDeliveriesKept {
+		t.Fatalf("WebhookDeliveries returned %d deliveries, want %d", len(deliveries), store.WebhookDeliveriesKept)
+	}
+	if newest, oldest := deliveries[0], deliveries[len(deliveries)-1]; !sameDelivery(newest, delivery(store.WebhookDeliveriesKept)) || !sameDelivery(oldest, delivery(1)) {
+		t.Errorf("WebhookDeliveries runs from %s to %s, want newest first without the first delivery", newest.ID, oldest.ID)
+	}
+	deliveries, err = s.WebhookDeliveries("alice", webhook.ID, 5)
+	check(t, "WebhookDeliveries", err)
+	if len(deliveries) != 5 {
+		t.Errorf("WebhookDeliveries with limit 5 returned %d deliveries", len(deliveries))
+	}
+
+	// Deleting the webhook deletes its deliveries.
+	check(t, "DeleteWebhook", s.DeleteWebhook("alice", webhook.ID))
+	deliveries, err = s.WebhookDeliveries("alice", webhook.ID, 10)
+	check(t, "WebhookDeliveries", err)
+	if len(deliveries) != 0 {
+		t.Errorf("WebhookDeliveries of a deleted webhook = %d deliveries, want none", len(deliveries))
+	}
+}
+
+func sameDelivery(a, b store.WebhookDelivery) bool {
+	if (a.CompletedAt == nil) != (b.CompletedAt == nil) || a.CompletedAt != nil && !a.CompletedAt.Equal(*b.CompletedAt) {
+		return false
+	}
+	if !a.CreatedAt.Equal(b.CreatedAt) || string(a.Payload) != string(b.Payload) {
+		return false
+	}
+	a.CreatedAt, b.CreatedAt, a.CompletedAt, b.CompletedAt, a.Payload, b.Payload = time.Time{}, time.Time{}, nil, nil, nil, nil
+	return reflect.DeepEqual(a, b)
+}
+
+func testExecutions(t *testing.T, s store.Store) {
+	const scheduleID = "7a1c2b3d-4e5f-4a6b-8c7d-9e0f1a2b3c01"
+	var all []store.Execution
+	for i := range 5 {
+		e := store.Execution{
+			ID:         fmt.Sprintf("7a1c2b3d-4e5f-4a6b-8c7d-9e0f1a2b3d%02d", i),
+			ScheduleID: scheduleID, Owner: "alice",
+			ServiceType: "app", ServiceName: "api", Action: "start",
+			PlannedAt: base.Add(time.Duration(i) * time.Hour), StartedAt: base.Add(time.Duration(i)*time.Hour + time.Second),
+			FinishedAt: base.Add(time.Duration(i)*time.Hour + 2*time.Second),
+			StatusCode: 200, ResponseBody: `{"ok":true}`, Attempts: 1, Outcome: "success",
+		}
+		if i == 3 {
+			e.StatusCode, e.Outcome, e.Error = 0, "failed", "timeout"
+		}
+		all = append(all, e)
+		check(t, "AddExecution", s.AddExecution(e))
+	}
+	// Neither another owner's nor another schedule's executions match.
+	other := all[0]
+	other.ID, other.Owner = "7a1c2b3d-4e5f-4a6b-8c7d-9e0f1a2b3e01", "bob"
+	check(t, "AddExecution", s.AddExecution(other))
+	other.ID, other.Owner, other.ScheduleID = "7a1c2b3d-4e5f-4a6b-8c7d-9e0f1a2b3e02", "alice", "7a1c2b3d-4e5f-4a6b-8c7d-9e0f1a2b3c02"
+	check(t, "AddExecution", s.AddExecution(other))
+
+	tests := []struct {
+		filter store.ExecutionFilter
+		want   []int // indexes into all, in the expected order
+		total  int
+	}{
+		{store.ExecutionFilter{Owner: "alice", ScheduleID: scheduleID, Limit: 10}, []int{4, 3, 2, 1, 0}, 5},
+		{store.ExecutionFilter{Owner: "alice", ScheduleID: scheduleID, Limit: 2, Offset: 1}, []int{3, 2}, 5},
+		{store.ExecutionFilter{Owner: "alice", ScheduleID: scheduleID, Limit: 10, Offset: 5}, nil, 5},
+		{store.ExecutionFilter{Owner: "alice", ScheduleID: scheduleID, From: base.Add(time.Hour), To: base.Add(3 * time.Hour), Limit: 10}, []int{2, 1}, 2},
+		{store.ExecutionFilter{Owner: "bob", ScheduleID: scheduleID, From: base.Add(time.Hour), Limit: 10}, nil, 0},
+	}
+	for _, test := range tests {
+		got, total, err := s.Executions(test.filter)
+		check(t, "Executions", err)
+		if total != test.total || len(got) != len(test.want) {
+			t.Errorf("Executions(%+v) returned %d of %d, want %d of %d", test.filter, len(got), total, len(test.want), test.total)
+			continue
+		}
+		for i, index := range test.want {
+			if !sameExecution(got[i], all[index]) {
+				t.Errorf("Executions(%+v)[%d] = %+v, want %+v", test.filter, i, got[i], all[index])
+			}
+		}
+	}
+}
+
+func sameExecution(a, b store.Execution) bool {
+	if !a.PlannedAt.Equal(b.PlannedAt) || !a.StartedAt.Equal(b.StartedAt) || !a.FinishedAt.Equal(b.FinishedAt) {
+		return false
+	}
+	a.PlannedAt, a.StartedAt, a.FinishedAt = time.Time{}, time.Time{}, time.Time{}
+	b.PlannedAt, b.StartedAt, b.FinishedAt = time.Time{}, time.Time{}, time.Time{}
+	return a == b
+}
+
+// appendLogs stores one entry per message for owner, a minute apart from
+// start, and returns them with their IDs.
+func appendLogs(t *testing.T, s store.Store, owner string, start time.Time, entries ...store.LogEntry) []store.LogEntry {
+	t.Helper()
+	for i := range entries {
+		entries[i].Owner = owner
+		entries[i].Timestamp = start.Add(time.Duration(i) * time.Minute)
+		if entries[i].Level == "" {
+			entries[i].Level = "INFO"
+		}
+	}
+	check(t, "AppendLogs", s.AppendLogs(entries))
+	return entries
+}
+
+func logMessages(entries []store.LogEntry) []string {
+	messages := make([]string, len(entries))
+	for i, e := range entries {
+		messages[i] = e.Message
+	}
+	return messages
+}
+
+func testLogs(t *testing.T, s store.Store) {
+	alice := appendLogs(t, s, "alice", base,
+		store.LogEntry{Message: "Scaled api to 1", ScheduleID: "s1", ExecutionID: "e1", Target: "app/api", Attrs: map[string]string{"status": "200"}},
+		store.LogEntry{Level: "ERROR", Message: "Failed to scale db", ScheduleID: "s2", Target: "database/db", Attrs: map[string]string{"error": "Connection 100% refused"}},
+		store.LogEntry{Level: "WARN", Message: "Retrying api_v2", ScheduleID: "s1", Target: "app/api"},
+		store.LogEntry{Level: "DEBUG", Message: "Next run computed"},
+	)
+	appendLogs(t, s, "bob", base, store.LogEntry{Message: "Scaled api to 1"})
+
+	for i := 1; i < len(alice); i++ {
+		if alice[i].ID <= alice[i-1].ID {
+			t.Fatalf("IDs do not increase: %d after %d", alice[i].ID, alice[i-1].ID)
+		}
+	}
+
+	got, err := s.Logs(store.LogFilter{Owner: "alice"}, false)
+	check(t, "Logs", err)
+	if len(got) != len(alice) {
+		t.Fatalf("Logs returned %d entries, want %d", len(got), len(alice))
+	}
+	for i := range got {
+		want := alice[i]
+		if !got[i].Timestamp.Equal(want.Timestamp) {
+			t.Errorf("entry %d has timestamp %s, want %s", i, got[i].Timestamp, want.Timestamp)
+		}
+		got[i].Timestamp, want.Timestamp = time.Time{}, time.Time{}
+		if !reflect.DeepEqual(got[i], want) {
+			t.Errorf("entry %d:\n got %+v\nwant %+v", i, got[i], want)
+		}
+	}
+
+	tests := []struct {
+		filter      store.LogFilter
+		newestFirst bool
+		want        []string
+	}{
+		{store.LogFilter{Owner: "alice", Limit: 2}, true, []string{"Next run computed", "Retrying api_v2"}},
+		{store.LogFilter{Owner: "alice", Limit: 2}, false, []string{"Scaled api to 1", "Failed to scale db"}},
+		{store.LogFilter{Owner: "alice", Before: alice[2].ID}, true, []string{"Failed to scale db", "Scaled api to 1"}},
+		{store.LogFilter{Owner: "alice", MinLevel: slog.LevelWarn, LevelSet: true}, false, []string{"Failed to scale db", "Retrying api_v2"}},
+		{store.LogFilter{Owner: "alice", MinLevel: slog.LevelDebug, LevelSet: true}, false, logMessages(alice)},
+		{store.LogFilter{Owner: "alice", ScheduleID: "s1"}, false, []string{"Scaled api to 1", "Retrying api_v2"}},
+		{store.LogFilter{Owner: "alice", Target: "database/db"}, false, []string{"Failed to scale db"}},
+		{store.LogFilter{Owner: "alice", From: base.Add(time.Minute), To: base.Add(3 * time.Minute)}, false, []string{"Failed to scale db", "Retrying api_v2"}},
+		{store.LogFilter{Owner: "alice", Text: "SCALE"}, false, []string{"Scaled api to 1", "Failed to scale db"}},
+		{store.LogFilter{Owner: "alice", Text: "refused"}, false, []string{"Failed to scale db"}}, // in an attribute
+		{store.LogFilter{Owner: "alice", Text: "100%"}, false, []string{"Failed to scale db"}},
+		{store.LogFilter{Owner: "alice", Text: "i_v"}, false, []string{"Retrying api_v2"}}, // _ is no wildcard
+		{store.LogFilter{Owner: "carol"}, false, []string{}},
+	}
+	for _, test := range tests {
+		got, err := s.Logs(test.filter, test.newestFirst)
+		check(t, "Logs", err)
+		if messages := logMessages(got); !reflect.DeepEqual(messages, test.want) {
+			t.Errorf("Logs(%+v, newestFirst=%t) = %q, want %q", test.filter, test.newestFirst, messages, test.want)
+		}
+	}
+}
+
+func testPruneLogs(t *testing.T, s store.Store) {
+	var messages []store.LogEntry
+	for i := range 6 {
+		messages = append(messages, store.LogEntry{Message: fmt.Sprintf("alice %d", i)})
+	}
+	appendLogs(t, s, "alice", base, messages...)
+	appendLogs(t, s, "bob", base.Add(time.Hour), store.LogEntry{Message: "bob 0"}, store.LogEntry{Message: "bob 1"})
+
+	remaining := func(owner string) []string {
+		t.Helper()
+		entries, err := s.Logs(store.LogFilter{Owner: owner}, false)
+		check(t, "Logs", err)
+		return logMessages(entries)
+	}
+
+	// A failing archive keeps everything.
+	_, err := s.PruneLogs(store.PruneOptions{Before: base.Add(2 * time.Minute), Archive: func([]store.LogEntry) error {
+		return errors.New("disk full")
+	}})
+	if err == nil {
+		t.Error("PruneLogs succeeded although the archive failed")
+	}
+	if got := remaining("alice"); len(got) != 6 {
+		t.Fatalf("after a failed prune %d entries remain, want 6", len(got))
+	}
+
+	var archived []string
+	archive := func(entries []store.LogEntry) error {
+		for _, e := range entries {
+			if e.Owner == "" {
+				t.Errorf("archived entry %q has no owner", e.Message)
+			}
+			archived = append(archived, e.Message)
+		}
+		return nil
+	}
+
+	n, err := s.PruneLogs(store.PruneOptions{Before: base.Add(2 * time.Minute), BatchSize: 1, Archive: archive})
+	check(t, "PruneLogs", err)
+	if n != 2 || !reflect.DeepEqual(archived, []string{"alice 0", "alice 1"}) {
+		t.Errorf("pruning by age removed %d entries and archived %q, want 2 and [alice 0 alice 1]", n, archived)
+	}
+
+	archived = nil
+	n, err = s.PruneLogs(store.PruneOptions{MaxEntries: 3, BatchSize: 10, Archive: archive})
+	check(t, "PruneLogs", err)
+	if n != 1 || !reflect.DeepEqual(archived, []string{"alice 2"}) {
+		t.Errorf("pruning by size removed %d entries and archived %q, want 1 and [alice 2]", n, archived)
+	}
+
+	if got, want := remaining("alice"), []string{"alice 3", "alice 4", "alice 5"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("alice's remaining logs = %q, want %q", got, want)
+	}
+	if got, want := remaining("bob"), []string{"bob 0", "bob 1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bob's remaining logs = %q, want %q", got, want)
+	}
+}
+
+func testTelegramChats(t *testing.T, s store.Store) {
+	group := store.TelegramChat{ChatID: -1001234567890, Owner: "alice", Title: "Ops", TokenCiphertext: "k1:abc", LinkedAt: base.Add(time.Minute)}
+	private := store.TelegramChat{ChatID: 42, Owner: "alice", Title: "@alice", LinkedAt: base}
+	other := store.TelegramChat{ChatID: 43, Owner: "bob", Title: "@bob", TokenCiphertext: "k1:def", LinkedAt: base.Add(2 * time.Minute)}
+	for _, c := range []store.TelegramChat{group, private, other} {
+		check(t, "LinkTelegramChat", s.LinkTelegramChat(c))
+	}
+
+	chats, err := s.TelegramChats()
+	check(t, "TelegramChats", err)
+	if len(chats) != 3 || !sameTelegramChat(chats[0], private) || !sameTelegramChat(chats[1], group) || !sameTelegramChat(chats[2], other) {
+		t.Fatalf("TelegramChats = %+v, want %+v oldest first", chats, []store.TelegramChat{private, group, other})
+	}
+
+	chat, err := s.TelegramChat(group.ChatID)
+	check(t, "TelegramChat", err)
+	if !sameTelegramChat(chat, group) {
+		t.Errorf("TelegramChat = %+v, want %+v", chat, group)
+	}
+	_, err = s.TelegramChat(44)
+	checkNotFound(t, "TelegramChat of an unknown chat", err)
+
+	// Linking a chat again replaces its link.
+	group.TokenCiphertext, group.Title = "k2:ghi", "Operations"
+	check(t, "LinkTelegramChat of a linked chat", s.LinkTelegramChat(group))
+	chat, err = s.TelegramChat(group.ChatID)
+	check(t, "TelegramChat", err)
+	if !sameTelegramChat(chat, group) {
+		t.Errorf("TelegramChat after relinking = %+v, want %+v", chat, group)
+	}
+
+	checkNotFound(t, "UnlinkTelegramChat of another owner's chat", s.UnlinkTelegramChat("bob", private.ChatID))
+	check(t, "UnlinkTelegramChat", s.UnlinkTelegramChat("alice", private.ChatID))
+	checkNotFound(t, "UnlinkTelegramChat of an unlinked chat", s.UnlinkTelegramChat("alice", private.ChatID))
+
+	chats, err = s.TelegramChats()
+	check(t, "TelegramChats", err)
+	if len(chats) != 2 || !sameTelegramChat(chats[0], group) || !sameTelegramChat(chats[1], other) {
+		t.Errorf("TelegramChats = %+v, want %+v", chats, []store.TelegramChat{group, other})
+	}
+}
+
+func sameTelegramChat(a, b store.TelegramChat) bool {
+	if !a.LinkedAt.Equal(b.LinkedAt) {
+		return false
+	}
+	a.LinkedAt, b.LinkedAt = time.Time{}, time.Time{}
+	return a == b
+}
+
+func testEmailRecipients(t *testing.T, s store.Store) {
+	ops := store.EmailRecipient{ID: "5e1d2c3b-4a59-4867-9f0e-1d2c3b4a5901", Owner: "alice", Address: "ops@example.com",
+		Failures: true, TokenCiphertext: "k1:abc", CreatedAt: base.Add(time.Minute)}
+	finance := store.EmailRecipient{ID: "5e1d2c3b-4a59-4867-9f0e-1d2c3b4a5902", Owner: "alice", Address: "finance@example.com",
+		Digest: "weekly", CreatedAt: base}
+	other := store.EmailRecipient{ID: "5e1d2c3b-4a59-4867-9f0e-1d2c3b4a5903", Owner: "bob", Address: "bob@example.org",
+		Failures: true, Digest: "daily", CreatedAt: base.Add(2 * time.Minute)}
+	for _, r := range []store.EmailRecipient{ops, finance, other} {
+		check(t, "SaveEmailRecipient", s.SaveEmailRecipient(r))
+	}
+
+	recipients, err := s.EmailRecipients()
+	check(t, "EmailRecipients", err)
+	if len(recipients) != 3 || !sameEmailRecipient(recipients[0], finance) || !sameEmailRecipient(recipients[1], ops) || !sameEmailRecipient(recipients[2], other) {
+		t.Fatalf("EmailRecipients = %+v, want %+v oldest first", recipients, []store.EmailRecipient{finance, ops, other})
+	}
+
+	// Saving a recipient again replaces it.
+	finance.TokenCiphertext, finance.Failures = "k2:ghi", true
+	check(t, "SaveEmailRecipient of a stored recipient", s.SaveEmailRecipient(finance))
+
+	checkNotFound(t, "DeleteEmailRecipient of another owner's recipient", s.DeleteEmailRecipient("bob", ops.ID))
+	check(t, "DeleteEmailRecipient", s.DeleteEmailRecipient("alice", ops.ID))
+	checkNotFound(t, "DeleteEmailRecipient of a deleted recipient", s.DeleteEmailRecipient("alice", ops.ID))
+
+	recipients, err = s.EmailRecipients()
+	check(t, "EmailRecipients", err)
+	if len(recipients) != 2 || !sameEmailRecipient(recipients[0], finance) || !sameEmailRecipient(recipients[1], other) {
+		t.Errorf("EmailRecipients = %+v, want %+v", recipients, []store.EmailRecipient{finance, other})
+	}
+}
+
+func sameEmailRecipient(a, b store.EmailRecipient) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return false
+	}
+	a.CreatedAt, b.CreatedAt = time.Time{}, time.Time{}
+	return a == b
+}
@@ -0,0 +1,649 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"scheduler/events"
+	"scheduler/retry"
+	"scheduler/store"
+	"scheduler/telegram"
+)
+
+const (
+	defaultTelegramSummaryCron = "0 9 * * *"
+	maxTelegramChatsPerAccount = 5
+	// telegramPollTimeout is how long each request for commands waits for
+	// one to arrive.
+	telegramPollTimeout = 30 * time.Second
+	// telegramErrorDelay is the pause after a failed request for commands.
+	telegramErrorDelay = 5 * time.Second
+	// telegramQueueSize is how many failures may wait to be sent before
+	// newer ones are dropped.
+	telegramQueueSize = 64
+	// telegramSendAttempts bounds the attempts of a message Telegram asks
+	// to slow down for.
+	telegramSendAttempts  = 3
+	maxTelegramRetryAfter = time.Minute
+	// telegramLinkCodePeriod is how often link codes change; a code is
+	// accepted during its own period and the next.
+	telegramLinkCodePeriod = time.Hour
+)
+
+const telegramHelp = `Commands:
+/status - whether the account is paused, its schedules and the state of its projects
+/schedules - the account's schedules and their next runs
+/on <project> - turn a project on now
+/off <project> - turn a project off now
+/help - this list`
+
+// TelegramChat is a Telegram chat linked to an account.
+type TelegramChat = store.TelegramChat
+
+var (
+	// telegramBot is nil unless TELEGRAM_BOT_TOKEN is set.
+	telegramBot *telegram.Client
+	// telegramLinkKey signs the codes that prove a chat is linked from
+	// inside it. It is derived from the bot token, which every replica
+	// shares, so any replica can check a code the leader's bot sent.
+	telegramLinkKey []byte
+	// telegramBotName is the bot's user name, once known, so commands
+	// addressed to other bots in a group are ignored. Only the poll loop
+	// uses it.
+	telegramBotName string
+	// telegramTokens keeps the tokens of chats linked on this replica, for
+	// when they are not stored.
+	telegramTokens   = make(map[int64]string)
+	telegramTokensMu sync.Mutex
+)
+
+// newTelegramBot builds the bot client from TELEGRAM_BOT_TOKEN and, to
+// talk to another Bot API server, TELEGRAM_API_BASE. It returns nil when no
+// token is set.
+func newTelegramBot() *telegram.Client {
+	token := os.Getenv("TELEGRAM_BOT_TOKEN")
+	if token == "" {
+		return nil
+	}
+	var opts []telegram.Option
+	if baseURL := os.Getenv("TELEGRAM_API_BASE"); baseURL != "" {
+		opts = append(opts, telegram.WithBaseURL(baseURL))
+	}
+	key := sha256.Sum256([]byte("telegram-link:" + token))
+	telegramLinkKey = key[:]
+	return telegram.NewClient(token, opts...)
+}
+
+// telegramLinkCode returns the code that links chatID during the period
+// containing at. The bot sends it only to the chat itself, so knowing it
+// shows that whoever links the chat can read it.
+func telegramLinkCode(chatID int64, at time.Time) string {
+	var message [16]byte
+	binary.BigEndian.PutUint64(message[:8], uint64(chatID))
+	binary.BigEndian.PutUint64(message[8:], uint64(at.Unix()/int64(telegramLinkCodePeriod/time.Second)))
+	mac := hmac.New(sha256.New, telegramLinkKey)
+	mac.Write(message[:])
+	return base32.StdEncoding.EncodeToString(mac.Sum(nil))[:10]
+}
+
+// validTelegramLinkCode reports whether code links chatID at now.
+func validTelegramLinkCode(chatID int64, code string, now time.Time) bool {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	for _, at := range []time.Time{now, now.Add(-telegramLinkCodePeriod)} {
+		if hmac.Equal([]byte(code), []byte(telegramLinkCode(chatID, at))) {
+			return true
+		}
+	}
+	return false
+}
+
+// startTelegramBot sends failed executions to the linked chats of their
+// account, adds the daily summary to the scheduler and answers commands. Like
+// the schedules, summaries and commands are handled by the leader only;
+// failures are sent by the replica that ran the execution.
+func startTelegramBot(summarySpec string) {
+	if telegramBot == nil {
+		return
+	}
+
+	if summarySpec != "" {
+		if _, err := scheduler.AddFunc(summarySpec, func() { sendTelegramSummaries(time.Now()) }); err != nil {
+			log.Printf("Error scheduling Telegram summaries: %v", err)
+		}
+	}
+
+	sub := eventBus.Subscribe(telegramQueueSize, func(e events.Event) bool {
+		execution, ok := e.Data.(Execution)
+		return e.Type == EventExecutionFinished && ok && execution.Outcome == ExecutionFailed
+	})
+	go func() {
+		<-shuttingDown
+		sub.Close()
+	}()
+	go func() {
+		for e := range sub.C {
+			notifyTelegramChats(e.Account, failureMessage(e.Data.(Execution)))
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-shuttingDown
+		cancel()
+	}()
+	// Commands may scale projects, which shutdown waits for.
+	backgroundJobs.Add(1)
+	go func() {
+		defer backgroundJobs.Done()
+		pollTelegram(ctx)
+	}()
+	log.Println("Telegram bot enabled.")
+}
+
+// pollTelegram receives and answers commands while this replica leads,
+// until ctx is done.
+func pollTelegram(ctx context.Context) {
+	var offset int64
+	polling := false
+	for {
+		if !isLeader.Load() {
+			if polling {
+				confirmTelegramUpdates(offset)
+				polling = false
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(telegramErrorDelay):
+			}
+			continue
+		}
+		polling = true
+
+		if telegramBotName == "" {
+			if me, err := telegramBot.GetMe(ctx); err == nil {
+				telegramBotName = me.Username
+			}
+		}
+		updates, err := telegramBot.GetUpdates(ctx, offset, telegramPollTimeout)
+		if ctx.Err() != nil {
+			confirmTelegramUpdates(offset)
+			return
+		}
+		if err != nil {
+			log.Printf("Error receiving Telegram commands: %v", err)
+			select {
+			case <-ctx.Done():
+				confirmTelegramUpdates(offset)
+				return
+			case <-time.After(telegramErrorDelay):
+			}
+			continue
+		}
+		for _, u := range updates {
+			offset = u.UpdateID + 1
+			if u.Message != nil {
+				handleTelegramMessage(u.Message)
+			}
+		}
+	}
+}
+
+// confirmTelegramUpdates tells Telegram that the updates before offset were
+// handled, so that the next replica to poll does not run them again.
+func confirmTelegramUpdates(offset int64) {
+	if offset == 0 {
+		return
+	}
+	if _, err := telegramBot.GetUpdates(context.Background(), offset, 0); err != nil {
+		log.Printf("Error confirming Telegram commands: %v", err)
+	}
+}
+
+// parseTelegramCommand splits "/command@bot args" into the command and its
+// arguments. It returns "" for messages that are not commands or are
+// addressed to another bot.
+func parseTelegramCommand(text string) (string, []string) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "/") {
+		return "", nil
+	}
+	command, bot, addressed := strings.Cut(strings.TrimPrefix(fields[0], "/"), "@")
+	if addressed && telegramBotName != "" && !strings.EqualFold(bot, telegramBotName) {
+		return "", nil
+	}
+	return strings.ToLower(command), fields[1:]
+}
+
+// handleTelegramMessage answers a command. Commands act on the account the
+// chat is linked to; other chats are only told how to link them, with the
+// code that proves the link is made from inside the chat.
+func handleTelegramMessage(m *telegram.Message) {
+	command, args := parseTelegramCommand(m.Text)
+	if command == "" {
+		return
+	}
+
+	chat, err := dataStore.TelegramChat(m.Chat.ID)
+	if errors.Is(err, store.ErrNotFound) {
+		sendTelegram(m.Chat.ID, fmt.Sprintf("This chat is not linked to a scheduler account. To link it, enter its ID, %d, and the code %s under Telegram in the scheduler's web interface within the hour.",
+			m.Chat.ID, telegramLinkCode(m.Chat.ID, time.Now())))
+		return
+	}
+	if err != nil {
+		log.Printf("Error loading Telegram chat %d: %v", m.Chat.ID, err)
+		sendTelegram(m.Chat.ID, "Something went wrong, please try again later.")
+		return
+	}
+
+	logger := accountLogger(chat.Owner).With("telegramChat", chat.ChatID)
+	var reply string
+	switch command {
+	case "start", "help":
+		reply = telegramHelp
+	case "status":
+		reply = telegramStatus(chat)
+	case "schedules":
+		reply = telegramSchedules(chat.Owner)
+	case "on", "off":
+		if len(args) != 1 {
+			reply = fmt.Sprintf("Usage: /%s <project>", command)
+			break
+		}
+		reply = telegramScale(chat, logger, command, args[0])
+	default:
+		reply = "Unknown command. Send /help for the list."
+	}
+	sendTelegram(m.Chat.ID, reply)
+}
+
+// telegramToken returns the token the chat's commands run with.
+func telegramToken(chat TelegramChat) (string, error) {
+	telegramTokensMu.Lock()
+	token, ok := telegramTokens[chat.ChatID]
+	telegramTokensMu.Unlock()
+	if ok && ownerFromToken(token) == chat.Owner {
+		return token, nil
+	}
+	return storedToken(chat.Owner, chat.TokenCiphertext)
+}
+
+// telegramScale turns a project of the chat's account on or off right away,
+// with the default retry policy and a record in the execution history, just
+// as a schedule would, and describes the outcome.
+func telegramScale(chat TelegramChat, logger *slog.Logger, action, project string) string {
+	token, err := telegramToken(chat)
+	if err != nil {
+		logger.Warn("Telegram command without a usable token", "error", err)
+		return "This chat has no token to act with. Link it again in the web interface."
+	}
+
+	a := scaleAction{owner: chat.Owner, serviceType: "project", serviceName: project, action: action, token: token,
+		logger: logger.With(logKeyTarget, project)}
+	a.logger.Info(fmt.Sprintf("Turning %s project %s on request from Telegram", action, project))
+	if execution := executeAction(a, retry.DefaultPolicy(), time.Now()); execution.Outcome == ExecutionFailed {
+		return fmt.Sprintf("Could not turn %s project %s: %s", action, project, execution.Error)
+	}
+
+	reply := fmt.Sprintf("Turned %s project %s.", action, project)
+	target := reconcileTarget{owner: chat.Owner, serviceType: "project", serviceName: project}
+	if state, ok := desiredStates(time.Now())[target]; ok && onOff(state.on) != action {
+		reply += fmt.Sprintf(" Its window schedules want it %s, so the reconciler will turn it back.", onOff(state.on))
+	}
+	return reply
+}
+
+// telegramStatus describes the account: whether it is paused, its schedules
+// and, if the chat has a token, which projects are on.
+func telegramStatus(chat TelegramChat) string {
+	var b strings.Builder
+	response := accountSchedules(chat.Owner)
+	writeAccountOverview(&b, response)
+
+	token, err := telegramToken(chat)
+	if err != nil {
+		b.WriteString("Projects: unknown, this chat has no token to look them up with.\n")
+		return b.String()
+	}
+	scales, err := actualScales(context.Background(), "project", token)
+	if err != nil {
+		fmt.Fprintf(&b, "Projects: could not fetch them: %v\n", err)
+		return b.String()
+	}
+	b.WriteString("Projects:\n")
+	for _, name := range slices.Sorted(maps.Keys(scales)) {
+		fmt.Fprintf(&b, "- %s: %s\n", name, onOff(scales[name]))
+	}
+	return b.String()
+}
+
+// writeAccountOverview writes whether the account is paused, how many of its
+// schedules are enabled and which runs next.
+func writeAccountOverview(b *strings.Builder, response SchedulesResponse) {
+	if response.AccountPaused {
+		b.WriteString("Account: paused\n")
+	} else {
+		b.WriteString("Account: active\n")
+	}
+
+	enabled := 0
+	var next *Schedule
+	var nextAt time.Time
+	for i, s := range response.Schedules {
+		if s.Enabled {
+			enabled++
+		}
+		for _, at := range []*time.Time{s.NextRun, s.NextEnd} {
+			if at != nil && (next == nil || at.Before(nextAt)) {
+				next, nextAt = &response.Schedules[i], *at
+			}
+		}
+	}
+	fmt.Fprintf(b, "Schedules: %d of %d enabled\n", enabled, len(response.Schedules))
+	if next != nil {
+		fmt.Fprintf(b, "Next run: %s, %s\n", nextAt.Format("2006-01-02 15:04 MST"), describeSchedule(*next))
+	}
+}
+
+// telegramSchedules lists the account's schedules and their next runs.
+func telegramSchedules(owner string) string {
+	response := accountSchedules(owner)
+	if len(response.Schedules) == 0 {
+		return "No schedules added yet."
+	}
+
+	var b strings.Builder
+	if response.AccountPaused {
+		b.WriteString("All schedules are paused.\n")
+	}
+	for i, s := range response.Schedules {
+		fmt.Fprintf(&b, "%d. %s\n", i+1, describeSchedule(s))
+		switch {
+		case !s.Enabled:
+			b.WriteString("   paused\n")
+		case s.NextRun != nil:
+			fmt.Fprintf(&b, "   next: %s\n", s.NextRun.Format("2006-01-02 15:04 MST"))
+		}
+	}
+	return b.String()
+}
+
+// describeSchedule says in a few words what a schedule does and when.
+func describeSchedule(s Schedule) string {
+	var text string
+	if s.Kind == ScheduleKindWindow {
+		end := s.EndCronSpec
+		if end == "" {
+			end = "+" + s.Duration
+		}
+		text = fmt.Sprintf("%s %s on from %q to %q", s.ServiceType, s.ServiceName, s.CronSpec, end)
+	} else {
+		text = fmt.Sprintf("turn %s %s %s at %q", s.Action, s.ServiceType, s.ServiceName, s.CronSpec)
+	}
+	if s.Timezone != "" {
+		text += " " + s.Timezone
+	}
+	return text
+}
+
+// failureMessage describes a failed execution.
+func failureMessage(e Execution) string {
+	text := fmt.Sprintf("Failed to turn %s %s %s after %d attempt(s): %s\n", e.Action, e.ServiceType, e.ServiceName, e.Attempts, e.Error)
+	if e.ScheduleID != "" {
+		text += "Schedule: " + e.ScheduleID + "\n"
+	}
+	return text + "The action is in the failed actions and can be replayed."
+}
+
+// sendTelegramSummaries sends every account with linked chats a summary of
+// the last 24 hours.
+func sendTelegramSummaries(now time.Time) {
+	chats, err := dataStore.TelegramChats()
+	if err != nil {
+		log.Printf("Error loading Telegram chats for the daily summary: %v", err)
+		return
+	}
+	byOwner := make(map[string][]TelegramChat)
+	for _, c := range chats {
+		byOwner[c.Owner] = append(byOwner[c.Owner], c)
+	}
+	for owner, chats := range byOwner {
+		summary := telegramSummary(owner, now)
+		for _, c := range chats {
+			sendTelegram(c.ChatID, summary)
+		}
+	}
+}
+
+// telegramSummary describes the account's runs in the 24 hours before now
+// and what comes next.
+func telegramSummary(owner string, now time.Time) string {
+	report := buildAccountReport(owner, now.Add(-24*time.Hour), now)
+	var b strings.Builder
+	fmt.Fprintf(&b, "Daily summary, %s\n", now.Format("2006-01-02"))
+	fmt.Fprintf(&b, "Runs in the last 24 hours: %d succeeded, %d failed, %d skipped\n", report.Succeeded, report.Failed, report.Skipped)
+	for _, e := range report.Failures {
+		fmt.Fprintf(&b, "- %s turn %s %s %s: %s\n", e.PlannedAt.Local().Format("15:04"), e.Action, e.ServiceType, e.ServiceName, e.Error)
+	}
+	if report.PendingFailedActions > 0 {
+		fmt.Fprintf(&b, "Failed actions awaiting replay: %d\n", report.PendingFailedActions)
+	}
+	writeAccountOverview(&b, report.Account)
+	return b.String()
+}
+
+// notifyTelegramChats sends text to every chat linked to the owner.
+func notifyTelegramChats(owner, text string) {
+	chats, err := dataStore.TelegramChats()
+	if err != nil {
+		accountLogger(owner).Error("Error loading Telegram chats", "error", err)
+		return
+	}
+	for _, c := range chats {
+		if c.Owner == owner {
+			sendTelegram(c.ChatID, text)
+		}
+	}
+}
+
+// sendTelegram sends text to a chat, waiting as long as Telegram asks when
+// the bot sends too fast.
+func sendTelegram(chatID int64, text string) {
+	for attempt := 1; ; attempt++ {
+		err := telegramBot.SendMessage(context.Background(), chatID, text)
+		if err == nil {
+			return
+		}
+		var apiErr *telegram.APIError
+		if attempt >= telegramSendAttempts || !errors.As(err, &apiErr) || apiErr.RetryAfter == 0 || apiErr.RetryAfter > maxTelegramRetryAfter {
+			log.Printf("Error sending Telegram message to chat %d: %v", chatID, err)
+			return
+		}
+		select {
+		case <-time.After(apiErr.RetryAfter):
+		case <-shuttingDown:
+			log.Printf("Not sending Telegram message to chat %d, shutting down: %v", chatID, err)
+			return
+		}
+	}
+}
+
+type TelegramChatRequest struct {
+	ChatID int64 `json:"chatId"`
+	// Code is the link code the bot sent to the chat.
+	Code string `json:"code"`
+}
+
+// telegramChatsHandler lists the chats linked to the account (GET) or links
+// one (POST).
+func telegramChatsHandler(w http.ResponseWriter, r *http.Request) {
+	owner, err := getOwnerFromContext(r.Context())
+	if err != nil {
+		http.Error(w, `{"error": "Authentication required"}`, http.StatusUnauthorized)
+		return
+	}
+	if telegramBot == nil {
+		http.Error(w, `{"error": "Telegram bot is not configured"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		chats, err := ownerTelegramChats(owner)
+		if err != nil {
+			loggerFromContext(r.Context()).Error("Error loading Telegram chats", "error", err)
+			http.Error(w, `{"error": "Failed to fetch Telegram chats"}`, http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(chats)
+	case http.MethodPost:
+		linkTelegramChatHandler(w, r, owner)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ownerTelegramChats returns the chats linked to the owner, oldest first.
+func ownerTelegramChats(owner string) ([]TelegramChat, error) {
+	all, err := dataStore.TelegramChats()
+	if err != nil {
+		return nil, err
+	}
+	chats := make([]TelegramChat, 0)
+	for _, c := range all {
+		if c.Owner == owner {
+			chats = append(chats, c)
+		}
+	}
+	return chats, nil
+}
+
+// linkTelegramChatHandler links a chat the bot can see to the account, or
+// refreshes the token of a chat already linked to it. The request must carry
+// the chat's current link code, so only someone in the chat can link it.
+func linkTelegramChatHandler(w http.ResponseWriter, r *http.Request, owner string) {
+	logger := loggerFromContext(r.Context())
+
+	var req TelegramChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ChatID == 0 {
+		http.Error(w, `{"error": "Invalid request body: chatId is required"}`, http.StatusBadRequest)
+		return
+	}
+	if !validTelegramLinkCode(req.ChatID, req.Code, time.Now()) {
+		logger.Warn("Telegram chat link with an invalid code", "telegramChat", req.ChatID)
+		http.Error(w, `{"error": "Invalid or expired link code. Send /start to the bot from the chat for a new one."}`, http.StatusForbidden)
+		return
+	}
+	token, err := getTokenFromContext(r.Context())
+	if err != nil {
+		http.Error(w, `{"error": "Authentication required"}`, http.StatusUnauthorized)
+		return
+	}
+
+	existing, err := dataStore.TelegramChat(req.ChatID)
+	switch {
+	case err == nil && existing.Owner != owner:
+		http.Error(w, `{"error": "Chat is linked to another account"}`, http.StatusConflict)
+		return
+	case errors.Is(err, store.ErrNotFound):
+		linked, err := ownerTelegramChats(owner)
+		if err != nil {
+			logger.Error("Error loading Telegram chats", "error", err)
+			http.Error(w, `{"error": "Failed to fetch Telegram chats"}`, http.StatusInternalServerError)
+			return
+		}
+		if len(linked) >= maxTelegramChatsPerAccount {
+			http.Error(w, fmt.Sprintf(`{"error": "An account can link at most %d Telegram chats"}`, maxTelegramChatsPerAccount), http.StatusConflict)
+			return
+		}
+	case err != nil:
+		logger.Error("Error loading Telegram chat", "error", err)
+		http.Error(w, `{"error": "Failed to fetch Telegram chats"}`, http.StatusInternalServerError)
+		return
+	}
+
+	tgChat, err := telegramBot.GetChat(r.Context(), req.ChatID)
+	if err != nil {
+		logger.Warn("Telegram chat not found", "telegramChat", req.ChatID, "error", err)
+		http.Error(w, `{"error": "The bot cannot see this chat. Send /start to the bot from the chat, or add the bot to the group, and try again."}`, http.StatusBadRequest)
+		return
+	}
+
+	ciphertext, err := encryptToken(owner, token)
+	if err != nil {
+		logger.Error("Error encrypting token", "error", err)
+		http.Error(w, `{"error": "Failed to encrypt token"}`, http.StatusInternalServerError)
+		return
+	}
+	chat := TelegramChat{ChatID: req.ChatID, Owner: owner, Title: tgChat.Name(), TokenCiphertext: ciphertext, LinkedAt: time.Now()}
+	if err := dataStore.LinkTelegramChat(chat); err != nil {
+		logger.Error("Error saving Telegram chat", "error", err)
+		http.Error(w, `{"error": "Failed to save Telegram chat to store"}`, http.StatusInternalServerError)
+		return
+	}
+	telegramTokensMu.Lock()
+	telegramTokens[chat.ChatID] = token
+	telegramTokensMu.Unlock()
+	logger.Info("Linked Telegram chat", "telegramChat", chat.ChatID)
+
+	go sendTelegram(chat.ChatID, "This chat is now linked to a scheduler account. It will get failed runs and a daily summary.\n\n"+telegramHelp)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(chat)
+}
+
+// telegramChatItemHandler unlinks the chat in DELETE /telegram/chats/{id}.
+func telegramChatItemHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	owner, err := getOwnerFromContext(r.Context())
+	if err != nil {
+		http.Error(w, `{"error": "Authentication required"}`, http.StatusUnauthorized)
+		return
+	}
+
+	chatID, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/telegram/chats/"), 10, 64)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid chat ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	err = dataStore.UnlinkTelegramChat(owner, chatID)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, `{"error": "Telegram chat not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		loggerFromContext(r.Context()).Error("Error unlinking Telegram chat", "error", err)
+		http.Error(w, `{"error": "Failed to delete Telegram chat from store"}`, http.StatusInternalServerError)
+		return
+	}
+	telegramTokensMu.Lock()
+	delete(telegramTokens, chatID)
+	telegramTokensMu.Unlock()
+	loggerFromContext(r.Context()).Info("Unlinked Telegram chat", "telegramChat", chatID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Telegram chat unlinked successfully"})
+}
@@ -0,0 +1,222 @@
+// Package telegram is a small client for the parts of the Telegram Bot API
+// used by the scheduler: receiving commands by long polling and sending
+// plain-text messages.
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultBaseURL is the endpoint of the official Bot API.
+	DefaultBaseURL = "https://api.telegram.org"
+
+	// MaxMessageLength is the longest text Telegram accepts in a message,
+	// in characters. Longer texts are cut short by SendMessage.
+	MaxMessageLength = 4096
+
+	defaultTimeout = 10 * time.Second
+)
+
+// Client talks to the Bot API as the bot owning token.
+type Client struct {
+	baseURL    string
+	token      string
+	timeout    time.Duration
+	httpClient *http.Client
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithBaseURL overrides the Bot API base URL, e.g. for a local Bot API
+// server or a stand-in used in tests.
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		c.baseURL = strings.TrimRight(baseURL, "/")
+	}
+}
+
+// WithTimeout sets the timeout applied to every request. Long polls wait
+// for their own timeout on top of it.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// NewClient returns a Client of the official Bot API unless configured
+// otherwise.
+func NewClient(token string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    DefaultBaseURL,
+		token:      token,
+		timeout:    defaultTimeout,
+		httpClient: &http.Client{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// User is a Telegram user or bot.
+type User struct {
+	ID        int64  `json:"id"`
+	IsBot     bool   `json:"is_bot"`
+	FirstName string `json:"first_name"`
+	Username  string `json:"username,omitempty"`
+}
+
+// Chat is a private chat, group or channel.
+type Chat struct {
+	ID        int64  `json:"id"`
+	Type      string `json:"type"` // "private", "group", "supergroup" or "channel"
+	Title     string `json:"title,omitempty"`
+	Username  string `json:"username,omitempty"`
+	FirstName string `json:"first_name,omitempty"`
+	LastName  string `json:"last_name,omitempty"`
+}
+
+// Name returns what people would call the chat: its title, the user name or
+// the person's name.
+func (c Chat) Name() string {
+	switch {
+	case c.Title != "":
+		return c.Title
+	case c.Username != "":
+		return "@" + c.Username
+	default:
+		return strings.TrimSpace(c.FirstName + " " + c.LastName)
+	}
+}
+
+// Message is a message received by the bot.
+type Message struct {
+	MessageID int64  `json:"message_id"`
+	From      *User  `json:"from,omitempty"`
+	Chat      Chat   `json:"chat"`
+	Date      int64  `json:"date"`
+	Text      string `json:"text,omitempty"`
+}
+
+// Update is an incoming update. Only messages are asked for, so Message is
+// nil only for kinds of updates the bot did not ask for.
+type Update struct {
+	UpdateID int64    `json:"update_id"`
+	Message  *Message `json:"message,omitempty"`
+}
+
+// APIError is returned when the Bot API rejects a request.
+type APIError struct {
+	Method      string
+	StatusCode  int
+	Description string
+	// RetryAfter is how long to wait before trying again when the bot is
+	// sending too fast; 0 otherwise.
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("Telegram %s failed with status %d: %s", e.Method, e.StatusCode, e.Description)
+}
+
+// GetMe returns the bot's own user, which also checks the token.
+func (c *Client) GetMe(ctx context.Context) (User, error) {
+	var me User
+	err := c.call(ctx, c.timeout, "getMe", struct{}{}, &me)
+	return me, err
+}
+
+// GetChat returns the chat with the given ID, if the bot can see it.
+func (c *Client) GetChat(ctx context.Context, chatID int64) (Chat, error) {
+	var chat Chat
+	err := c.call(ctx, c.timeout, "getChat", map[string]any{"chat_id": chatID}, &chat)
+	return chat, err
+}
+
+// SendMessage sends text to a chat as plain text, cut to MaxMessageLength.
+func (c *Client) SendMessage(ctx context.Context, chatID int64, text string) error {
+	if utf8.RuneCountInString(text) > MaxMessageLength {
+		text = string([]rune(text)[:MaxMessageLength-1]) + "…"
+	}
+	params := map[string]any{"chat_id": chatID, "text": text, "disable_web_page_preview": true}
+	return c.call(ctx, c.timeout, "sendMessage", params, nil)
+}
+
+// GetUpdates waits up to timeout for messages with an ID of at least offset.
+// Passing the ID after the last one received confirms the earlier ones, so
+// they are not sent again.
+func (c *Client) GetUpdates(ctx context.Context, offset int64, timeout time.Duration) ([]Update, error) {
+	params := map[string]any{"offset": offset, "timeout": int(timeout / time.Second), "allowed_updates": []string{"message"}}
+	var updates []Update
+	err := c.call(ctx, c.timeout+timeout, "getUpdates", params, &updates)
+	return updates, err
+}
+
+// call sends a Bot API request and decodes its result into result, unless
+// result is nil.
+func (c *Client) call(ctx context.Context, timeout time.Duration, method string, params, result any) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	body, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("error encoding request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/bot"+c.token+"/"+method, bytes.NewReader(body))
+	if err != nil {
+		// The URL holds the token; keep it out of the message.
+		return fmt.Errorf("error creating %s request", method)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("Telegram %s: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading %s response: %w", method, err)
+	}
+
+	var envelope struct {
+		OK          bool            `json:"ok"`
+		Result      json.RawMessage `json:"result"`
+		Description string          `json:"description"`
+		Parameters  struct {
+			RetryAfter int `json:"retry_after"`
+		} `json:"parameters"`
+	}
+	if err := json.Unmarshal(respBody, &envelope); err != nil || !envelope.OK {
+		apiErr := &APIError{Method: method, StatusCode: resp.StatusCode, Description: envelope.Description,
+			RetryAfter: time.Duration(envelope.Parameters.RetryAfter) * time.Second}
+		if apiErr.Description == "" {
+			apiErr.Description = http.StatusText(resp.StatusCode)
+		}
+		return apiErr
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(envelope.Result, result); err != nil {
+		return fmt.Errorf("error decoding %s response: %w", method, err)
+	}
+	return nil
+}
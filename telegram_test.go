@@ -0,0 +1,262 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"scheduler/store"
+	"scheduler/telegram"
+)
+
+// fakeBotAPI stands in for the Telegram Bot API and records the messages the
+// bot sends.
+type fakeBotAPI struct {
+	mu       sync.Mutex
+	messages map[int64][]string
+	// throttle answers that many sendMessage calls with 429 and retryAfter
+	// seconds before accepting them.
+	throttle   int
+	retryAfter int
+	sendCalls  int
+}
+
+func (f *fakeBotAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var params struct {
+		ChatID int64  `json:"chat_id"`
+		Text   string `json:"text"`
+	}
+	json.NewDecoder(r.Body).Decode(&params)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.URL.Path[strings.LastIndexByte(r.URL.Path, '/')+1:] {
+	case "getMe":
+		fmt.Fprint(w, `{"ok":true,"result":{"id":1,"is_bot":true,"username":"schedulerbot"}}`)
+	case "getChat":
+		fmt.Fprintf(w, `{"ok":true,"result":{"id":%d,"type":"private","first_name":"Sam"}}`, params.ChatID)
+	case "sendMessage":
+		f.sendCalls++
+		if f.throttle > 0 {
+			f.throttle--
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprintf(w, `{"ok":false,"error_code":429,"description":"Too Many Requests","parameters":{"retry_after":%d}}`, f.retryAfter)
+			return
+		}
+		f.messages[params.ChatID] = append(f.messages[params.ChatID], params.Text)
+		fmt.Fprint(w, `{"ok":true,"result":{}}`)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"ok":false,"description":"Not Found"}`)
+	}
+}
+
+// take returns and forgets the messages sent to chatID so far.
+func (f *fakeBotAPI) take(chatID int64) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	messages := f.messages[chatID]
+	delete(f.messages, chatID)
+	return messages
+}
+
+// calls returns how many sendMessage calls were made.
+func (f *fakeBotAPI) calls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.sendCalls
+}
+
+// startFakeBotAPI points telegramBot at a fresh fakeBotAPI for the rest of
+// the test.
+func startFakeBotAPI(t *testing.T) *fakeBotAPI {
+	t.Helper()
+	fake := &fakeBotAPI{messages: make(map[int64][]string)}
+	srv := httptest.NewServer(fake)
+	savedBot, savedName, savedKey := telegramBot, telegramBotName, telegramLinkKey
+	telegramBot = telegram.NewClient("123:test", telegram.WithBaseURL(srv.URL))
+	telegramBotName, telegramLinkKey = "schedulerbot", []byte("test link key")
+	t.Cleanup(func() {
+		telegramBot, telegramBotName, telegramLinkKey = savedBot, savedName, savedKey
+		srv.Close()
+	})
+	return fake
+}
+
+// command sends text from chatID to the bot and returns its replies.
+func (f *fakeBotAPI) command(chatID int64, text string) []string {
+	handleTelegramMessage(&telegram.Message{Chat: telegram.Chat{ID: chatID, Type: "private"}, Text: text})
+	return f.take(chatID)
+}
+
+var linkCodePattern = regexp.MustCompile(`the code ([A-Z2-7]+)`)
+
+func TestTelegramLinkNeedsCodeFromChat(t *testing.T) {
+	fake := startFakeBotAPI(t)
+	const chatID = 4242
+
+	replies := fake.command(chatID, "/start")
+	if len(replies) != 1 || !strings.Contains(replies[0], "not linked") {
+		t.Fatalf("/start in an unlinked chat replied %q", replies)
+	}
+	match := linkCodePattern.FindStringSubmatch(replies[0])
+	if match == nil {
+		t.Fatalf("no link code in %q", replies[0])
+	}
+
+	for _, code := range []string{"", "AAAAAAAAAA", telegramLinkCode(chatID+1, time.Now()), telegramLinkCode(chatID, time.Now().Add(-3*time.Hour))} {
+		body := fmt.Sprintf(`{"chatId":%d,"code":%q}`, chatID, code)
+		if status, resp := serve(t, telegramChatsHandler, http.MethodPost, "/telegram/chats", body); status != http.StatusForbidden {
+			t.Errorf("linking with code %q: %d %s, want 403", code, status, resp)
+		}
+	}
+
+	body := fmt.Sprintf(`{"chatId":%d,"code":%q}`, chatID, strings.ToLower(match[1]))
+	if status, resp := serve(t, telegramChatsHandler, http.MethodPost, "/telegram/chats", body); status != http.StatusCreated {
+		t.Fatalf("linking with the code from the chat: %d %s", status, resp)
+	}
+	t.Cleanup(func() {
+		serve(t, telegramChatItemHandler, http.MethodDelete, fmt.Sprintf("/telegram/chats/%d", chatID), "")
+	})
+	fake.waitForMessage(t, chatID)
+	if chat, err := dataStore.TelegramChat(chatID); err != nil || chat.Owner != testOwner {
+		t.Errorf("stored chat = %+v, %v", chat, err)
+	}
+}
+
+func TestTelegramCommands(t *testing.T) {
+	fake := startFakeBotAPI(t)
+	const chatID, unlinkedChatID = 5151, 6161
+	linkTestChat(t, fake, chatID)
+
+	var mu sync.Mutex
+	var scaleCalls []string
+	stubLiara(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`{"projects":[{"project_id":"app","scale":1},{"project_id":"worker","scale":0}]}`))
+			return
+		}
+		mu.Lock()
+		scaleCalls = append(scaleCalls, r.URL.Path)
+		mu.Unlock()
+		w.Write([]byte(`{}`))
+	})
+	createSchedule(t, `{"service":"app","serviceType":"project","action":"off","cron":"0 20 * * *"}`)
+	start := time.Now()
+
+	tests := []struct {
+		chatID int64
+		text   string
+		want   []string // substrings of the only reply; nil for no reply
+		scales int      // scale calls the command makes
+	}{
+		{chatID, "/status", []string{"Projects:", "- app: on", "- worker: off"}, 0},
+		{chatID, "/schedules", []string{"app", "0 20 * * *"}, 0},
+		{chatID, "/on worker", []string{"Turned on project worker."}, 1},
+		{chatID, "/off@schedulerbot app", []string{"Turned off project app."}, 1},
+		{chatID, "/on", []string{"Usage: /on <project>"}, 0},
+		{chatID, "/on@otherbot worker", nil, 0},
+		{chatID, "not a command", nil, 0},
+		{unlinkedChatID, "/status", []string{"not linked"}, 0},
+		{unlinkedChatID, "/on worker", []string{"not linked"}, 0},
+	}
+	for _, tt := range tests {
+		mu.Lock()
+		before := len(scaleCalls)
+		mu.Unlock()
+
+		replies := fake.command(tt.chatID, tt.text)
+		switch {
+		case tt.want == nil && len(replies) > 0:
+			t.Errorf("%q in chat %d got replies %q, want none", tt.text, tt.chatID, replies)
+		case tt.want != nil && len(replies) != 1:
+			t.Errorf("%q in chat %d got replies %q, want one", tt.text, tt.chatID, replies)
+		case tt.want != nil:
+			for _, want := range tt.want {
+				if !strings.Contains(replies[0], want) {
+					t.Errorf("%q in chat %d replied %q, want it to contain %q", tt.text, tt.chatID, replies[0], want)
+				}
+			}
+		}
+
+		mu.Lock()
+		if got := len(scaleCalls) - before; got != tt.scales {
+			t.Errorf("%q in chat %d made %d scale calls, want %d", tt.text, tt.chatID, got, tt.scales)
+		}
+		mu.Unlock()
+	}
+
+	// Commands are recorded like scheduled runs.
+	executions, _, err := dataStore.Executions(store.ExecutionFilter{Owner: testOwner, From: start, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var recorded []string
+	for _, e := range executions {
+		if e.Outcome != ExecutionSucceeded || e.Attempts != 1 {
+			t.Errorf("execution of %s %s = %s after %d attempts, want one successful attempt", e.Action, e.ServiceName, e.Outcome, e.Attempts)
+		}
+		recorded = append(recorded, e.Action+" "+e.ServiceName)
+	}
+	slices.Sort(recorded)
+	if want := []string{"off app", "on worker"}; !slices.Equal(recorded, want) {
+		t.Errorf("executions %q, want %q", recorded, want)
+	}
+}
+
+// linkTestChat links chatID to the test account with the code the bot sends
+// it.
+func linkTestChat(t *testing.T, fake *fakeBotAPI, chatID int64) {
+	t.Helper()
+	body := fmt.Sprintf(`{"chatId":%d,"code":%q}`, chatID, telegramLinkCode(chatID, time.Now()))
+	if status, resp := serve(t, telegramChatsHandler, http.MethodPost, "/telegram/chats", body); status != http.StatusCreated {
+		t.Fatalf("linking chat: %d %s", status, resp)
+	}
+	t.Cleanup(func() {
+		serve(t, telegramChatItemHandler, http.MethodDelete, fmt.Sprintf("/telegram/chats/%d", chatID), "")
+	})
+	fake.waitForMessage(t, chatID)
+}
+
+// waitForMessage waits for the welcome message sent in the background when
+// chatID is linked, and forgets it.
+func (f *fakeBotAPI) waitForMessage(t *testing.T, chatID int64) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if len(f.take(chatID)) > 0 {
+			return
+		}
+	}
+	t.Fatal("no welcome message after linking")
+}
+
+func TestSendTelegramHonoursRetryAfter(t *testing.T) {
+	fake := startFakeBotAPI(t)
+	fake.throttle, fake.retryAfter = 1, 1
+
+	start := time.Now()
+	sendTelegram(7171, "hello")
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("sent again after %s, want the 1s Telegram asked for", elapsed)
+	}
+	if messages := fake.take(7171); len(messages) != 1 || messages[0] != "hello" || fake.calls() != 2 {
+		t.Errorf("delivered %q in %d calls, want hello after a throttled call", messages, fake.calls())
+	}
+
+	// Waits longer than maxTelegramRetryAfter are not worth it.
+	fake.mu.Lock()
+	fake.throttle, fake.retryAfter, fake.sendCalls = 1, int(2*maxTelegramRetryAfter/time.Second), 0
+	fake.mu.Unlock()
+	start = time.Now()
+	sendTelegram(7171, "dropped")
+	if elapsed := time.Since(start); elapsed > 5*time.Second || fake.calls() != 1 {
+		t.Errorf("made %d calls in %s, want to give up at once", fake.calls(), elapsed)
+	}
+}
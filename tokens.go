@@ -0,0 +1,184 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"scheduler/keyring"
+	"scheduler/store"
+)
+
+// tokenKeyring encrypts the tokens stored with each schedule. It is nil when
+// no keys are configured, in which case tokens are not persisted.
+var tokenKeyring *keyring.Keyring
+
+// loadTokenKeyring reads keys from TOKEN_ENCRYPTION_KEYS or, if unset, from the
+// file named by TOKEN_ENCRYPTION_KEYS_FILE. Both hold "id:base64key" entries,
+// primary key first.
+func loadTokenKeyring() (*keyring.Keyring, error) {
+	spec := os.Getenv("TOKEN_ENCRYPTION_KEYS")
+	if spec == "" {
+		path := os.Getenv("TOKEN_ENCRYPTION_KEYS_FILE")
+		if path == "" {
+			return nil, nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading TOKEN_ENCRYPTION_KEYS_FILE: %w", err)
+		}
+		spec = string(content)
+	}
+	return keyring.Parse(spec)
+}
+
+// encryptToken returns the ciphertext to store alongside a schedule or a
+// Telegram chat, or "" when encryption is not configured. The owner is bound
+// as associated data so a ciphertext cannot be moved to another account.
+func encryptToken(owner, token string) (string, error) {
+	if tokenKeyring == nil {
+		return "", nil
+	}
+	return tokenKeyring.Encrypt([]byte(token), []byte(owner))
+}
+
+// storedToken recovers the token a stored schedule, Telegram chat or email
+// recipient runs with. Records stored without a token fall back to
+// LIARA_API_TOKEN when it belongs to the same owner.
+func storedToken(owner, ciphertext string) (string, error) {
+	if ciphertext != "" {
+		if tokenKeyring == nil {
+			return "", errors.New("token is encrypted but no TOKEN_ENCRYPTION_KEYS are configured")
+		}
+		token, err := tokenKeyring.Decrypt(ciphertext, []byte(owner))
+		if err != nil {
+			return "", err
+		}
+		return string(token), nil
+	}
+
+	envToken := os.Getenv("LIARA_API_TOKEN")
+	if envToken == "" {
+		return "", errors.New("no stored token and LIARA_API_TOKEN not set")
+	}
+	if ownerFromToken(envToken) != owner {
+		return "", errors.New("no stored token and LIARA_API_TOKEN belongs to another account")
+	}
+	return envToken, nil
+}
+
+// runCommand runs a maintenance subcommand instead of the server.
+func runCommand(args []string) error {
+	switch args[0] {
+	case "keygen":
+		fs := flag.NewFlagSet("keygen", flag.ExitOnError)
+		id := fs.String("id", "k1", "identifier of the new key")
+		fs.Parse(args[1:])
+
+		key, err := keyring.GenerateKey(*id)
+		if err != nil {
+			return err
+		}
+		fmt.Println(key)
+		return nil
+	case "reencrypt":
+		return reencryptTokens()
+	case "migrate":
+		return migrateCommand(args[1:])
+	default:
+		return fmt.Errorf("unknown command %q (available: keygen, reencrypt, migrate)", args[0])
+	}
+}
+
+// reencryptTokens rewrites every stored token that was not encrypted with the
+// primary key. After a rotation, put the new key first in the keyring, run
+// this command, then drop the old key.
+func reencryptTokens() error {
+	if tokenKeyring == nil {
+		return errors.New("reencrypt: TOKEN_ENCRYPTION_KEYS is not configured")
+	}
+	if os.Getenv("STORE_BACKEND") == "" && os.Getenv("DATABASE_URL") == "" {
+		return errors.New("reencrypt: no store configured, set DATABASE_URL or STORE_BACKEND")
+	}
+	s, err := openStore()
+	if err != nil {
+		return fmt.Errorf("reencrypt: %w", err)
+	}
+	defer s.Close()
+
+	credentials, err := s.Credentials()
+	if err != nil {
+		return fmt.Errorf("reencrypt: reading stored tokens: %w", err)
+	}
+
+	var stale []store.Credential
+	for _, c := range credentials {
+		if tokenKeyring.IsCurrent(c.Ciphertext) {
+			continue
+		}
+		token, err := tokenKeyring.Decrypt(c.Ciphertext, []byte(c.Owner))
+		if err != nil {
+			return fmt.Errorf("reencrypt: schedule %s: %w", c.ScheduleID, err)
+		}
+		c.Ciphertext, err = tokenKeyring.Encrypt(token, []byte(c.Owner))
+		if err != nil {
+			return fmt.Errorf("reencrypt: schedule %s: %w", c.ScheduleID, err)
+		}
+		stale = append(stale, c)
+	}
+
+	if err := s.UpdateCredentials(stale); err != nil {
+		return fmt.Errorf("reencrypt: %w", err)
+	}
+
+	chats, err := s.TelegramChats()
+	if err != nil {
+		return fmt.Errorf("reencrypt: reading Telegram chats: %w", err)
+	}
+	reencryptedChats := 0
+	for _, c := range chats {
+		if c.TokenCiphertext == "" || tokenKeyring.IsCurrent(c.TokenCiphertext) {
+			continue
+		}
+		token, err := tokenKeyring.Decrypt(c.TokenCiphertext, []byte(c.Owner))
+		if err != nil {
+			return fmt.Errorf("reencrypt: Telegram chat %d: %w", c.ChatID, err)
+		}
+		c.TokenCiphertext, err = tokenKeyring.Encrypt(token, []byte(c.Owner))
+		if err != nil {
+			return fmt.Errorf("reencrypt: Telegram chat %d: %w", c.ChatID, err)
+		}
+		if err := s.LinkTelegramChat(c); err != nil {
+			return fmt.Errorf("reencrypt: %w", err)
+		}
+		reencryptedChats++
+	}
+
+	recipients, err := s.EmailRecipients()
+	if err != nil {
+		return fmt.Errorf("reencrypt: reading email recipients: %w", err)
+	}
+	reencryptedRecipients := 0
+	for _, r := range recipients {
+		if r.TokenCiphertext == "" || tokenKeyring.IsCurrent(r.TokenCiphertext) {
+			continue
+		}
+		token, err := tokenKeyring.Decrypt(r.TokenCiphertext, []byte(r.Owner))
+		if err != nil {
+			return fmt.Errorf("reencrypt: email recipient %s: %w", r.ID, err)
+		}
+		r.TokenCiphertext, err = tokenKeyring.Encrypt(token, []byte(r.Owner))
+		if err != nil {
+			return fmt.Errorf("reencrypt: email recipient %s: %w", r.ID, err)
+		}
+		if err := s.SaveEmailRecipient(r); err != nil {
+			return fmt.Errorf("reencrypt: %w", err)
+		}
+		reencryptedRecipients++
+	}
+
+	log.Printf("Re-encrypted %d stored tokens with key %q.", len(stale)+reencryptedChats+reencryptedRecipients, tokenKeyring.PrimaryID())
+	return nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"scheduler/keyring"
+	"scheduler/store"
+)
+
+func TestReencryptTokens(t *testing.T) {
+	oldKey, err := keyring.GenerateKey("old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey, err := keyring.GenerateKey("new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, _ := keyring.Parse(oldKey)
+	rotated, _ := keyring.Parse(newKey + "," + oldKey)
+
+	path := filepath.Join(t.TempDir(), "scheduler.db")
+	t.Setenv("STORE_BACKEND", "sqlite")
+	t.Setenv("STORE_PATH", path)
+	s, err := store.OpenSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ownerA, ownerB := ownerFromToken("token-a"), ownerFromToken("token-b")
+	seal := func(k *keyring.Keyring, owner, token string) string {
+		t.Helper()
+		ciphertext, err := k.Encrypt([]byte(token), []byte(owner))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ciphertext
+	}
+	current := seal(rotated, ownerB, "token-b")
+	for _, sc := range []store.Schedule{
+		{ID: "11111111-1111-1111-1111-111111111111", Owner: ownerA, Kind: ScheduleKindSingle, ServiceName: "app",
+			ServiceType: "project", Action: "on", CronSpec: "0 8 * * *", Enabled: true, TokenCiphertext: seal(before, ownerA, "token-a")},
+		{ID: "22222222-2222-2222-2222-222222222222", Owner: ownerB, Kind: ScheduleKindSingle, ServiceName: "db",
+			ServiceType: "database", Action: "off", CronSpec: "0 20 * * *", Enabled: true, TokenCiphertext: current},
+	} {
+		if err := s.CreateSchedule(sc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.LinkTelegramChat(store.TelegramChat{ChatID: 42, Owner: ownerA, Title: "ops",
+		TokenCiphertext: seal(before, ownerA, "token-a"), LinkedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []store.EmailRecipient{
+		{ID: "r1", Owner: ownerB, Address: "b@example.com", Digest: DigestDaily, TokenCiphertext: seal(before, ownerB, "token-b"), CreatedAt: time.Now()},
+		{ID: "r2", Owner: ownerA, Address: "a@example.com", Failures: true, CreatedAt: time.Now()},
+	} {
+		if err := s.SaveEmailRecipient(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Close()
+
+	saved := tokenKeyring
+	tokenKeyring = rotated
+	defer func() { tokenKeyring = saved }()
+	if err := reencryptTokens(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = store.OpenSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// Everything must now be readable with the new key alone.
+	newOnly, _ := keyring.Parse(newKey)
+	expect := func(what, owner, ciphertext, want string) {
+		t.Helper()
+		token, err := newOnly.Decrypt(ciphertext, []byte(owner))
+		if err != nil || string(token) != want {
+			t.Errorf("%s after reencrypt = %q, %v, want %q under the new key", what, token, err, want)
+		}
+	}
+	credentials, err := s.Credentials()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(credentials) != 2 {
+		t.Fatalf("%d credentials, want 2", len(credentials))
+	}
+	for _, c := range credentials {
+		want := "token-a"
+		if c.Owner == ownerB {
+			want = "token-b"
+			if c.Ciphertext != current {
+				t.Error("a token already under the new key was rewritten")
+			}
+		}
+		expect("schedule "+c.ScheduleID, c.Owner, c.Ciphertext, want)
+	}
+
+	chat, err := s.TelegramChat(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect("Telegram chat", ownerA, chat.TokenCiphertext, "token-a")
+
+	recipients, err := s.EmailRecipients()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recipients) != 2 {
+		t.Fatalf("%d email recipients, want 2", len(recipients))
+	}
+	for _, r := range recipients {
+		switch r.ID {
+		case "r1":
+			expect("email recipient", ownerB, r.TokenCiphertext, "token-b")
+		case "r2":
+			if r.TokenCiphertext != "" {
+				t.Errorf("recipient without a token got %q", r.TokenCiphertext)
+			}
+		}
+	}
+}
+
+func TestStoredToken(t *testing.T) {
+	key, err := keyring.GenerateKey("k1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := tokenKeyring
+	tokenKeyring, _ = keyring.Parse(key)
+	defer func() { tokenKeyring = saved }()
+
+	ownerA, ownerB := ownerFromToken("token-a"), ownerFromToken("token-b")
+	ciphertext, err := encryptToken(ownerA, "token-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token, err := storedToken(ownerA, ciphertext); err != nil || token != "token-a" {
+		t.Errorf("storedToken = %q, %v, want token-a", token, err)
+	}
+	if token, err := storedToken(ownerB, ciphertext); err == nil {
+		t.Errorf("storedToken under another owner = %q, want an error", token)
+	}
+
+	t.Setenv("LIARA_API_TOKEN", "token-a")
+	if token, err := storedToken(ownerA, ""); err != nil || token != "token-a" {
+		t.Errorf("storedToken without a ciphertext = %q, %v, want LIARA_API_TOKEN", token, err)
+	}
+	if token, err := storedToken(ownerB, ""); err == nil {
+		t.Errorf("storedToken of another account without a ciphertext = %q, want an error", token)
+	}
+
+	tokenKeyring = nil
+	if ciphertext, err := encryptToken(ownerA, "token-a"); err != nil || ciphertext != "" {
+		t.Errorf("encryptToken without keys = %q, %v, want nothing stored", ciphertext, err)
+	}
+	if _, err := storedToken(ownerA, "v1:k1:abc"); err == nil {
+		t.Error("storedToken of a ciphertext without keys succeeded")
+	}
+}
@@ -0,0 +1,173 @@
+// Package webhook sends signed event notifications to URLs chosen by the
+// accounts of the scheduler.
+//
+// Every notification is a JSON POST with these headers:
+//
+//	X-Scheduler-Event      the event type
+//	X-Scheduler-Delivery   the delivery ID, the same on every attempt
+//	X-Scheduler-Timestamp  Unix seconds when the attempt was sent
+//	X-Scheduler-Signature  "sha256=" and the hex HMAC-SHA256 of
+//	                       "<timestamp>.<body>" keyed with the webhook secret
+//
+// Receivers check the signature with Verify and should reject timestamps
+// too far in the past, so that a captured request cannot be replayed.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Request headers.
+const (
+	HeaderEvent     = "X-Scheduler-Event"
+	HeaderDelivery  = "X-Scheduler-Delivery"
+	HeaderTimestamp = "X-Scheduler-Timestamp"
+	HeaderSignature = "X-Scheduler-Signature"
+)
+
+const (
+	defaultTimeout   = 10 * time.Second
+	defaultUserAgent = "liara-scheduler-webhook"
+	signaturePrefix  = "sha256="
+)
+
+// ErrPrivateAddress is returned when a URL resolves to a loopback, private
+// or link-local address and the sender does not allow those.
+var ErrPrivateAddress = errors.New("webhook address is not public")
+
+// NewSecret returns a random secret to sign a webhook's requests with.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Sign returns the X-Scheduler-Signature value of body sent at timestamp.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is the signature of body sent at
+// timestamp, in constant time.
+func Verify(secret, signature string, timestamp int64, body []byte) bool {
+	return hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, body)))
+}
+
+// StatusError is returned when the receiver answers with a status outside
+// 2xx.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("webhook answered with status %d", e.StatusCode)
+}
+
+// Sender posts notifications.
+type Sender struct {
+	userAgent    string
+	allowPrivate bool
+	httpClient   *http.Client
+}
+
+// Option configures a Sender.
+type Option func(*Sender)
+
+// WithTimeout sets the timeout of every request.
+func WithTimeout(timeout time.Duration) Option {
+	return func(s *Sender) {
+		s.httpClient.Timeout = timeout
+	}
+}
+
+// WithPrivateNetworks lets the sender reach loopback, private and
+// link-local addresses, which it refuses by default so that accounts cannot
+// probe the network the scheduler runs in.
+func WithPrivateNetworks() Option {
+	return func(s *Sender) {
+		s.allowPrivate = true
+	}
+}
+
+// NewSender returns a Sender that only reaches public addresses unless
+// configured otherwise.
+func NewSender(opts ...Option) *Sender {
+	s := &Sender{userAgent: defaultUserAgent, httpClient: &http.Client{Timeout: defaultTimeout}}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	dialer := &net.Dialer{Timeout: s.httpClient.Timeout}
+	if !s.allowPrivate {
+		// Checking the address actually dialed also covers redirects and
+		// names that resolve differently on every lookup.
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublic(ip) {
+				return ErrPrivateAddress
+			}
+			return nil
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	s.httpClient.Transport = transport
+	return s
+}
+
+func isPublic(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsUnspecified() && !ip.IsMulticast() && !ip.IsInterfaceLocalMulticast()
+}
+
+// Send posts body, signed with secret, to url. It returns the status of the
+// response, 0 if there was none, and a *StatusError for statuses outside
+// 2xx.
+func (s *Sender) Send(ctx context.Context, url, secret, event, deliveryID string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("error creating request: %w", err)
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", s.userAgent)
+	req.Header.Set(HeaderEvent, event)
+	req.Header.Set(HeaderDelivery, deliveryID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(secret, timestamp, body))
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Read a little of the body so the connection can be reused.
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, &StatusError{StatusCode: resp.StatusCode}
+	}
+	return resp.StatusCode, nil
+}
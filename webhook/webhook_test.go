@@ -0,0 +1,127 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"event":"execution.failed"}`)
+	// Computed independently: HMAC-SHA256 of `1700000000.{"event":"execution.failed"}`
+	// keyed with whsec-test.
+	const want = "sha256=2d2ce6f54b006fd50174278e676298ccd5d8b1f9089fa6cc5a932ba48a94ad04"
+	if got := Sign("whsec-test", 1700000000, body); got != want {
+		t.Errorf("Sign = %q, want %q", got, want)
+	}
+
+	if !Verify("whsec-test", want, 1700000000, body) {
+		t.Error("Verify rejected the signature")
+	}
+	for name, verify := range map[string]func() bool{
+		"other secret":    func() bool { return Verify("whsec-other", want, 1700000000, body) },
+		"other timestamp": func() bool { return Verify("whsec-test", want, 1700000001, body) },
+		"other body":      func() bool { return Verify("whsec-test", want, 1700000000, []byte(`{}`)) },
+		"no prefix":       func() bool { return Verify("whsec-test", want[len("sha256="):], 1700000000, body) },
+	} {
+		if verify() {
+			t.Errorf("Verify accepted the signature with %s", name)
+		}
+	}
+}
+
+func TestNewSecret(t *testing.T) {
+	a, err := NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a) != 64 || a == b {
+		t.Errorf("secrets %q and %q, want two different 32-byte hex strings", a, b)
+	}
+}
+
+func TestSend(t *testing.T) {
+	var got *http.Request
+	var gotBody []byte
+	status := http.StatusNoContent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	s := NewSender(WithPrivateNetworks())
+	body := []byte(`{"event":"execution.succeeded"}`)
+	code, err := s.Send(context.Background(), srv.URL, "whsec-test", "execution.succeeded", "d1", body)
+	if err != nil || code != http.StatusNoContent {
+		t.Fatalf("Send = %d, %v, want 204", code, err)
+	}
+	if string(gotBody) != string(body) {
+		t.Errorf("body = %q, want %q", gotBody, body)
+	}
+	if got.Header.Get(HeaderEvent) != "execution.succeeded" || got.Header.Get(HeaderDelivery) != "d1" ||
+		got.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("headers = %v", got.Header)
+	}
+	timestamp, err := strconv.ParseInt(got.Header.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		t.Fatalf("timestamp header: %v", err)
+	}
+	if !Verify("whsec-test", got.Header.Get(HeaderSignature), timestamp, gotBody) {
+		t.Error("the signature header does not verify")
+	}
+
+	status = http.StatusServiceUnavailable
+	code, err = s.Send(context.Background(), srv.URL, "whsec-test", "execution.succeeded", "d1", body)
+	var statusErr *StatusError
+	if code != http.StatusServiceUnavailable || !errors.As(err, &statusErr) || statusErr.StatusCode != code {
+		t.Errorf("Send = %d, %v, want 503 with a *StatusError", code, err)
+	}
+}
+
+func TestSendRefusesPrivateAddresses(t *testing.T) {
+	received := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = true
+	}))
+	defer srv.Close()
+
+	code, err := NewSender().Send(context.Background(), srv.URL, "whsec-test", "execution.failed", "d1", []byte(`{}`))
+	if code != 0 || !errors.Is(err, ErrPrivateAddress) {
+		t.Errorf("Send to %s = %d, %v, want ErrPrivateAddress", srv.URL, code, err)
+	}
+	if received {
+		t.Error("the loopback server received the request")
+	}
+}
+
+func TestIsPublic(t *testing.T) {
+	for address, want := range map[string]bool{
+		"8.8.8.8":     true,
+		"2001:db8::1": true,
+		"127.0.0.1":   false,
+		"::1":         false,
+		"10.1.2.3":    false,
+		"172.16.0.1":  false,
+		"192.168.1.1": false,
+		"169.254.1.1": false,
+		"fe80::1":     false,
+		"fd00::1":     false,
+		"0.0.0.0":     false,
+		"224.0.0.1":   false,
+	} {
+		if got := isPublic(net.ParseIP(address)); got != want {
+			t.Errorf("isPublic(%s) = %v, want %v", address, got, want)
+		}
+	}
+}
@@ -0,0 +1,482 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"scheduler/events"
+	"scheduler/retry"
+	"scheduler/store"
+	"scheduler/webhook"
+)
+
+// Delivery statuses.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// EventPing is sent to a webhook on request, to try it out.
+const EventPing = "ping"
+
+const (
+	maxWebhooksPerAccount  = 10
+	defaultDeliveriesLimit = 20
+	// webhookQueueSize is how many events may wait for the dispatcher
+	// before newer ones are dropped.
+	webhookQueueSize = 256
+)
+
+// webhookEvents are the event types webhooks can subscribe to.
+var webhookEvents = []string{EventScaleSucceeded, EventScaleFailed, EventRetriesExhausted, EventReconcileDrift}
+
+// webhookRetryPolicy spreads the attempts of a delivery over about an hour.
+// Receivers that answer 4xx, other than 408, 425 and 429, are not retried.
+var webhookRetryPolicy = (&retry.Policy{
+	MaxAttempts:    6,
+	InitialBackoff: retry.Duration(30 * time.Second),
+	MaxBackoff:     retry.Duration(30 * time.Minute),
+	Multiplier:     3,
+}).WithDefaults()
+
+// Webhook is an account's subscription to events by HTTP POST.
+type Webhook = store.Webhook
+
+// WebhookDelivery is one event sent to a webhook, including all its
+// attempts.
+type WebhookDelivery = store.WebhookDelivery
+
+// webhookPayload is the body of every webhook request.
+type webhookPayload struct {
+	ID    string    `json:"id"` // of the delivery
+	Event string    `json:"event"`
+	Time  time.Time `json:"time"`
+	Data  any       `json:"data,omitempty"`
+}
+
+var (
+	webhookSender *webhook.Sender
+	// webhookSub feeds the dispatcher; nil when it is not running.
+	webhookSub            *events.Subscription
+	webhookDispatcherDone chan struct{}
+	// webhookCtx is cancelled when shutdown stops waiting for deliveries.
+	webhookCtx, cancelWebhooks = context.WithCancel(context.Background())
+	webhookDeliveries          sync.WaitGroup
+)
+
+// newWebhookSender builds the sender of all webhook requests.
+// WEBHOOK_ALLOW_PRIVATE_NETWORKS=true lets webhooks reach loopback and
+// private addresses, e.g. a receiver on the same host.
+func newWebhookSender() (*webhook.Sender, error) {
+	var opts []webhook.Option
+	if value := os.Getenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS"); value != "" {
+		allow, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid WEBHOOK_ALLOW_PRIVATE_NETWORKS: %w", err)
+		}
+		if allow {
+			opts = append(opts, webhook.WithPrivateNetworks())
+		}
+	}
+	return webhook.NewSender(opts...), nil
+}
+
+// startWebhookDispatcher sends every event to the webhooks of its account
+// that subscribe to it. Events are published by the replica that caused
+// them, so each is sent once however many replicas run.
+func startWebhookDispatcher() {
+	webhookSub = eventBus.Subscribe(webhookQueueSize, func(e events.Event) bool {
+		_, ok := webhookEvent(e)
+		return ok
+	})
+	webhookDispatcherDone = make(chan struct{})
+	go func(sub *events.Subscription) {
+		defer close(webhookDispatcherDone)
+		for e := range sub.C {
+			e, _ = webhookEvent(e)
+			dispatchWebhooks(e)
+		}
+	}(webhookSub)
+}
+
+// webhookEvent returns the webhook event that e gives rise to, if any.
+// Webhooks hear of a scale outcome once per execution, after its retries,
+// rather than once per attempt as the event stream does; a scale call made
+// outside an execution, like a Telegram command, is its own outcome.
+func webhookEvent(e events.Event) (events.Event, bool) {
+	switch e.Type {
+	case EventExecutionFinished:
+		execution := e.Data.(Execution)
+		switch execution.Outcome {
+		case ExecutionSucceeded:
+			e.Type = EventScaleSucceeded
+		case ExecutionFailed:
+			e.Type = EventScaleFailed
+		default:
+			return e, false
+		}
+		e.Data = ScaleEvent{ScheduleID: execution.ScheduleID, ExecutionID: execution.ID, ServiceType: execution.ServiceType,
+			ServiceName: execution.ServiceName, Action: execution.Action, Attempt: execution.Attempts,
+			StatusCode: execution.StatusCode, Error: execution.Error}
+		return e, true
+	case EventScaleSucceeded, EventScaleFailed:
+		return e, e.Data.(ScaleEvent).ExecutionID == ""
+	}
+	return e, slices.Contains(webhookEvents, e.Type)
+}
+
+// stopWebhooks stops taking events and waits for the deliveries under way
+// until ctx is done. Deliveries waiting to be retried give up as soon as the
+// server starts shutting down and are marked failed. Retries only live in
+// memory, so nothing is resumed on the next start, and a delivery cut short
+// by a crash stays pending.
+func stopWebhooks(ctx context.Context) error {
+	if webhookSub == nil {
+		return nil
+	}
+	webhookSub.Close()
+	<-webhookDispatcherDone
+
+	done := make(chan struct{})
+	go func() {
+		webhookDeliveries.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		cancelWebhooks()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// dispatchWebhooks starts a delivery of e to each matching webhook.
+func dispatchWebhooks(e events.Event) {
+	webhooks, err := dataStore.Webhooks(e.Account)
+	if err != nil {
+		accountLogger(e.Account).Error("Error loading webhooks", "event", e.Type, "error", err)
+		return
+	}
+	for _, w := range webhooks {
+		if len(w.Events) == 0 || slices.Contains(w.Events, e.Type) {
+			startDelivery(w, e)
+		}
+	}
+}
+
+// startDelivery records a delivery of e to w and sends it in the background.
+func startDelivery(w Webhook, e events.Event) (WebhookDelivery, error) {
+	d := WebhookDelivery{ID: uuid.NewString(), WebhookID: w.ID, Owner: w.Owner, Event: e.Type, Status: DeliveryPending, CreatedAt: time.Now()}
+	logger := accountLogger(w.Owner).With("webhook", w.ID, "delivery", d.ID)
+
+	payload, err := json.Marshal(webhookPayload{ID: d.ID, Event: e.Type, Time: e.Time, Data: e.Data})
+	if err != nil {
+		logger.Error("Error encoding webhook payload", "event", e.Type, "error", err)
+		return d, err
+	}
+	d.Payload = payload
+	saveDelivery(logger, d)
+
+	webhookDeliveries.Add(1)
+	go func() {
+		defer webhookDeliveries.Done()
+		deliver(logger, w, d)
+	}()
+	return d, nil
+}
+
+// deliver sends d to w until it is accepted or webhookRetryPolicy gives up,
+// recording every attempt.
+func deliver(logger *slog.Logger, w Webhook, d WebhookDelivery) {
+	for {
+		d.Attempts++
+		status, err := webhookSender.Send(webhookCtx, w.URL, w.Secret, d.Event, d.ID, d.Payload)
+		d.StatusCode = status
+		if err == nil {
+			d.Status, d.Error = DeliveryDelivered, ""
+			break
+		}
+		d.Error = err.Error()
+		if d.Attempts >= webhookRetryPolicy.MaxAttempts || !webhookRetryable(status, err) {
+			d.Status = DeliveryFailed
+			logger.Warn(fmt.Sprintf("Giving up delivering %s to webhook after %d attempt(s)", d.Event, d.Attempts), "error", err)
+			break
+		}
+
+		saveDelivery(logger, d)
+		select {
+		case <-time.After(webhookRetryPolicy.Backoff(d.Attempts)):
+			continue
+		case <-shuttingDown:
+			d.Status = DeliveryFailed
+			logger.Warn(fmt.Sprintf("Not retrying %s delivery to webhook, shutting down", d.Event), "error", err)
+		}
+		break
+	}
+
+	completedAt := time.Now()
+	d.CompletedAt = &completedAt
+	saveDelivery(logger, d)
+}
+
+// webhookRetryable reports whether a failed attempt is worth repeating.
+// Requests that never got a response are retried unless the address was
+// refused.
+func webhookRetryable(status int, err error) bool {
+	if status != 0 {
+		return webhookRetryPolicy.RetryableStatusCode(status)
+	}
+	return !errors.Is(err, webhook.ErrPrivateAddress) && !errors.Is(err, context.Canceled)
+}
+
+func saveDelivery(logger *slog.Logger, d WebhookDelivery) {
+	if err := dataStore.SaveWebhookDelivery(d); err != nil {
+		logger.Error("Error saving webhook delivery", "error", err)
+	}
+}
+
+type WebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"` // empty for all
+}
+
+// WebhookCreatedResponse is the only place the secret is ever shown.
+type WebhookCreatedResponse struct {
+	Webhook
+	Secret string `json:"secret"`
+}
+
+// validateWebhookRequest checks req and removes duplicate events.
+func validateWebhookRequest(req *WebhookRequest) error {
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	var events []string
+	for _, event := range req.Events {
+		if !slices.Contains(webhookEvents, event) {
+			return fmt.Errorf("unknown event %q (available: %s)", event, strings.Join(webhookEvents, ", "))
+		}
+		if !slices.Contains(events, event) {
+			events = append(events, event)
+		}
+	}
+	req.Events = events
+	return nil
+}
+
+// webhooksHandler lists the account's webhooks (GET) or adds one (POST).
+func webhooksHandler(w http.ResponseWriter, r *http.Request) {
+	owner, err := getOwnerFromContext(r.Context())
+	if err != nil {
+		http.Error(w, `{"error": "Authentication required"}`, http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		webhooks, err := dataStore.Webhooks(owner)
+		if err != nil {
+			loggerFromContext(r.Context()).Error("Error loading webhooks", "error", err)
+			http.Error(w, `{"error": "Failed to fetch webhooks"}`, http.StatusInternalServerError)
+			return
+		}
+		for i := range webhooks {
+			webhooks[i].Events = allEventsIfEmpty(webhooks[i].Events)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(webhooks)
+	case http.MethodPost:
+		createWebhookHandler(w, r, owner)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func createWebhookHandler(w http.ResponseWriter, r *http.Request, owner string) {
+	var req WebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+	if err := validateWebhookRequest(&req); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Invalid webhook: %v"}`, err), http.StatusBadRequest)
+		return
+	}
+
+	existing, err := dataStore.Webhooks(owner)
+	if err != nil {
+		loggerFromContext(r.Context()).Error("Error loading webhooks", "error", err)
+		http.Error(w, `{"error": "Failed to fetch webhooks"}`, http.StatusInternalServerError)
+		return
+	}
+	if len(existing) >= maxWebhooksPerAccount {
+		http.Error(w, fmt.Sprintf(`{"error": "An account can have at most %d webhooks"}`, maxWebhooksPerAccount), http.StatusConflict)
+		return
+	}
+
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		http.Error(w, `{"error": "Failed to generate webhook secret"}`, http.StatusInternalServerError)
+		return
+	}
+	hook := Webhook{ID: uuid.NewString(), Owner: owner, URL: req.URL, Events: req.Events, Secret: secret, CreatedAt: time.Now()}
+	if err := dataStore.CreateWebhook(hook); err != nil {
+		loggerFromContext(r.Context()).Error("Error saving webhook", "error", err)
+		http.Error(w, `{"error": "Failed to save webhook to store"}`, http.StatusInternalServerError)
+		return
+	}
+	loggerFromContext(r.Context()).Info("Added webhook", "webhook", hook.ID, "events", strings.Join(allEventsIfEmpty(hook.Events), ","))
+
+	hook.Events = allEventsIfEmpty(hook.Events)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(WebhookCreatedResponse{Webhook: hook, Secret: secret})
+}
+
+// allEventsIfEmpty spells out what a webhook without events subscribes to.
+func allEventsIfEmpty(events []string) []string {
+	if len(events) == 0 {
+		return webhookEvents
+	}
+	return events
+}
+
+// webhookItemHandler routes requests under /webhooks/{id}: DELETE removes a
+// webhook, GET .../deliveries lists its latest deliveries and POST .../ping
+// sends it a ping event.
+func webhookItemHandler(w http.ResponseWriter, r *http.Request) {
+	owner, err := getOwnerFromContext(r.Context())
+	if err != nil {
+		http.Error(w, `{"error": "Authentication required"}`, http.StatusUnauthorized)
+		return
+	}
+
+	pathParts := strings.Split(strings.TrimPrefix(r.URL.Path, "/webhooks/"), "/")
+	parsedID, err := uuid.Parse(pathParts[0])
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Invalid webhook ID format: %v"}`, err), http.StatusBadRequest)
+		return
+	}
+	webhookID := parsedID.String()
+
+	var method, action string
+	switch {
+	case len(pathParts) == 1:
+		method, action = http.MethodDelete, "delete"
+	case len(pathParts) == 2 && pathParts[1] == "deliveries":
+		method, action = http.MethodGet, "deliveries"
+	case len(pathParts) == 2 && pathParts[1] == "ping":
+		method, action = http.MethodPost, "ping"
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != method {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	hook, err := findWebhook(owner, webhookID)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, `{"error": "Webhook not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		loggerFromContext(r.Context()).Error("Error loading webhooks", "error", err)
+		http.Error(w, `{"error": "Failed to fetch webhooks"}`, http.StatusInternalServerError)
+		return
+	}
+
+	switch action {
+	case "delete":
+		deleteWebhookHandler(w, r, hook)
+	case "deliveries":
+		webhookDeliveriesHandler(w, r, hook)
+	case "ping":
+		pingWebhookHandler(w, r, hook)
+	}
+}
+
+// findWebhook returns the owner's webhook or store.ErrNotFound.
+func findWebhook(owner, webhookID string) (Webhook, error) {
+	webhooks, err := dataStore.Webhooks(owner)
+	if err != nil {
+		return Webhook{}, err
+	}
+	for _, hook := range webhooks {
+		if hook.ID == webhookID {
+			return hook, nil
+		}
+	}
+	return Webhook{}, store.ErrNotFound
+}
+
+func deleteWebhookHandler(w http.ResponseWriter, r *http.Request, hook Webhook) {
+	// Another request may have deleted it in the meantime.
+	if err := dataStore.DeleteWebhook(hook.Owner, hook.ID); err != nil && !errors.Is(err, store.ErrNotFound) {
+		loggerFromContext(r.Context()).Error("Error deleting webhook", "error", err)
+		http.Error(w, `{"error": "Failed to delete webhook from store"}`, http.StatusInternalServerError)
+		return
+	}
+	loggerFromContext(r.Context()).Info("Deleted webhook", "webhook", hook.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Webhook deleted successfully"})
+}
+
+// webhookDeliveriesHandler lists the webhook's latest deliveries, newest
+// first; limit caps how many.
+func webhookDeliveriesHandler(w http.ResponseWriter, r *http.Request, hook Webhook) {
+	limit := defaultDeliveriesLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 1 || parsed > store.WebhookDeliveriesKept {
+			http.Error(w, fmt.Sprintf(`{"error": "Invalid query: limit must be between 1 and %d"}`, store.WebhookDeliveriesKept), http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	deliveries, err := dataStore.WebhookDeliveries(hook.Owner, hook.ID, limit)
+	if err != nil {
+		loggerFromContext(r.Context()).Error("Error querying webhook deliveries", "error", err)
+		http.Error(w, `{"error": "Failed to fetch webhook deliveries"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deliveries)
+}
+
+// pingWebhookHandler sends the webhook a ping event, whatever events it
+// subscribes to, and returns the delivery so its outcome can be looked up.
+func pingWebhookHandler(w http.ResponseWriter, r *http.Request, hook Webhook) {
+	ping := events.Event{Type: EventPing, Account: hook.Owner, Time: time.Now(), Data: map[string]string{"webhookId": hook.ID}}
+	d, err := startDelivery(hook, ping)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to send ping"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(d)
+}
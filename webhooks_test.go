@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"scheduler/events"
+	"scheduler/retry"
+	"scheduler/webhook"
+)
+
+func TestWebhookEvent(t *testing.T) {
+	execution := Execution{ID: "e1", ScheduleID: "s1", ServiceType: "project", ServiceName: "app", Action: "on",
+		Attempts: 3, StatusCode: 503, Error: "unavailable"}
+	tests := []struct {
+		name     string
+		event    events.Event
+		wantType string // "" if not sent to webhooks
+	}{
+		{"execution succeeded", events.Event{Type: EventExecutionFinished, Data: withOutcome(execution, ExecutionSucceeded)}, EventScaleSucceeded},
+		{"execution failed", events.Event{Type: EventExecutionFinished, Data: withOutcome(execution, ExecutionFailed)}, EventScaleFailed},
+		{"execution skipped", events.Event{Type: EventExecutionFinished, Data: withOutcome(execution, ExecutionSkipped)}, ""},
+		{"execution started", events.Event{Type: EventExecutionStarted, Data: withOutcome(execution, ExecutionRunning)}, ""},
+		{"attempt of an execution", events.Event{Type: EventScaleFailed, Data: ScaleEvent{ExecutionID: "e1", Attempt: 1}}, ""},
+		{"call outside an execution", events.Event{Type: EventScaleSucceeded, Data: ScaleEvent{ServiceName: "app"}}, EventScaleSucceeded},
+		{"retries exhausted", events.Event{Type: EventRetriesExhausted, Data: ScaleEvent{ExecutionID: "e1"}}, EventRetriesExhausted},
+		{"drift", events.Event{Type: EventReconcileDrift, Data: DriftEvent{}}, EventReconcileDrift},
+		{"schedule created", events.Event{Type: EventScheduleCreated, Data: Schedule{}}, ""},
+	}
+	for _, tt := range tests {
+		got, ok := webhookEvent(tt.event)
+		if !ok {
+			got.Type = ""
+		}
+		if got.Type != tt.wantType {
+			t.Errorf("%s: sent as %q, want %q", tt.name, got.Type, tt.wantType)
+		}
+	}
+
+	got, _ := webhookEvent(events.Event{Type: EventExecutionFinished, Data: withOutcome(execution, ExecutionFailed)})
+	want := ScaleEvent{ScheduleID: "s1", ExecutionID: "e1", ServiceType: "project", ServiceName: "app", Action: "on",
+		Attempt: 3, StatusCode: 503, Error: "unavailable"}
+	if got.Data != want {
+		t.Errorf("data = %+v, want %+v", got.Data, want)
+	}
+}
+
+func TestWebhooksOncePerExecution(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var payload struct{ Event string }
+		json.Unmarshal(body, &payload)
+		mu.Lock()
+		received = append(received, payload.Event)
+		mu.Unlock()
+	}))
+	defer receiver.Close()
+
+	savedSender := webhookSender
+	webhookSender = webhook.NewSender(webhook.WithPrivateNetworks())
+	defer func() { webhookSender = savedSender }()
+	if code, body := serve(t, webhooksHandler, http.MethodPost, "/webhooks", `{"url":"`+receiver.URL+`"}`); code != http.StatusCreated && code != http.StatusOK {
+		t.Fatalf("creating webhook: %d %s", code, body)
+	}
+	hooks, _ := dataStore.Webhooks(testOwner)
+	defer func() {
+		for _, hook := range hooks {
+			dataStore.DeleteWebhook(testOwner, hook.ID)
+		}
+	}()
+
+	calls := 0
+	stubLiara(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{}`))
+	})
+
+	startWebhookDispatcher()
+	a := testAction("webhook-app")
+	executeAction(a, (&retry.Policy{MaxAttempts: 3, InitialBackoff: retry.Duration(time.Millisecond)}).WithDefaults(), time.Now())
+	// Give the dispatcher the events before it is stopped.
+	time.Sleep(100 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := stopWebhooks(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 1 || received[0] != EventScaleSucceeded {
+		t.Errorf("webhook received %v, want a single %s for three attempts", received, EventScaleSucceeded)
+	}
+}
+
+func TestWebhookRetryable(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		err    error
+		want   bool
+	}{
+		{name: "unavailable", status: 503, err: &webhook.StatusError{StatusCode: 503}, want: true},
+		{name: "rate limited", status: 429, err: &webhook.StatusError{StatusCode: 429}, want: true},
+		{name: "not found", status: 404, err: &webhook.StatusError{StatusCode: 404}},
+		{name: "bad request", status: 400, err: &webhook.StatusError{StatusCode: 400}},
+		{name: "connection refused", err: errors.New("dial tcp: connection refused"), want: true},
+		{name: "timeout", err: context.DeadlineExceeded, want: true},
+		{name: "private address", err: fmt.Errorf("dial tcp 10.0.0.1:443: %w", webhook.ErrPrivateAddress)},
+		{name: "canceled", err: fmt.Errorf("Post: %w", context.Canceled)},
+	}
+	for _, tt := range tests {
+		if got := webhookRetryable(tt.status, tt.err); got != tt.want {
+			t.Errorf("%s: webhookRetryable = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func withOutcome(e Execution, outcome string) Execution {
+	e.Outcome = outcome
+	return e
+}
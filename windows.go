@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// maxWindowDuration keeps duration windows shorter than a week so the end of
+// one window cannot be confused with the start of a later one.
+const maxWindowDuration = 7 * 24 * time.Hour
+
+func parseWindowDuration(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 || d >= maxWindowDuration {
+		return 0, fmt.Errorf("%s is not between 0 and %s", s, maxWindowDuration)
+	}
+	return d, nil
+}
+
+// windowEnd returns the cron schedule of a window's end.
+func windowEnd(s Schedule, start cron.Schedule) (cron.Schedule, error) {
+	if s.EndCronSpec != "" {
+		return cron.ParseStandard(cronSpecWithTimezone(s.EndCronSpec, s.Timezone))
+	}
+	d, err := parseWindowDuration(s.Duration)
+	if err != nil {
+		return nil, err
+	}
+	return offsetSchedule{start: start, offset: d}, nil
+}
+
+// windowOpenedAt returns the last activation of start at or before t, i.e.
+// when the window open at t, or closing at t, was opened. ok is false if
+// start did not fire in the maxWindowDuration before t.
+func windowOpenedAt(start cron.Schedule, t time.Time) (opened time.Time, ok bool) {
+	// Search a short span first so that frequent starts are not walked
+	// through for a whole week.
+	for _, span := range []time.Duration{time.Hour, 24 * time.Hour, maxWindowDuration} {
+		for next := start.Next(t.Add(-span)); !next.IsZero() && !next.After(t); next = start.Next(next) {
+			opened, ok = next, true
+		}
+		if ok {
+			return opened, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// offsetSchedule fires a fixed offset after every activation of start. Being
+// derived from the start schedule rather than armed as a timer when the start
+// fires, the end survives restarts and cannot drift from its start.
+type offsetSchedule struct {
+	start  cron.Schedule
+	offset time.Duration
+}
+
+func (o offsetSchedule) Next(t time.Time) time.Time {
+	next := o.start.Next(t.Add(-o.offset))
+	if next.IsZero() {
+		return next
+	}
+	return next.Add(o.offset)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"scheduler/calendar"
+)
+
+func TestOffsetScheduleNext(t *testing.T) {
+	tests := []struct {
+		spec     string
+		duration time.Duration
+		from     time.Time
+	}{
+		{"0 8 * * *", 12 * time.Hour, time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)},
+		{"0 8 * * *", 12 * time.Hour, time.Date(2025, 1, 1, 21, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", 5 * time.Minute, time.Date(2025, 1, 1, 10, 7, 0, 0, time.UTC)},
+		{"0 18 * * 6", 36 * time.Hour, time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 9 1 * *", 48 * time.Hour, time.Date(2025, 6, 2, 12, 0, 0, 0, time.UTC)},
+		{"CRON_TZ=Asia/Tehran 0 8 * * 0-3,6", 10 * time.Hour, time.Date(2025, 3, 20, 18, 0, 0, 0, time.UTC)},
+		// Windows across the spring and autumn DST changes in Berlin.
+		{"CRON_TZ=Europe/Berlin 0 22 * * *", 12 * time.Hour, time.Date(2025, 3, 29, 12, 0, 0, 0, time.UTC)},
+		{"CRON_TZ=Europe/Berlin 0 22 * * *", 12 * time.Hour, time.Date(2025, 10, 25, 12, 0, 0, 0, time.UTC)},
+		{"CRON_TZ=America/New_York 30 1 * * *", 3 * time.Hour, time.Date(2025, 11, 2, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		start, err := cron.ParseStandard(tt.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		end := offsetSchedule{start: start, offset: tt.duration}
+
+		// Every end must be the first start activation plus the duration
+		// that is still ahead, for a week of successive calls.
+		at := tt.from
+		for i := 0; i < 20; i++ {
+			got := end.Next(at)
+			if want := nextStartPlus(start, tt.duration, at); !got.Equal(want) {
+				t.Errorf("%q + %s: Next(%s) = %s, want %s", tt.spec, tt.duration, at, got, want)
+				break
+			}
+			at = got
+		}
+	}
+}
+
+// nextStartPlus finds the first activation of start that ends after t by
+// walking the activations from well before t.
+func nextStartPlus(start cron.Schedule, d time.Duration, t time.Time) time.Time {
+	for s := start.Next(t.Add(-d - time.Minute)); !s.IsZero(); s = start.Next(s) {
+		if s.Add(d).After(t) {
+			return s.Add(d)
+		}
+	}
+	return time.Time{}
+}
+
+func TestWindowEndAcrossDST(t *testing.T) {
+	s := Schedule{Kind: ScheduleKindWindow, CronSpec: "0 22 * * *", Duration: "12h", Timezone: "Europe/Berlin"}
+	start, err := cron.ParseStandard(cronSpecWithTimezone(s.CronSpec, s.Timezone))
+	if err != nil {
+		t.Fatal(err)
+	}
+	end, err := windowEnd(s, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The window opens at 22:00 CET on 29 March and lasts twelve real hours,
+	// which ends at 11:00 CEST after the clocks go forward.
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	got := end.Next(time.Date(2025, 3, 29, 23, 0, 0, 0, berlin))
+	if want := time.Date(2025, 3, 30, 11, 0, 0, 0, berlin); !got.Equal(want) {
+		t.Errorf("window ends at %s, want %s", got, want)
+	}
+}
+
+func TestOvernightWindowCalendar(t *testing.T) {
+	tehran, err := time.LoadLocation("Asia/Tehran")
+	if err != nil {
+		t.Skip("no tzdata for Asia/Tehran")
+	}
+	at := func(day, hour int) time.Time { return time.Date(2025, 4, day, hour, 0, 0, 0, tehran) }
+
+	// The window is open from 22:00 to 06:00 on nights that start in the
+	// Iranian work week, Saturday to Wednesday; 9 April 2025 is a Wednesday.
+	for _, s := range []Schedule{
+		{Kind: ScheduleKindWindow, CronSpec: "0 22 * * *", EndCronSpec: "0 6 * * *", Timezone: "Asia/Tehran"},
+		{Kind: ScheduleKindWindow, CronSpec: "0 22 * * *", Duration: "8h", Timezone: "Asia/Tehran"},
+	} {
+		s.JobID = 1
+		s.Calendar = &calendar.Rule{WorkWeekOnly: true}
+		start, err := cron.ParseStandard(cronSpecWithTimezone(s.CronSpec, s.Timezone))
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := s.EndCronSpec + s.Duration
+
+		runs := []struct {
+			what        string
+			at          time.Time
+			windowStart cron.Schedule
+			skip        bool
+		}{
+			{"opening on Wednesday", at(9, 22), nil, false},
+			{"closing on Thursday morning", at(10, 6), start, false},
+			{"opening on Thursday", at(10, 22), nil, true},
+			{"closing on Friday morning", at(11, 6), start, true},
+			{"opening on Friday", at(11, 22), nil, true},
+			{"closing on Saturday morning", at(12, 6), start, true},
+			{"opening on Saturday", at(12, 22), nil, false},
+			{"closing on Sunday morning", at(13, 6), start, false},
+		}
+		for _, run := range runs {
+			if _, skip := s.calendarSkip(run.at, run.windowStart); skip != run.skip {
+				t.Errorf("%s: %s skipped = %v, want %v", name, run.what, skip, run.skip)
+			}
+		}
+
+		checks := []struct {
+			at             time.Time
+			active, hasSay bool
+		}{
+			{at(10, 2), true, true},    // opened on Wednesday
+			{at(10, 12), false, false}, // Thursday is off
+			{at(11, 2), false, false},  // opened on Thursday
+			{at(12, 2), false, false},  // opened on Friday
+			{at(12, 12), false, true},  // Saturday is a work day
+			{at(13, 2), true, true},    // opened on Saturday
+		}
+		for _, c := range checks {
+			active, hasSay := windowActive(s, c.at)
+			if active != c.active || hasSay != c.hasSay {
+				t.Errorf("%s: windowActive at %s = %v, %v, want %v, %v", name, c.at, active, hasSay, c.active, c.hasSay)
+			}
+		}
+	}
+}